@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+	"sigs.k8s.io/yaml"
+)
+
+type LocalCmd struct {
+	List LocalListCmd `cmd:"" help:"List all lvm-thin volumes attached to this host"`
+	Gc   LocalGcCmd   `cmd:"" help:"Release attached volumes which are neither served nor mounted"`
+}
+
+type LocalListCmd struct {
+}
+
+func (cmd *LocalListCmd) Run(g *flags.GlobalFlags) error {
+	volumes, err := volume.ListLocalVolumes()
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(volumes)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type LocalGcCmd struct {
+	DryRun bool `help:"Only print what would be released"`
+}
+
+func (cmd *LocalGcCmd) Run(g *flags.GlobalFlags) error {
+	return volume.GcLocalVolumes(cmd.DryRun)
+}
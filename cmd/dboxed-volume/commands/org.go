@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"sigs.k8s.io/yaml"
+)
+
+type OrgCmd struct {
+	List   OrgListCmd   `cmd:"" help:"List the organizations you are a member of"`
+	Create OrgCreateCmd `cmd:"" help:"Create an organization (admin only)"`
+	Delete OrgDeleteCmd `cmd:"" help:"Delete an organization without repositories (admin only)"`
+	Member OrgMemberCmd `cmd:"" help:"Manage the members of an organization (admin only)"`
+}
+
+type OrgMemberCmd struct {
+	Add    OrgMemberAddCmd    `cmd:"" help:"Add a member or change the role of an existing member"`
+	List   OrgMemberListCmd   `cmd:"" help:"List the members of an organization"`
+	Remove OrgMemberRemoveCmd `cmd:"" help:"Remove a member. The repositories of the organization are kept"`
+}
+
+// getOrgAsAdmin finds the organization by ID or name in the list of all organizations
+func getOrgAsAdmin(ctx context.Context, c *client.Client, org string) (*models.Organization, error) {
+	orgId, err := strconv.ParseInt(org, 10, 64)
+	isId := err == nil
+
+	l, err := c.AdminListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range l {
+		if (isId && o.ID == orgId) || (!isId && o.Name == org) {
+			return &o, nil
+		}
+	}
+	return nil, fmt.Errorf("organization %s not found", org)
+}
+
+type OrgListCmd struct {
+}
+
+func (cmd *OrgListCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	l, err := c.ListOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(b)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type OrgCreateCmd struct {
+	Name string `help:"Specify the organization name. Must be unique." required:""`
+}
+
+func (cmd *OrgCreateCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	o, err := c.AdminCreateOrganization(ctx, models.CreateOrganization{
+		Name: cmd.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("organization created", slog.Any("id", o.ID), slog.Any("name", o.Name))
+
+	return nil
+}
+
+type OrgDeleteCmd struct {
+	Org string `help:"Specify the organization." required:""`
+}
+
+func (cmd *OrgDeleteCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	o, err := getOrgAsAdmin(ctx, c, cmd.Org)
+	if err != nil {
+		return err
+	}
+
+	err = c.AdminDeleteOrganization(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("organization deleted", slog.Any("id", o.ID), slog.Any("name", o.Name))
+
+	return nil
+}
+
+type OrgMemberAddCmd struct {
+	Org  string `help:"Specify the organization." required:""`
+	User string `help:"Specify the user by ID or email. The user must have logged in at least once." required:""`
+	Role string `help:"Specify the role, which applies to all repositories of the organization." enum:"owner,operator,viewer" default:"operator"`
+}
+
+func (cmd *OrgMemberAddCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	o, err := getOrgAsAdmin(ctx, c, cmd.Org)
+	if err != nil {
+		return err
+	}
+
+	req := models.CreateOrganizationMember{
+		Role: cmd.Role,
+	}
+	if strings.Contains(cmd.User, "@") {
+		req.EMail = &cmd.User
+	} else {
+		req.UserId = &cmd.User
+	}
+
+	m, err := c.AdminCreateOrganizationMember(ctx, o.ID, req)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("organization member added", slog.Any("userId", m.UserId), slog.Any("role", m.Role))
+
+	return nil
+}
+
+type OrgMemberListCmd struct {
+	Org string `help:"Specify the organization." required:""`
+}
+
+func (cmd *OrgMemberListCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	o, err := getOrgAsAdmin(ctx, c, cmd.Org)
+	if err != nil {
+		return err
+	}
+
+	l, err := c.AdminListOrganizationMembers(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(b)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type OrgMemberRemoveCmd struct {
+	Org    string `help:"Specify the organization." required:""`
+	UserId string `help:"Specify the ID of the user." required:""`
+}
+
+func (cmd *OrgMemberRemoveCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	o, err := getOrgAsAdmin(ctx, c, cmd.Org)
+	if err != nil {
+		return err
+	}
+
+	err = c.AdminDeleteOrganizationMember(ctx, o.ID, cmd.UserId)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("organization member removed", slog.Any("userId", cmd.UserId))
+
+	return nil
+}
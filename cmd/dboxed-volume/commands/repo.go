@@ -12,8 +12,10 @@ type RepoCmd struct {
 	Create RepoCreateCmd `cmd:"" help:"Create a repository"`
 	Update RepoUpdateCmd `cmd:"" help:"Update a repository"`
 	List   RepoListCmd   `cmd:"" help:"List repositories"`
+	Access RepoAccessCmd `cmd:"" help:"Manage who can access a repository"`
 }
 
+// getRepo accepts repository IDs, names and names in the form <organization>/<repository>
 func getRepo(ctx context.Context, c *client.Client, repo string) (*models.Repository, error) {
 	repoId, err := strconv.ParseInt(repo, 10, 64)
 	if err == nil {
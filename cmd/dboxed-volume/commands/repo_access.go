@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"sigs.k8s.io/yaml"
+)
+
+type RepoAccessCmd struct {
+	Add    RepoAccessAddCmd    `cmd:"" help:"Grant a user access to a repository or change the role of an existing grant"`
+	List   RepoAccessListCmd   `cmd:"" help:"List the users with access to a repository"`
+	Remove RepoAccessRemoveCmd `cmd:"" help:"Revoke the access of a user"`
+}
+
+type RepoAccessAddCmd struct {
+	Repo string `help:"Specify the repository." required:""`
+	User string `help:"Specify the user by ID or email. The user must have logged in at least once." required:""`
+	Role string `help:"Specify the role. Operators can serve and lock volumes, viewers only have read access." enum:"owner,operator,viewer" default:"operator"`
+}
+
+func (cmd *RepoAccessAddCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRepo(ctx, c, cmd.Repo)
+	if err != nil {
+		return err
+	}
+
+	req := models.CreateRepositoryAccess{
+		Role: cmd.Role,
+	}
+	if strings.Contains(cmd.User, "@") {
+		req.EMail = &cmd.User
+	} else {
+		req.UserId = &cmd.User
+	}
+
+	ra, err := c.CreateRepositoryAccess(ctx, r.ID, req)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("repository access granted", slog.Any("userId", ra.UserId), slog.Any("role", ra.Role))
+
+	return nil
+}
+
+type RepoAccessListCmd struct {
+	Repo string `help:"Specify the repository." required:""`
+}
+
+func (cmd *RepoAccessListCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRepo(ctx, c, cmd.Repo)
+	if err != nil {
+		return err
+	}
+
+	l, err := c.ListRepositoryAccess(ctx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(b)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type RepoAccessRemoveCmd struct {
+	Repo   string `help:"Specify the repository." required:""`
+	UserId string `help:"Specify the ID of the user." required:""`
+}
+
+func (cmd *RepoAccessRemoveCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRepo(ctx, c, cmd.Repo)
+	if err != nil {
+		return err
+	}
+
+	err = c.DeleteRepositoryAccess(ctx, r.ID, cmd.UserId)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("repository access revoked", slog.Any("userId", cmd.UserId))
+
+	return nil
+}
@@ -10,7 +10,8 @@ import (
 )
 
 type RepoCreateCmd struct {
-	Name string `help:"Specify the repository name. Must be unique." required:""`
+	Name string `help:"Specify the repository name. Must be unique within the organization." required:""`
+	Org  string `help:"Specify the organization that owns the repository. Without an organization, the repository is owned by you."`
 
 	S3Endpoint        string  `name:"s3-endpoint" help:"Specify S3 endpoint" default:"s3.amazonaws.com"`
 	S3Region          *string `name:"s3-region" help:"Specify S3 region" optional:""`
@@ -34,6 +35,13 @@ func (cmd *RepoCreateCmd) Run(g *flags.GlobalFlags) error {
 	req := models.CreateRepository{
 		Name: cmd.Name,
 	}
+	if cmd.Org != "" {
+		o, err := c.GetOrganizationByName(ctx, cmd.Org)
+		if err != nil {
+			return err
+		}
+		req.OrganizationId = &o.ID
+	}
 
 	req.S3 = &models.CreateRepositoryStorageS3{
 		Endpoint:        cmd.S3Endpoint,
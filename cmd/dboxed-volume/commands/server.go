@@ -1,5 +1,7 @@
 package commands
 
 type ServerCmd struct {
-	Run ServerRunCmd `cmd:"" help:"Run the server"`
+	Run               ServerRunCmd               `cmd:"" help:"Run the server"`
+	GenerateMasterKey ServerGenerateMasterKeyCmd `cmd:"" help:"Generate a new master key to encrypt secrets in the database"`
+	RotateMasterKey   ServerRotateMasterKeyCmd   `cmd:"" help:"Re-encrypt secrets with the current master key"`
 }
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/pkg/secrets"
+)
+
+type ServerGenerateMasterKeyCmd struct {
+	Out *string `help:"Write the key to this file instead of stdout" type:"path"`
+}
+
+func (cmd *ServerGenerateMasterKeyCmd) Run() error {
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	if cmd.Out == nil {
+		fmt.Println(key)
+		return nil
+	}
+
+	f, err := os.OpenFile(*cmd.Out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(key + "\n")
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("master key written", slog.Any("path", *cmd.Out))
+	return nil
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	config2 "github.com/dboxed/dboxed-volume/pkg/config"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+)
+
+// ServerRotateMasterKeyCmd re-wraps all secrets with the current master key. To rotate, configure the new
+// key as secrets.masterKey(File), move the old key to secrets.previousMasterKeys(Files), run this command and then
+// remove the old key from the config.
+type ServerRotateMasterKeyCmd struct {
+	Config string `help:"Config file" type:"existingfile"`
+}
+
+func (cmd *ServerRotateMasterKeyCmd) Run() error {
+	ctx := context.Background()
+
+	config, err := config2.LoadConfig(cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := config.Secrets.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return fmt.Errorf("missing secrets.masterKey or secrets.masterKeyFile")
+	}
+
+	db, err := openDB(ctx, *config, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	cnt, err := dmodel.ReencryptSecrets(ctx, db, keyring, true)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("secrets re-encrypted", slog.Any("count", cnt))
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/dboxed/dboxed-common/db/migrator"
 	config2 "github.com/dboxed/dboxed-volume/pkg/config"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
 	"github.com/dboxed/dboxed-volume/pkg/db/migration/postgres"
 	"github.com/dboxed/dboxed-volume/pkg/db/migration/sqlite"
 	"github.com/dboxed/dboxed-volume/pkg/server"
@@ -30,6 +31,16 @@ func (cmd *ServerRunCmd) Run() error {
 		return err
 	}
 
+	keyring, err := config.Secrets.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		slog.WarnContext(ctx, "no master key configured, repository secrets and volume encryption keys are stored unencrypted. "+
+			"Generate one with 'server generate-master-key' and configure it as secrets.masterKeyFile")
+	}
+	dmodel.SetSecretsKeyring(keyring)
+
 	db, err := initDB(ctx, *config)
 	if err != nil {
 		return err
@@ -113,6 +124,20 @@ func migrateDB(ctx context.Context, config config2.Config) error {
 	if err != nil {
 		return err
 	}
+	keyring, err := config.Secrets.LoadKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring != nil {
+		// this also encrypts the secrets of servers that were upgraded and got a master key configured afterward
+		cnt, err := dmodel.ReencryptSecrets(ctx, db, keyring, false)
+		if err != nil {
+			return err
+		}
+		if cnt != 0 {
+			slog.InfoContext(ctx, "encrypted plaintext secrets", slog.Any("count", cnt))
+		}
+	}
 	return nil
 }
 
@@ -132,5 +157,12 @@ func initDB(ctx context.Context, config config2.Config) (*sqlx.DB, error) {
 		return nil, err
 	}
 
+	// this is not part of migrateDB, as tokens would stop working if migrations are applied out of band
+	err = dmodel.MigrateTokenHashes(ctx, db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
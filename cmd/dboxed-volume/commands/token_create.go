@@ -2,8 +2,12 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
 	"github.com/dboxed/dboxed-volume/pkg/client"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
@@ -11,6 +15,11 @@ import (
 
 type TokenCreateCmd struct {
 	Name string `help:"Specify the token name. Must be unique." required:""`
+
+	Scope     string        `help:"Specify what the token can do. read-only can only read, serve can additionally lock, serve and back up volumes, admin has the full rights of your user." enum:"read-only,serve,admin" default:"admin"`
+	Repo      []string      `help:"Limit the token to the given repositories. Can be specified multiple times."`
+	Volume    []string      `help:"Limit the token to the given volumes, specified as <repo>:<volume>. Can be specified multiple times. Such tokens can not write to the repository storage."`
+	ExpiresIn time.Duration `help:"Let the token expire after the given duration"`
 }
 
 func (cmd *TokenCreateCmd) Run(g *flags.GlobalFlags) error {
@@ -22,7 +31,35 @@ func (cmd *TokenCreateCmd) Run(g *flags.GlobalFlags) error {
 	}
 
 	req := models.CreateToken{
-		Name: cmd.Name,
+		Name:  cmd.Name,
+		Scope: cmd.Scope,
+	}
+	if cmd.ExpiresIn != 0 {
+		req.ExpiresAt = util.Ptr(time.Now().Add(cmd.ExpiresIn))
+	}
+
+	for _, repo := range cmd.Repo {
+		r, err := getRepo(ctx, c, repo)
+		if err != nil {
+			return err
+		}
+		req.Resources = append(req.Resources, models.TokenResource{
+			RepositoryId: r.ID,
+		})
+	}
+	for _, volume := range cmd.Volume {
+		repo, volumeName, ok := strings.Cut(volume, ":")
+		if !ok {
+			return fmt.Errorf("invalid volume %s, expected <repo>:<volume>", volume)
+		}
+		r, v, err := getVolume(ctx, c, repo, volumeName)
+		if err != nil {
+			return err
+		}
+		req.Resources = append(req.Resources, models.TokenResource{
+			RepositoryId: r.ID,
+			VolumeId:     &v.ID,
+		})
 	}
 
 	token, err := c.CreateToken(ctx, req)
@@ -2,16 +2,25 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/dboxed/dboxed-volume/pkg/client"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
 )
 
 type VolumeCmd struct {
-	Create VolumeCreateCmd `cmd:"" help:"Create a volume in the repository"`
-	List   VolumeListCmd   `cmd:"" help:"List volumes"`
-	Serve  VolumeServeCmd  `cmd:"" help:"Lock, mount and sync a volume"`
+	Create      VolumeCreateCmd      `cmd:"" help:"Create a volume in the repository"`
+	Update      VolumeUpdateCmd      `cmd:"" help:"Update a volume"`
+	List        VolumeListCmd        `cmd:"" help:"List volumes"`
+	Serve       VolumeServeCmd       `cmd:"" help:"Lock, mount and sync a volume"`
+	Restore     VolumeRestoreCmd     `cmd:"" help:"List and restore backups of a volume"`
+	Snapshot    VolumeSnapshotCmd    `cmd:"" help:"Manage local snapshots of a volume"`
+	Fsck        VolumeFsckCmd        `cmd:"" help:"Check and optionally repair an unmounted local volume image"`
+	Unlock      VolumeUnlockCmd      `cmd:"" help:"Release a volume lock held by the given lock id"`
+	ForceUnlock VolumeForceUnlockCmd `cmd:"" help:"Break a volume lock regardless of who holds it"`
+	LockEvents  VolumeLockEventsCmd  `cmd:"" help:"List the lock history of a volume"`
 }
 
 func getVolume(ctx context.Context, c *client.Client, repo string, volume string) (*models.Repository, *models.Volume, error) {
@@ -35,3 +44,77 @@ func getVolume(ctx context.Context, c *client.Client, repo string, volume string
 		return r, v, nil
 	}
 }
+
+type retentionFlags struct {
+	KeepLast    *int64 `help:"Keep the last n backups"`
+	KeepHourly  *int64 `help:"Keep the last n hourly backups"`
+	KeepDaily   *int64 `help:"Keep the last n daily backups"`
+	KeepWeekly  *int64 `help:"Keep the last n weekly backups"`
+	KeepMonthly *int64 `help:"Keep the last n monthly backups"`
+}
+
+func (f *retentionFlags) isSet() bool {
+	return f.KeepLast != nil || f.KeepHourly != nil || f.KeepDaily != nil || f.KeepWeekly != nil || f.KeepMonthly != nil
+}
+
+// applyTo overrides all retention values that were specified on the command line
+func (f *retentionFlags) applyTo(r *models.VolumeRetention) {
+	if f.KeepLast != nil {
+		r.KeepLast = f.KeepLast
+	}
+	if f.KeepHourly != nil {
+		r.KeepHourly = f.KeepHourly
+	}
+	if f.KeepDaily != nil {
+		r.KeepDaily = f.KeepDaily
+	}
+	if f.KeepWeekly != nil {
+		r.KeepWeekly = f.KeepWeekly
+	}
+	if f.KeepMonthly != nil {
+		r.KeepMonthly = f.KeepMonthly
+	}
+}
+
+type snapshotHookFlags struct {
+	SnapshotFreeze      *bool   `help:"Freeze the filesystem while the backup snapshot is taken"`
+	PreSnapshotHook     *string `help:"Specify a shell command or http(s) URL that is called before the backup snapshot is taken"`
+	PostSnapshotHook    *string `help:"Specify a shell command or http(s) URL that is called after the backup snapshot was taken"`
+	SnapshotHookTimeout *string `help:"Specify the timeout of each snapshot hook (e.g. 1m)"`
+}
+
+func (f *snapshotHookFlags) isSet() bool {
+	return f.SnapshotFreeze != nil || f.PreSnapshotHook != nil || f.PostSnapshotHook != nil || f.SnapshotHookTimeout != nil
+}
+
+// applyTo overrides all snapshot hook values that were specified on the command line
+func (f *snapshotHookFlags) applyTo(h *models.VolumeSnapshotHooks) error {
+	if f.SnapshotFreeze != nil {
+		h.Freeze = *f.SnapshotFreeze
+	}
+	if f.PreSnapshotHook != nil {
+		h.PreSnapshot = f.PreSnapshotHook
+	}
+	if f.PostSnapshotHook != nil {
+		h.PostSnapshot = f.PostSnapshotHook
+	}
+	if f.SnapshotHookTimeout != nil {
+		d, err := time.ParseDuration(*f.SnapshotHookTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid snapshot hook timeout %s: %w", *f.SnapshotHookTimeout, err)
+		}
+		timeout := int64(d.Seconds())
+		h.Timeout = &timeout
+	}
+	return nil
+}
+
+// parseLockTtl parses a duration and converts it into the seconds expected by the API
+func parseLockTtl(s string) (*int64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock ttl %s: %w", s, err)
+	}
+	ret := int64(d.Seconds())
+	return &ret, nil
+}
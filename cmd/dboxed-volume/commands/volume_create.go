@@ -16,6 +16,13 @@ type VolumeCreateCmd struct {
 	Name   string `help:"Specify the volume name. Must be unique in the repository."`
 	FsType string `help:"Specify the filesystem type" default:"ext4"`
 	FsSize string `help:"Specify the maximum filesystem size." required:""`
+
+	Encryption string `help:"Specify how the local volume is encrypted. server-key stores the key on the server, local-key requires the key to be passed to serve and restore" enum:"none,server-key,local-key" default:"none"`
+
+	LockTtl *string `help:"Specify after which time without refresh the volume lock expires (e.g. 5m)"`
+
+	retentionFlags
+	snapshotHookFlags
 }
 
 func (cmd *VolumeCreateCmd) Run(g *flags.GlobalFlags) error {
@@ -36,11 +43,31 @@ func (cmd *VolumeCreateCmd) Run(g *flags.GlobalFlags) error {
 		return err
 	}
 
-	rep, err := c.CreateVolume(ctx, r.ID, models.CreateVolume{
-		Name:   cmd.Name,
-		FsSize: int64(fsSize),
-		FsType: cmd.FsType,
-	})
+	req := models.CreateVolume{
+		Name:       cmd.Name,
+		FsSize:     int64(fsSize),
+		FsType:     cmd.FsType,
+		Encryption: cmd.Encryption,
+	}
+	if cmd.LockTtl != nil {
+		req.LockTtl, err = parseLockTtl(*cmd.LockTtl)
+		if err != nil {
+			return err
+		}
+	}
+	if cmd.retentionFlags.isSet() {
+		req.Retention = &models.VolumeRetention{}
+		cmd.retentionFlags.applyTo(req.Retention)
+	}
+	if cmd.snapshotHookFlags.isSet() {
+		req.SnapshotHooks = &models.VolumeSnapshotHooks{}
+		err = cmd.snapshotHookFlags.applyTo(req.SnapshotHooks)
+		if err != nil {
+			return err
+		}
+	}
+
+	rep, err := c.CreateVolume(ctx, r.ID, req)
 	if err != nil {
 		return err
 	}
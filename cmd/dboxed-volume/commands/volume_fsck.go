@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+	"github.com/dboxed/dboxed-volume/pkg/volume_serve"
+)
+
+type VolumeFsckCmd struct {
+	Image  string `help:"Specify the location of the volume image" type:"existingfile" required:""`
+	Repair bool   `help:"Repair found problems instead of only reporting them"`
+
+	Repo              *string `help:"Specify volume repo. Only required for volumes with server-side encryption" and:"volume"`
+	Volume            *string `help:"Specify volume volume. Only required for volumes with server-side encryption" and:"volume"`
+	EncryptionKeyFile *string `help:"Specify the file containing the key of volumes with local-key encryption. Works without repo and volume" type:"existingfile"`
+}
+
+func (cmd *VolumeFsckCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	var encryptionKey []byte
+	if cmd.Repo != nil {
+		c, err := client.New(g.ApiUrl, g.ApiToken)
+		if err != nil {
+			return err
+		}
+		_, v, err := getVolume(ctx, c, *cmd.Repo, *cmd.Volume)
+		if err != nil {
+			return err
+		}
+		encryptionKey, err = volume_serve.LoadEncryptionKey(ctx, c, v, cmd.EncryptionKeyFile, nil)
+		if err != nil {
+			return err
+		}
+	} else if cmd.EncryptionKeyFile != nil {
+		var err error
+		encryptionKey, err = volume_serve.ReadEncryptionKeyFile(*cmd.EncryptionKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	imageLock, err := volume.LockImage(cmd.Image)
+	if err != nil {
+		return err
+	}
+	defer imageLock.Close()
+
+	localVolume, err := volume.Open(cmd.Image, encryptionKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := localVolume.Close()
+		if err != nil {
+			slog.Error("deferred volume close failed", slog.Any("error", err))
+		}
+	}()
+
+	err = localVolume.Fsck(cmd.Repair)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("volume check finished", slog.Any("repair", cmd.Repair))
+	return nil
+}
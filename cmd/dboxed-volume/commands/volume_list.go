@@ -3,7 +3,9 @@ package commands
 import (
 	"context"
 	"os"
+	"time"
 
+	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
 	"github.com/dboxed/dboxed-volume/pkg/client"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
@@ -16,7 +18,26 @@ type VolumeListCmd struct {
 
 type repoWithVolumes struct {
 	models.Repository
-	Volumes []models.Volume `json:"volumes"`
+	Volumes []volumeWithBackupAge `json:"volumes"`
+}
+
+type volumeWithBackupAge struct {
+	models.Volume
+	LastBackupAge *string `json:"lastBackupAge,omitempty"`
+}
+
+func buildVolumesWithBackupAge(volumes []models.Volume) []volumeWithBackupAge {
+	var ret []volumeWithBackupAge
+	for _, v := range volumes {
+		x := volumeWithBackupAge{
+			Volume: v,
+		}
+		if v.LastBackupAt != nil {
+			x.LastBackupAge = util.Ptr(time.Since(*v.LastBackupAt).Truncate(time.Second).String())
+		}
+		ret = append(ret, x)
+	}
+	return ret
 }
 
 func (cmd *VolumeListCmd) Run(g *flags.GlobalFlags) error {
@@ -39,7 +60,7 @@ func (cmd *VolumeListCmd) Run(g *flags.GlobalFlags) error {
 			return err
 		}
 
-		y = volumes
+		y = buildVolumesWithBackupAge(volumes)
 	} else {
 		var l []repoWithVolumes
 		repos, err := c.ListRepositories(ctx)
@@ -53,7 +74,7 @@ func (cmd *VolumeListCmd) Run(g *flags.GlobalFlags) error {
 			}
 			l = append(l, repoWithVolumes{
 				Repository: r,
-				Volumes:    volumes,
+				Volumes:    buildVolumesWithBackupAge(volumes),
 			})
 		}
 		y = l
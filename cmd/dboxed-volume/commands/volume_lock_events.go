@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type VolumeLockEventsCmd struct {
+	Repo   string `help:"Specify the dboxed-volume repo" required:""`
+	Volume string `help:"Specify the volume" required:""`
+}
+
+func (cmd *VolumeLockEventsCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
+	if err != nil {
+		return err
+	}
+
+	events, err := c.ListVolumeLockEvents(ctx, r.ID, v.ID)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+	"github.com/dboxed/dboxed-volume/pkg/volume_backup"
+	"github.com/dboxed/dboxed-volume/pkg/volume_serve"
+	"sigs.k8s.io/yaml"
+)
+
+type VolumeRestoreCmd struct {
+	Repo   string `help:"Specify volume repo" required:""`
+	Volume string `help:"Specify volume volume" required:""`
+
+	List bool `help:"List the available snapshots instead of restoring one"`
+
+	Snapshot *string `help:"Specify the snapshot ID (or an unambiguous prefix of it) to restore" xor:"snapshot"`
+	Time     *string `help:"Restore the newest snapshot taken at or before the given time. Either a RFC3339 timestamp or a duration relative to now (e.g. 24h)" xor:"snapshot"`
+
+	Image   *string `help:"Restore into a new local volume image (lvm-thin), subvolume (btrfs) or directory (dir)" type:"path" xor:"target"`
+	Target  *string `help:"Restore into an existing directory" type:"existingdir" xor:"target"`
+	Backend string  `help:"Specify the local storage backend used with --image" enum:"lvm-thin,btrfs,dir" default:"lvm-thin"`
+
+	BackupMode string `help:"Specify whether to restore file level (rustic) or block level backups. Block level backups can only be restored with --image" enum:"files,blocks" default:"files"`
+
+	EncryptionKeyFile *string `help:"Specify the file containing the key of volumes with local-key encryption" type:"existingfile"`
+
+	WebdavProxyListen string `help:"Specify Webdav/S3 proxy listen address" default:"127.0.0.1:0"`
+}
+
+func (cmd *VolumeRestoreCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
+	if err != nil {
+		return err
+	}
+
+	vb := &volume_backup.VolumeBackup{
+		Client:                c,
+		RepositoryId:          r.ID,
+		VolumeUuid:            v.Uuid,
+		VolumeName:            v.Name,
+		RusticPassword:        r.Rustic.Password,
+		WebdavProxyListenAddr: cmd.WebdavProxyListen,
+	}
+
+	if cmd.BackupMode == volume_backup.BackupModeBlocks {
+		return cmd.runBlocks(ctx, c, vb, v)
+	}
+
+	if cmd.List {
+		snapshots, err := vb.ListSnapshots(ctx)
+		if err != nil {
+			return err
+		}
+		b, err := yaml.Marshal(snapshots)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(b)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if cmd.Image == nil && cmd.Target == nil {
+		return fmt.Errorf("either --image or --target must be specified")
+	}
+
+	var snapshot *volume_backup.RusticSnapshot
+	if cmd.Snapshot != nil {
+		snapshot, err = vb.FindSnapshot(ctx, *cmd.Snapshot)
+	} else if cmd.Time != nil {
+		var t time.Time
+		t, err = parseRestoreTime(*cmd.Time)
+		if err != nil {
+			return err
+		}
+		snapshot, err = vb.FindSnapshotAt(ctx, t)
+	} else {
+		snapshot, err = vb.GetLatestSnapshot(ctx)
+		if err == nil && snapshot == nil {
+			err = fmt.Errorf("volume has no snapshots")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.Target != nil {
+		return vb.Restore(ctx, snapshot, *cmd.Target)
+	} else {
+		return cmd.restoreImage(ctx, c, v, func(localVolume volume.VolumeBackend) error {
+			mountDir, err := os.MkdirTemp("", "")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(mountDir)
+
+			err = localVolume.Mount(mountDir)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err := localVolume.Unmount(mountDir)
+				if err != nil {
+					slog.Error("deferred unmounting failed", slog.Any("error", err))
+				}
+			}()
+
+			return vb.Restore(ctx, snapshot, mountDir)
+		})
+	}
+}
+
+func (cmd *VolumeRestoreCmd) runBlocks(ctx context.Context, c *client.Client, vb *volume_backup.VolumeBackup, v *models.Volume) error {
+	if cmd.List {
+		manifests, err := vb.ListBlockManifests(ctx)
+		if err != nil {
+			return err
+		}
+		b, err := yaml.Marshal(manifests)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(b)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if cmd.Image == nil {
+		return fmt.Errorf("block level backups can only be restored into a new image, --image must be specified")
+	}
+
+	var m *volume_backup.BlockManifest
+	var err error
+	if cmd.Snapshot != nil {
+		m, err = vb.FindBlockManifest(ctx, *cmd.Snapshot)
+	} else if cmd.Time != nil {
+		var t time.Time
+		t, err = parseRestoreTime(*cmd.Time)
+		if err != nil {
+			return err
+		}
+		m, err = vb.FindBlockManifestAt(ctx, t)
+	} else {
+		m, err = vb.GetLatestBlockManifest(ctx)
+		if err == nil && m == nil {
+			err = fmt.Errorf("volume has no block backups")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return cmd.restoreImage(ctx, c, v, func(localVolume volume.VolumeBackend) error {
+		bv, ok := localVolume.(volume.BlockBackend)
+		if !ok {
+			return fmt.Errorf("block level backups can't be restored with the %s backend", cmd.Backend)
+		}
+		return vb.RestoreBlocks(ctx, m, bv.DevName())
+	})
+}
+
+// restoreImage creates and opens a new local volume and then calls restore to fill it. The new volume is deleted
+// again if anything fails, so that a retry does not fail because of a half restored image.
+func (cmd *VolumeRestoreCmd) restoreImage(ctx context.Context, c *client.Client, v *models.Volume, restore func(localVolume volume.VolumeBackend) error) (retErr error) {
+	encryptionKey, err := volume_serve.LoadEncryptionKey(ctx, c, v, cmd.EncryptionKeyFile, nil)
+	if err != nil {
+		return err
+	}
+
+	imageLock, err := volume.LockImage(*cmd.Image)
+	if err != nil {
+		return err
+	}
+	defer imageLock.Close()
+
+	if _, err := os.Stat(*cmd.Image); err == nil {
+		return fmt.Errorf("%s already exists, we won't overwrite it", *cmd.Image)
+	}
+	defer func() {
+		if retErr != nil {
+			cmd.deleteImage(encryptionKey)
+		}
+	}()
+
+	slog.Info("creating local volume", slog.Any("backend", cmd.Backend), slog.Any("path", *cmd.Image))
+	err = volume.CreateBackend(cmd.Backend, volume.CreateOptions{
+		ImagePath: *cmd.Image,
+		ImageSize: v.FsSize * volume.ImageSizeFactor,
+		FsSize:    v.FsSize,
+		FsType:    v.FsType,
+
+		EncryptionKey: encryptionKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	localVolume, err := volume.OpenBackend(cmd.Backend, *cmd.Image, encryptionKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := localVolume.Release()
+		if err != nil {
+			slog.Error("deferred volume release failed", slog.Any("error", err))
+		}
+	}()
+
+	return restore(localVolume)
+}
+
+// deleteImage removes a partially created or restored image. If the volume can't be opened anymore, e.g. because
+// creation failed half way, the image path is removed directly.
+func (cmd *VolumeRestoreCmd) deleteImage(encryptionKey []byte) {
+	slog.Info("deleting partially restored local volume", slog.Any("path", *cmd.Image))
+	localVolume, err := volume.OpenBackend(cmd.Backend, *cmd.Image, encryptionKey)
+	if err == nil {
+		err = localVolume.Delete()
+	} else {
+		err = os.RemoveAll(*cmd.Image)
+	}
+	if err != nil {
+		slog.Error("deleting partially restored local volume failed, it must be removed manually", slog.Any("path", *cmd.Image), slog.Any("error", err))
+	}
+}
+
+func parseRestoreTime(s string) (time.Time, error) {
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, must be a RFC3339 timestamp or a duration", s)
+	}
+	return t, nil
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
 	"github.com/dboxed/dboxed-volume/pkg/client"
-	"github.com/dboxed/dboxed-volume/pkg/server/models"
 	"github.com/dboxed/dboxed-volume/pkg/volume_serve"
 )
 
@@ -19,17 +20,40 @@ type VolumeServeCmd struct {
 
 	PrevLockId *string `help:"Specify previous lock id"`
 	LockIdFile *string `help:"Specify the file to load and store the lock id"`
-
-	Image          string `help:"Specify the location of the volume image" type:"path" required:""`
-	Mount          string `help:"Specify where to mount the volume" type:"existingdir" required:""`
-	SnapshotMount  string `help:"Specify where to mount the temporary backup snapshots" type:"existingdir" required:""`
-	BackupInterval string `help:"Specify the backup interval" default:"5m"`
+	LockLabel  *string `help:"Specify a free-form label that is stored with the lock to identify the holder"`
+
+	Backend          string `help:"Specify the local storage backend" enum:"lvm-thin,btrfs,dir" default:"lvm-thin"`
+	Image            string `help:"Specify the location of the volume image (lvm-thin), subvolume (btrfs) or directory (dir)" type:"path" required:""`
+	Mount            string `help:"Specify where to mount the volume" type:"existingdir" required:""`
+	SnapshotMount    string `help:"Specify where to mount the temporary backup snapshots" type:"existingdir" required:""`
+	BackupMode       string `help:"Specify how backups are taken. files backs up the file tree with rustic, blocks uploads the changed blocks of thin snapshots (lvm-thin only)" enum:"files,blocks" default:"files"`
+	BackupInterval   string `help:"Specify the backup interval" default:"5m"`
+	ForgetInterval   string `help:"Specify the interval in which the retention policy is applied. Set to 0 to disable" default:"1h"`
+	PruneInterval    string `help:"Specify the interval in which unreferenced data is pruned from the repository. Set to 0 to disable" default:"24h"`
+	NoRestore        bool   `help:"Don't restore the latest backup when the local volume image is created"`
+	FinalBackup      bool   `help:"Perform a final backup when shutting down"`
+	NoPoolAutoExtend bool   `help:"Don't grow the image and thin pool automatically when the thin pool is filling up"`
+	FenceMode        string `help:"Specify how writes are stopped when the lock can't be refreshed anymore" enum:"read-only,freeze,none" default:"read-only"`
+
+	TagLegacySnapshots bool `help:"Tag untagged backups from older versions with the identity of this volume if they have the same hostname and snapshot mount. Only use this if no other volume was served on this host with the same snapshot mount"`
+
+	LocalSnapshotInterval string `help:"Specify the interval in which local snapshots are created. Set to 0 to disable" default:"0"`
+	LocalSnapshotKeep     int    `help:"Specify how many scheduled local snapshots are kept. Set to 0 to keep all" default:"24"`
+
+	EncryptionKeyFile *string `help:"Specify the file containing the key of volumes with local-key encryption" type:"existingfile"`
+
+	SnapshotFreeze          bool    `help:"Freeze the filesystem while the backup snapshot is taken, in addition to the volume configuration"`
+	PreSnapshotHook         *string `help:"Specify a shell command or http(s) URL that is called before the backup snapshot is taken. Overrides the volume configuration"`
+	PostSnapshotHook        *string `help:"Specify a shell command or http(s) URL that is called after the backup snapshot was taken. Overrides the volume configuration"`
+	SnapshotHookTimeout     string  `help:"Specify the timeout of each snapshot hook. Overrides the volume configuration"`
+	AllowVolumeHookCommands bool    `help:"Allow running shell command hooks that are configured on the volume. Without this, only http(s) hooks from the volume configuration are used"`
 
 	WebdavProxyListen string `help:"Specify Webdav/S3 proxy listen address" default:"127.0.0.1:0"`
 }
 
 func (cmd *VolumeServeCmd) Run(g *flags.GlobalFlags) error {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	c, err := client.New(g.ApiUrl, g.ApiToken)
 	if err != nil {
@@ -41,6 +65,27 @@ func (cmd *VolumeServeCmd) Run(g *flags.GlobalFlags) error {
 		return err
 	}
 
+	forgetInterval, err := time.ParseDuration(cmd.ForgetInterval)
+	if err != nil {
+		return err
+	}
+	pruneInterval, err := time.ParseDuration(cmd.PruneInterval)
+	if err != nil {
+		return err
+	}
+	localSnapshotInterval, err := time.ParseDuration(cmd.LocalSnapshotInterval)
+	if err != nil {
+		return err
+	}
+
+	var snapshotHookTimeout time.Duration
+	if cmd.SnapshotHookTimeout != "" {
+		snapshotHookTimeout, err = time.ParseDuration(cmd.SnapshotHookTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
 	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
 	if err != nil {
 		return err
@@ -70,41 +115,47 @@ func (cmd *VolumeServeCmd) Run(g *flags.GlobalFlags) error {
 		VolumeId:          v.ID,
 		PrevLockId:        prevLockId,
 		UpdateLockIdCb:    updateLockId,
+		LockLabel:         cmd.LockLabel,
+		Backend:           cmd.Backend,
 		Image:             cmd.Image,
+		EncryptionKeyFile: cmd.EncryptionKeyFile,
 		Mount:             cmd.Mount,
 		SnapshotMount:     cmd.SnapshotMount,
+		BackupMode:        cmd.BackupMode,
 		BackupInterval:    backupInterval,
+		ForgetInterval:    forgetInterval,
+		PruneInterval:     pruneInterval,
+		NoRestore:         cmd.NoRestore,
+		FinalBackup:       cmd.FinalBackup,
+		NoPoolAutoExtend:  cmd.NoPoolAutoExtend,
+		FenceMode:         volume_serve.FenceMode(cmd.FenceMode),
 		WebdavProxyListen: cmd.WebdavProxyListen,
+
+		TagLegacySnapshots: cmd.TagLegacySnapshots,
+
+		LocalSnapshotInterval: localSnapshotInterval,
+		LocalSnapshotKeep:     cmd.LocalSnapshotKeep,
+
+		SnapshotFreeze:          cmd.SnapshotFreeze,
+		PreSnapshotHook:         cmd.PreSnapshotHook,
+		PostSnapshotHook:        cmd.PostSnapshotHook,
+		SnapshotHookTimeout:     snapshotHookTimeout,
+		AllowVolumeHookCommands: cmd.AllowVolumeHookCommands,
 	}
 
 	err = vs.Start(ctx)
 	if err != nil {
+		stop()
+		stopErr := vs.Stop(context.Background())
+		if stopErr != nil {
+			slog.Error("cleanup after failed start failed", slog.Any("error", stopErr))
+		}
 		return err
 	}
 
 	<-ctx.Done()
+	stop()
 
-	return ctx.Err()
-}
-
-func periodicRefreshLock(ctx context.Context, c *client.Client, lockRequest *models.VolumeLockRequest, volumePtr **models.Volume) {
-	for {
-		v := *volumePtr
-
-		slog.Info("refreshing volume lock")
-		lockRequest.PrevLockId = v.LockId
-		newVolume, err := c.VolumeLock(ctx, v.RepositoryID, v.ID, *lockRequest)
-		if err != nil {
-			slog.Error("error in VolumeLock", slog.Any("error", err))
-		} else {
-			*volumePtr = newVolume
-			slog.Info("lock refreshed")
-		}
-
-		select {
-		case <-time.After(15 * time.Second):
-		case <-ctx.Done():
-			return
-		}
-	}
+	slog.Info("shutting down")
+	return vs.Stop(context.Background())
 }
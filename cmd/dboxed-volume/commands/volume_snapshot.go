@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+	"github.com/dboxed/dboxed-volume/pkg/volume_serve"
+	"sigs.k8s.io/yaml"
+)
+
+type VolumeSnapshotCmd struct {
+	Create   VolumeSnapshotCreateCmd   `cmd:"" help:"Create a named local snapshot"`
+	List     VolumeSnapshotListCmd     `cmd:"" help:"List the local snapshots"`
+	Mount    VolumeSnapshotMountCmd    `cmd:"" help:"Mount a local snapshot read-only"`
+	Unmount  VolumeSnapshotUnmountCmd  `cmd:"" help:"Unmount a local snapshot"`
+	Delete   VolumeSnapshotDeleteCmd   `cmd:"" help:"Delete a local snapshot"`
+	Rollback VolumeSnapshotRollbackCmd `cmd:"" help:"Replace the content of the volume with a local snapshot. The volume must not be served while rolling back"`
+}
+
+type localVolumeFlags struct {
+	Repo   string `help:"Specify volume repo" required:""`
+	Volume string `help:"Specify volume volume" required:""`
+
+	Backend string `help:"Specify the local storage backend" enum:"lvm-thin,btrfs,dir" default:"lvm-thin"`
+	Image   string `help:"Specify the location of the volume image (lvm-thin), subvolume (btrfs) or directory (dir)" type:"path" required:""`
+
+	EncryptionKeyFile *string `help:"Specify the file containing the key of volumes with local-key encryption" type:"existingfile"`
+}
+
+// run opens the local volume, calls fn and closes the volume again
+func (f *localVolumeFlags) run(g *flags.GlobalFlags, fn func(v volume.VolumeBackend) error) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	_, v, err := getVolume(ctx, c, f.Repo, f.Volume)
+	if err != nil {
+		return err
+	}
+
+	encryptionKey, err := volume_serve.LoadEncryptionKey(ctx, c, v, f.EncryptionKeyFile, nil)
+	if err != nil {
+		return err
+	}
+
+	localVolume, err := volume.OpenBackend(f.Backend, f.Image, encryptionKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := localVolume.Close()
+		if err != nil {
+			slog.Error("deferred volume close failed", slog.Any("error", err))
+		}
+	}()
+
+	return fn(localVolume)
+}
+
+type VolumeSnapshotCreateCmd struct {
+	localVolumeFlags
+
+	Name string `help:"Specify the snapshot name" required:""`
+}
+
+func (cmd *VolumeSnapshotCreateCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		err := volume.CreateNamedSnapshot(v, cmd.Name)
+		if err != nil {
+			return err
+		}
+		slog.Info("snapshot created", slog.Any("name", cmd.Name))
+		return nil
+	})
+}
+
+type VolumeSnapshotListCmd struct {
+	localVolumeFlags
+}
+
+type localSnapshot struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Age  string    `json:"age"`
+}
+
+func (cmd *VolumeSnapshotListCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		snapshots, err := volume.ListNamedSnapshots(v)
+		if err != nil {
+			return err
+		}
+		var l []localSnapshot
+		for _, s := range snapshots {
+			l = append(l, localSnapshot{
+				Name: s.Name,
+				Time: s.Time,
+				Age:  time.Since(s.Time).Truncate(time.Second).String(),
+			})
+		}
+
+		b, err := yaml.Marshal(l)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(b)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+type VolumeSnapshotMountCmd struct {
+	localVolumeFlags
+
+	Name   string `help:"Specify the snapshot name" required:""`
+	Target string `help:"Specify where to mount the snapshot" type:"existingdir" required:""`
+}
+
+func (cmd *VolumeSnapshotMountCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		return volume.MountNamedSnapshot(v, cmd.Name, cmd.Target)
+	})
+}
+
+type VolumeSnapshotUnmountCmd struct {
+	localVolumeFlags
+
+	Name string `help:"Specify the snapshot name" required:""`
+}
+
+func (cmd *VolumeSnapshotUnmountCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		return volume.UnmountNamedSnapshot(v, cmd.Name)
+	})
+}
+
+type VolumeSnapshotDeleteCmd struct {
+	localVolumeFlags
+
+	Name string `help:"Specify the snapshot name" required:""`
+}
+
+func (cmd *VolumeSnapshotDeleteCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		err := volume.DeleteNamedSnapshot(v, cmd.Name)
+		if err != nil {
+			return err
+		}
+		slog.Info("snapshot deleted", slog.Any("name", cmd.Name))
+		return nil
+	})
+}
+
+type VolumeSnapshotRollbackCmd struct {
+	localVolumeFlags
+
+	Name string `help:"Specify the snapshot name" required:""`
+}
+
+func (cmd *VolumeSnapshotRollbackCmd) Run(g *flags.GlobalFlags) error {
+	return cmd.run(g, func(v volume.VolumeBackend) error {
+		err := volume.RollbackNamedSnapshot(v, cmd.Name)
+		if err != nil {
+			return err
+		}
+		slog.Info("volume rolled back", slog.Any("name", cmd.Name))
+		return nil
+	})
+}
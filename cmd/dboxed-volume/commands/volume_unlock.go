@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+)
+
+type VolumeUnlockCmd struct {
+	Repo   string `help:"Specify the dboxed-volume repo" required:""`
+	Volume string `help:"Specify the volume" required:""`
+
+	LockId     *string `help:"Specify the current lock id" xor:"lock-id"`
+	LockIdFile *string `help:"Specify the file to load the current lock id from" xor:"lock-id"`
+}
+
+func (cmd *VolumeUnlockCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	var lockId string
+	if cmd.LockId != nil {
+		lockId = *cmd.LockId
+	} else if cmd.LockIdFile != nil {
+		b, err := os.ReadFile(*cmd.LockIdFile)
+		if err != nil {
+			return err
+		}
+		lockId = strings.TrimSpace(string(b))
+	} else {
+		return fmt.Errorf("either --lock-id or --lock-id-file must be specified")
+	}
+
+	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.VolumeUnlock(ctx, r.ID, v.ID, models.VolumeUnlockRequest{
+		LockId: lockId,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("volume unlocked", slog.Any("id", v.ID), slog.Any("lockId", lockId))
+
+	return nil
+}
+
+type VolumeForceUnlockCmd struct {
+	Repo   string `help:"Specify the dboxed-volume repo" required:""`
+	Volume string `help:"Specify the volume" required:""`
+
+	Reason string `help:"Specify why the lock is broken. This is recorded on the volume" required:""`
+}
+
+func (cmd *VolumeForceUnlockCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
+	if err != nil {
+		return err
+	}
+
+	if v.LockId != nil {
+		slog.Warn("breaking volume lock", slog.Any("id", v.ID), slog.Any("lockId", *v.LockId))
+	}
+
+	_, err = c.VolumeForceUnlock(ctx, r.ID, v.ID, models.VolumeForceUnlockRequest{
+		Reason: cmd.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("volume lock broken", slog.Any("id", v.ID))
+
+	return nil
+}
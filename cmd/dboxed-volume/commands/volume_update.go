@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dboxed/dboxed-common/util"
+	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dustin/go-humanize"
+)
+
+type VolumeUpdateCmd struct {
+	Repo   string `help:"Specify the dboxed-volume repo" required:""`
+	Volume string `help:"Specify the volume" required:""`
+
+	FsSize  *string `help:"Specify the new maximum filesystem size. Volumes can only grow"`
+	LockTtl *string `help:"Specify after which time without refresh the volume lock expires (e.g. 5m)"`
+
+	retentionFlags
+
+	ClearRetention bool `help:"Remove the retention policy, so that backups are kept forever"`
+
+	snapshotHookFlags
+
+	ClearSnapshotHooks bool `help:"Remove all snapshot hooks and disable freezing"`
+}
+
+func (cmd *VolumeUpdateCmd) Run(g *flags.GlobalFlags) error {
+	ctx := context.Background()
+
+	c, err := client.New(g.ApiUrl, g.ApiToken)
+	if err != nil {
+		return err
+	}
+
+	r, v, err := getVolume(ctx, c, cmd.Repo, cmd.Volume)
+	if err != nil {
+		return err
+	}
+
+	req := models.UpdateVolume{}
+
+	if cmd.FsSize != nil {
+		fsSize, err := humanize.ParseBytes(*cmd.FsSize)
+		if err != nil {
+			return err
+		}
+		req.FsSize = util.Ptr(int64(fsSize))
+	}
+
+	if cmd.LockTtl != nil {
+		req.LockTtl, err = parseLockTtl(*cmd.LockTtl)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.ClearRetention {
+		req.Retention = &models.VolumeRetention{}
+	} else if cmd.retentionFlags.isSet() {
+		retention := v.Retention
+		cmd.retentionFlags.applyTo(&retention)
+		req.Retention = &retention
+	}
+
+	if cmd.ClearSnapshotHooks {
+		req.SnapshotHooks = &models.VolumeSnapshotHooks{}
+	} else if cmd.snapshotHookFlags.isSet() {
+		hooks := v.SnapshotHooks
+		err = cmd.snapshotHookFlags.applyTo(&hooks)
+		if err != nil {
+			return err
+		}
+		req.SnapshotHooks = &hooks
+	}
+
+	rep, err := c.UpdateVolume(ctx, r.ID, v.ID, req)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("volume updated", slog.Any("id", rep.ID), slog.Any("uuid", rep.Uuid))
+
+	return nil
+}
@@ -3,6 +3,8 @@ package flags
 type GlobalFlags struct {
 	Debug bool `help:"Enable debugging mode"`
 
+	CliBackends bool `help:"Use the plain losetup and lvm command line tools instead of the native loop backend and device scoped lvm calls"`
+
 	ApiUrl   string  `help:"Specify the API url" default:"https://volumes.dboxed.io"`
 	ApiToken *string `help:"Specify a static API token"`
 }
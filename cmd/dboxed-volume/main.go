@@ -8,6 +8,8 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/commands"
 	"github.com/dboxed/dboxed-volume/cmd/dboxed-volume/flags"
+	"github.com/dboxed/dboxed-volume/pkg/losetup"
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
 	versionpkg "github.com/dboxed/dboxed-volume/pkg/version"
 )
 
@@ -18,9 +20,11 @@ type Cli struct {
 
 	Server commands.ServerCmd `cmd:"" help:"Server commands"`
 
+	Org    commands.OrgCmd    `cmd:"" help:"Organization commands"`
 	Repo   commands.RepoCmd   `cmd:"" help:"Repo commands"`
 	Volume commands.VolumeCmd `cmd:"" help:"Volume commands"`
 	Token  commands.TokenCmd  `cmd:"" help:"Token commands"`
+	Local  commands.LocalCmd  `cmd:"" help:"Inspect and clean up local volumes of this host"`
 
 	Debug commands.DebugCmd `cmd:"" help:"Debug/dev commands"`
 }
@@ -44,6 +48,11 @@ func Execute() {
 		kong.DefaultEnvars("DBOXED_VOLUME"),
 	)
 
+	if cli.CliBackends {
+		losetup.SetBackend(losetup.NewCli())
+		lvm.UseGlobalScans()
+	}
+
 	err := ctx.Run(&cli.GlobalFlags)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
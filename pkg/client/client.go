@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"sync"
 
@@ -75,10 +76,92 @@ func (c *Client) GetRepositoryById(ctx context.Context, repoId int64) (*models.R
 	return requestApi[models.Repository](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d", repoId), struct{}{})
 }
 
+// GetRepositoryByName also accepts names in the form <organization>/<repository> for repositories of organizations
 func (c *Client) GetRepositoryByName(ctx context.Context, name string) (*models.Repository, error) {
 	return requestApi[models.Repository](ctx, c, "GET", fmt.Sprintf("v1/repositories/by-name/%s", name), struct{}{})
 }
 
+func (c *Client) RepositoryPruneLock(ctx context.Context, repoId int64, req models.RepositoryPruneLockRequest) (*models.RepositoryPruneLock, error) {
+	return requestApi[models.RepositoryPruneLock](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/prune-lock", repoId), req)
+}
+
+func (c *Client) RepositoryPruneUnlock(ctx context.Context, repoId int64, req models.RepositoryPruneUnlockRequest) error {
+	_, err := requestApi[huma_utils.Empty](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/prune-unlock", repoId), req)
+	return err
+}
+
+func (c *Client) ListRepositoryAccess(ctx context.Context, repoId int64) ([]models.RepositoryAccess, error) {
+	l, err := requestApi[huma_utils.ListBody[models.RepositoryAccess]](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/access", repoId), struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) CreateRepositoryAccess(ctx context.Context, repoId int64, req models.CreateRepositoryAccess) (*models.RepositoryAccess, error) {
+	return requestApi[models.RepositoryAccess](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/access", repoId), req)
+}
+
+func (c *Client) DeleteRepositoryAccess(ctx context.Context, repoId int64, userId string) error {
+	_, err := requestApi[huma_utils.Empty](ctx, c, "DELETE", fmt.Sprintf("v1/repositories/%d/access/%s", repoId, url.PathEscape(userId)), struct{}{})
+	return err
+}
+
+func (c *Client) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	l, err := requestApi[huma_utils.ListBody[models.Organization]](ctx, c, "GET", "v1/organizations", struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) GetOrganizationByName(ctx context.Context, name string) (*models.Organization, error) {
+	l, err := c.ListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range l {
+		if o.Name == name {
+			return &o, nil
+		}
+	}
+	return nil, fmt.Errorf("organization %s not found", name)
+}
+
+func (c *Client) AdminCreateOrganization(ctx context.Context, req models.CreateOrganization) (*models.Organization, error) {
+	return requestApi[models.Organization](ctx, c, "POST", "v1/admin/organizations", req)
+}
+
+func (c *Client) AdminListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	l, err := requestApi[huma_utils.ListBody[models.Organization]](ctx, c, "GET", "v1/admin/organizations", struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) AdminDeleteOrganization(ctx context.Context, orgId int64) error {
+	_, err := requestApi[huma_utils.Empty](ctx, c, "DELETE", fmt.Sprintf("v1/admin/organizations/%d", orgId), struct{}{})
+	return err
+}
+
+func (c *Client) AdminListOrganizationMembers(ctx context.Context, orgId int64) ([]models.OrganizationMember, error) {
+	l, err := requestApi[huma_utils.ListBody[models.OrganizationMember]](ctx, c, "GET", fmt.Sprintf("v1/admin/organizations/%d/members", orgId), struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) AdminCreateOrganizationMember(ctx context.Context, orgId int64, req models.CreateOrganizationMember) (*models.OrganizationMember, error) {
+	return requestApi[models.OrganizationMember](ctx, c, "POST", fmt.Sprintf("v1/admin/organizations/%d/members", orgId), req)
+}
+
+func (c *Client) AdminDeleteOrganizationMember(ctx context.Context, orgId int64, userId string) error {
+	_, err := requestApi[huma_utils.Empty](ctx, c, "DELETE", fmt.Sprintf("v1/admin/organizations/%d/members/%s", orgId, url.PathEscape(userId)), struct{}{})
+	return err
+}
+
 func (c *Client) CreateVolume(ctx context.Context, repoId int64, req models.CreateVolume) (*models.Volume, error) {
 	return requestApi[models.Volume](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes", repoId), req)
 }
@@ -88,6 +171,10 @@ func (c *Client) DeleteVolume(ctx context.Context, repoId int64, volumeId int64)
 	return err
 }
 
+func (c *Client) UpdateVolume(ctx context.Context, repoId int64, volumeId int64, req models.UpdateVolume) (*models.Volume, error) {
+	return requestApi[models.Volume](ctx, c, "PATCH", fmt.Sprintf("v1/repositories/%d/volumes/%d", repoId, volumeId), req)
+}
+
 func (c *Client) ListVolumes(ctx context.Context, repoId int64) ([]models.Volume, error) {
 	l, err := requestApi[huma_utils.ListBody[models.Volume]](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes", repoId), struct{}{})
 	if err != nil {
@@ -104,10 +191,58 @@ func (c *Client) GetVolumeByName(ctx context.Context, repoId int64, name string)
 	return requestApi[models.Volume](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes/by-name/%s", repoId, name), struct{}{})
 }
 
+func (c *Client) GetVolumeEncryptionKey(ctx context.Context, repoId int64, volumeId int64) (*models.VolumeEncryptionKey, error) {
+	return requestApi[models.VolumeEncryptionKey](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes/%d/encryption-key", repoId, volumeId), struct{}{})
+}
+
+// GetVolumeEncryptionKeyWithLock only requires the operator role, but the volume must be locked by the caller
+func (c *Client) GetVolumeEncryptionKeyWithLock(ctx context.Context, repoId int64, volumeId int64, lockId string) (*models.VolumeEncryptionKey, error) {
+	return requestApi[models.VolumeEncryptionKey](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes/%d/encryption-key", repoId, volumeId), models.VolumeEncryptionKeyRequest{
+		LockId: lockId,
+	})
+}
+
 func (c *Client) VolumeLock(ctx context.Context, repoId int64, volumeId int64, req models.VolumeLockRequest) (*models.Volume, error) {
 	return requestApi[models.Volume](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes/%d/lock", repoId, volumeId), req)
 }
 
+func (c *Client) VolumeUnlock(ctx context.Context, repoId int64, volumeId int64, req models.VolumeUnlockRequest) (*models.Volume, error) {
+	return requestApi[models.Volume](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes/%d/unlock", repoId, volumeId), req)
+}
+
+func (c *Client) VolumeForceUnlock(ctx context.Context, repoId int64, volumeId int64, req models.VolumeForceUnlockRequest) (*models.Volume, error) {
+	return requestApi[models.Volume](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes/%d/force-unlock", repoId, volumeId), req)
+}
+
+func (c *Client) CreateVolumeBackup(ctx context.Context, repoId int64, volumeId int64, req models.CreateVolumeBackup) (*models.VolumeBackup, error) {
+	return requestApi[models.VolumeBackup](ctx, c, "POST", fmt.Sprintf("v1/repositories/%d/volumes/%d/backups", repoId, volumeId), req)
+}
+
+func (c *Client) ListVolumeBackups(ctx context.Context, repoId int64, volumeId int64) ([]models.VolumeBackup, error) {
+	l, err := requestApi[huma_utils.ListBody[models.VolumeBackup]](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes/%d/backups", repoId, volumeId), struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) ListVolumeLockEvents(ctx context.Context, repoId int64, volumeId int64) ([]models.VolumeLockEvent, error) {
+	l, err := requestApi[huma_utils.ListBody[models.VolumeLockEvent]](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes/%d/lock-events", repoId, volumeId), struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, err
+}
+
+func (c *Client) GetVolumeBackupById(ctx context.Context, repoId int64, volumeId int64, backupId int64) (*models.VolumeBackup, error) {
+	return requestApi[models.VolumeBackup](ctx, c, "GET", fmt.Sprintf("v1/repositories/%d/volumes/%d/backups/%d", repoId, volumeId, backupId), struct{}{})
+}
+
+func (c *Client) DeleteVolumeBackup(ctx context.Context, repoId int64, volumeId int64, backupId int64) error {
+	_, err := requestApi[huma_utils.Empty](ctx, c, "DELETE", fmt.Sprintf("v1/repositories/%d/volumes/%d/backups/%d", repoId, volumeId, backupId), struct{}{})
+	return err
+}
+
 func (c *Client) CreateToken(ctx context.Context, req models.CreateToken) (*models.CreateTokenResult, error) {
 	return requestApi[models.CreateTokenResult](ctx, c, "POST", "v1/tokens", req)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,23 @@ import (
 	"path"
 )
 
+// HttpError is returned when the server replied with a non-2xx status
+type HttpError struct {
+	Path       string
+	Status     string
+	StatusCode int
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("%s request returned http status %s", e.Path, e.Status)
+}
+
+// IsHttpStatus returns true if the error was caused by the server replying with the given status
+func IsHttpStatus(err error, statusCode int) bool {
+	var httpErr *HttpError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == statusCode
+}
+
 func requestApi[ReplyBody any, RequestBody any](ctx context.Context, c *Client, method string, p string, body RequestBody) (*ReplyBody, error) {
 	return requestApi2[ReplyBody, RequestBody](ctx, c, method, p, body, true)
 }
@@ -55,7 +73,7 @@ func requestApi2[ReplyBody any, RequestBody any](ctx context.Context, c *Client,
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("%s request returned http status %s", p, resp.Status)
+		return nil, &HttpError{Path: p, Status: resp.Status, StatusCode: resp.StatusCode}
 	}
 
 	b, err = io.ReadAll(resp.Body)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/dboxed/dboxed-volume/pkg/secrets"
 	"sigs.k8s.io/yaml"
 )
 
@@ -11,6 +12,8 @@ type Config struct {
 	Auth   AuthConfig   `json:"auth"`
 	DB     DbConfig     `json:"db"`
 	Server ServerConfig `json:"server"`
+
+	Secrets SecretsConfig `json:"secrets"`
 }
 
 type AuthConfig struct {
@@ -30,6 +33,61 @@ type ServerConfig struct {
 	BaseUrl       string `json:"baseUrl"`
 }
 
+// SecretsConfig configures the master key used to encrypt repository secrets in the database. Previous master keys
+// are only needed while rotating to a new master key.
+type SecretsConfig struct {
+	MasterKey     string `json:"masterKey"`
+	MasterKeyFile string `json:"masterKeyFile"`
+
+	PreviousMasterKeys     []string `json:"previousMasterKeys"`
+	PreviousMasterKeyFiles []string `json:"previousMasterKeyFiles"`
+}
+
+// LoadKeyring returns nil if no master key is configured
+func (c *SecretsConfig) LoadKeyring() (*secrets.Keyring, error) {
+	if c.MasterKey == "" && c.MasterKeyFile == "" {
+		if len(c.PreviousMasterKeys) != 0 || len(c.PreviousMasterKeyFiles) != 0 {
+			return nil, fmt.Errorf("previous master keys are configured, but secrets.masterKey or secrets.masterKeyFile is missing")
+		}
+		return nil, nil
+	}
+	if c.MasterKey != "" && c.MasterKeyFile != "" {
+		return nil, fmt.Errorf("only one of secrets.masterKey and secrets.masterKeyFile can be set")
+	}
+
+	current, err := loadKey(c.MasterKey, c.MasterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var previous [][]byte
+	for _, s := range c.PreviousMasterKeys {
+		key, err := loadKey(s, "")
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	for _, f := range c.PreviousMasterKeyFiles {
+		key, err := loadKey("", f)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return secrets.NewKeyring(current, previous...)
+}
+
+func loadKey(s string, file string) ([]byte, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	}
+	return secrets.ParseKey(s)
+}
+
 func LoadConfig(configPath string) (*Config, error) {
 	if configPath == "" {
 		return nil, fmt.Errorf("missing config path")
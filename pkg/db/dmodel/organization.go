@@ -0,0 +1,86 @@
+package dmodel
+
+import (
+	"github.com/dboxed/dboxed-common/db/querier"
+)
+
+type Organization struct {
+	Base
+
+	Name string `db:"name"`
+}
+
+// OrganizationMember uses the same roles as RepositoryAccess. The role applies to all repositories of the organization.
+type OrganizationMember struct {
+	OrganizationId int64  `db:"organization_id"`
+	UserId         string `db:"user_id"`
+	Role           string `db:"role"`
+}
+
+func (v *Organization) Create(q *querier.Querier) error {
+	return querier.Create(q, v)
+}
+
+func (v *OrganizationMember) Create(q *querier.Querier) error {
+	return querier.Create(q, v)
+}
+
+func ListOrganizations(q *querier.Querier, skipDeleted bool) ([]Organization, error) {
+	return querier.GetMany[Organization](q, map[string]any{
+		"deleted_at": querier.ExcludeNonNull(skipDeleted),
+	})
+}
+
+func ListOrganizationsForUser(q *querier.Querier, userId string, skipDeleted bool) ([]Organization, error) {
+	where := "organization.id in (select organization_id from organization_member where user_id = :user_id)"
+	if skipDeleted {
+		where += " and deleted_at is null"
+	}
+	return querier.GetManyWhere[Organization](q, where, map[string]any{
+		"user_id": userId,
+	})
+}
+
+func GetOrganizationById(q *querier.Querier, id int64, skipDeleted bool) (*Organization, error) {
+	return querier.GetOne[Organization](q, map[string]any{
+		"id":         id,
+		"deleted_at": querier.ExcludeNonNull(skipDeleted),
+	})
+}
+
+func GetOrganizationByName(q *querier.Querier, name string, skipDeleted bool) (*Organization, error) {
+	return querier.GetOne[Organization](q, map[string]any{
+		"name":       name,
+		"deleted_at": querier.ExcludeNonNull(skipDeleted),
+	})
+}
+
+func ListOrganizationMembers(q *querier.Querier, organizationId int64) ([]OrganizationMember, error) {
+	return querier.GetMany[OrganizationMember](q, map[string]any{
+		"organization_id": organizationId,
+	})
+}
+
+func GetOrganizationMember(q *querier.Querier, organizationId int64, userId string) (*OrganizationMember, error) {
+	return querier.GetOne[OrganizationMember](q, map[string]any{
+		"organization_id": organizationId,
+		"user_id":         userId,
+	})
+}
+
+func (v *OrganizationMember) UpdateRole(q *querier.Querier, role string) error {
+	v.Role = role
+	return querier.UpdateOneByFields[OrganizationMember](q, map[string]any{
+		"organization_id": v.OrganizationId,
+		"user_id":         v.UserId,
+	}, map[string]any{
+		"role": role,
+	})
+}
+
+func DeleteOrganizationMember(q *querier.Querier, organizationId int64, userId string) error {
+	return querier.DeleteOneByFields[OrganizationMember](q, map[string]any{
+		"organization_id": organizationId,
+		"user_id":         userId,
+	})
+}
@@ -1,7 +1,7 @@
 package dmodel
 
 import (
-	"fmt"
+	"database/sql"
 	"strings"
 
 	"github.com/dboxed/dboxed-common/db/querier"
@@ -10,19 +10,47 @@ import (
 type Repository struct {
 	Base
 
+	OrganizationId *int64 `db:"organization_id"`
+
 	Name string `db:"name"`
 	Uuid string `db:"uuid"`
 
+	PruneLockId   *string `db:"prune_lock_id"`
+	PruneLockTime *int64  `db:"prune_lock_time"`
+
 	S3 *RepositoryStorageS3 `join:"true"`
 
-	Rustic *RepositoryBackupRustic `join:"true""`
+	Rustic *RepositoryBackupRustic `join:"true"`
 
 	Access []RepositoryAccess
 }
 
+const (
+	RepositoryRoleOwner    = "owner"
+	RepositoryRoleOperator = "operator"
+	RepositoryRoleViewer   = "viewer"
+)
+
+var repositoryRoleLevels = map[string]int{
+	RepositoryRoleViewer:   1,
+	RepositoryRoleOperator: 2,
+	RepositoryRoleOwner:    3,
+}
+
+func IsValidRepositoryRole(role string) bool {
+	_, ok := repositoryRoleLevels[role]
+	return ok
+}
+
+// RepositoryRoleAllows returns true if role grants at least the permissions of required
+func RepositoryRoleAllows(role string, required string) bool {
+	return repositoryRoleLevels[role] >= repositoryRoleLevels[required]
+}
+
 type RepositoryAccess struct {
 	RepositoryId int64  `db:"repository_id"`
 	UserId       string `db:"user_id"`
+	Role         string `db:"role"`
 }
 
 type RepositoryStorageS3 struct {
@@ -50,12 +78,43 @@ func (v *RepositoryAccess) Create(q *querier.Querier) error {
 	return querier.Create(q, v)
 }
 
+func (v *RepositoryAccess) UpdateRole(q *querier.Querier, role string) error {
+	v.Role = role
+	return querier.UpdateOneByFields[RepositoryAccess](q, map[string]any{
+		"repository_id": v.RepositoryId,
+		"user_id":       v.UserId,
+	}, map[string]any{
+		"role": role,
+	})
+}
+
+func DeleteRepositoryAccess(q *querier.Querier, repositoryId int64, userId string) error {
+	return querier.DeleteOneByFields[RepositoryAccess](q, map[string]any{
+		"repository_id": repositoryId,
+		"user_id":       userId,
+	})
+}
+
+// Create stores the S3 storage with an encrypted secret access key. v itself keeps the plaintext key.
 func (v *RepositoryStorageS3) Create(q *querier.Querier) error {
-	return querier.Create(q, v)
+	secretAccessKey, err := encryptSecret(v.SecretAccessKey.V)
+	if err != nil {
+		return err
+	}
+	c := *v
+	c.SecretAccessKey = querier.N(secretAccessKey)
+	return querier.Create(q, &c)
 }
 
+// Create stores the rustic config with an encrypted password. v itself keeps the plaintext password.
 func (v *RepositoryBackupRustic) Create(q *querier.Querier) error {
-	return querier.Create(q, v)
+	password, err := encryptSecret(v.Password.V)
+	if err != nil {
+		return err
+	}
+	c := *v
+	c.Password = querier.N(password)
+	return querier.Create(q, &c)
 }
 
 func GetRepositoryAccessesById(q *querier.Querier, id int64) ([]RepositoryAccess, error) {
@@ -75,9 +134,16 @@ func postprocessRepository(q *querier.Querier, w *Repository) (*Repository, erro
 	}
 
 	w.Access = ras
+
+	err = decryptRepositorySecrets(w)
+	if err != nil {
+		return nil, err
+	}
 	return w, nil
 }
 
+// ListRepositories returns the repositories the user has access to, either directly or through the membership in an
+// organization. If userId is nil, all repositories are returned.
 func ListRepositories(q *querier.Querier, userId *string, skipDeleted bool) ([]Repository, error) {
 	rasWhere, rasWhereArgs, err := querier.BuildWhere[RepositoryAccess](map[string]any{
 		"user_id": querier.OmitIfNull(userId),
@@ -95,22 +161,45 @@ func ListRepositories(q *querier.Querier, userId *string, skipDeleted bool) ([]R
 		rasMap[wa.RepositoryId] = append(rasMap[wa.RepositoryId], wa)
 	}
 
-	if rasWhere != "" {
-		rasWhere = "where " + rasWhere
-	}
 	var whereClauses []string
-	whereClauses = append(whereClauses, fmt.Sprintf("repository.id in (select repository_id from repository_access %s)", rasWhere))
+	args := map[string]any{}
+	if userId != nil {
+		whereClauses = append(whereClauses, "(repository.id in (select repository_id from repository_access where user_id = :user_id) or "+
+			"repository.organization_id in (select organization_id from organization_member where user_id = :user_id))")
+		args["user_id"] = *userId
+	}
 	if skipDeleted {
 		whereClauses = append(whereClauses, "deleted_at is null")
 	}
 	where := strings.Join(whereClauses, " and ")
-	l, err := querier.GetManyWhere[Repository](q, where, rasWhereArgs)
+	l, err := querier.GetManyWhere[Repository](q, where, args)
 	if err != nil {
 		return nil, err
 	}
 
 	for i, x := range l {
 		l[i].Access = rasMap[x.ID]
+		err = decryptRepositorySecrets(&l[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func ListRepositoriesByOrganization(q *querier.Querier, organizationId int64, skipDeleted bool) ([]Repository, error) {
+	l, err := querier.GetMany[Repository](q, map[string]any{
+		"organization_id": organizationId,
+		"deleted_at":      querier.ExcludeNonNull(skipDeleted),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range l {
+		err = decryptRepositorySecrets(&l[i])
+		if err != nil {
+			return nil, err
+		}
 	}
 	return l, nil
 }
@@ -126,15 +215,43 @@ func GetRepositoryById(q *querier.Querier, id int64, skipDeleted bool) (*Reposit
 	return postprocessRepository(q, r)
 }
 
-func GetRepositoryByName(q *querier.Querier, name string, skipDeleted bool) (*Repository, error) {
-	r, err := querier.GetOne[Repository](q, map[string]any{
-		"name":       name,
-		"deleted_at": querier.ExcludeNonNull(skipDeleted),
-	})
+// GetRepositoryByName looks up a repository by its name, which is unique per organization. Repositories without
+// an organization are looked up when organizationId is nil.
+func GetRepositoryByName(q *querier.Querier, organizationId *int64, name string, skipDeleted bool) (*Repository, error) {
+	where := "name = :name and organization_id is null"
+	args := map[string]any{
+		"name": name,
+	}
+	if organizationId != nil {
+		where = "name = :name and organization_id = :organization_id"
+		args["organization_id"] = *organizationId
+	}
+	if skipDeleted {
+		where += " and deleted_at is null"
+	}
+	l, err := querier.GetManyWhere[Repository](q, where, args)
 	if err != nil {
 		return nil, err
 	}
-	return postprocessRepository(q, r)
+	if len(l) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return postprocessRepository(q, &l[0])
+}
+
+func (v *Repository) UpdatePruneLock(q *querier.Querier, newLockId *string, newLockTime *int64) error {
+	oldLockId := v.PruneLockId
+	oldLockTime := v.PruneLockTime
+	v.PruneLockId = newLockId
+	v.PruneLockTime = newLockTime
+	return querier.UpdateOneByFields[Repository](q, map[string]any{
+		"id":              v.ID,
+		"prune_lock_id":   oldLockId,
+		"prune_lock_time": oldLockTime,
+	}, map[string]any{
+		"prune_lock_id":   v.PruneLockId,
+		"prune_lock_time": v.PruneLockTime,
+	})
 }
 
 func (v *RepositoryStorageS3) UpdateEndpoint(q *querier.Querier, endpoint string) error {
@@ -166,17 +283,38 @@ func (v *RepositoryStorageS3) UpdatePrefix(q *querier.Querier, prefix string) er
 }
 
 func (v *RepositoryStorageS3) UpdateKeys(q *querier.Querier, accessKeyId string, secretAccessKey string) error {
-	v.AccessKeyId = querier.N(accessKeyId)
-	v.SecretAccessKey = querier.N(secretAccessKey)
-	return querier.UpdateOneFromStruct(q, v,
+	encrypted, err := encryptSecret(secretAccessKey)
+	if err != nil {
+		return err
+	}
+	c := *v
+	c.AccessKeyId = querier.N(accessKeyId)
+	c.SecretAccessKey = querier.N(encrypted)
+	err = querier.UpdateOneFromStruct(q, &c,
 		"access_key_id",
 		"secret_access_key",
 	)
+	if err != nil {
+		return err
+	}
+	v.AccessKeyId = querier.N(accessKeyId)
+	v.SecretAccessKey = querier.N(secretAccessKey)
+	return nil
 }
 
 func (v *RepositoryBackupRustic) UpdatePassword(q *querier.Querier, password string) error {
-	v.Password = querier.N(password)
-	return querier.UpdateOneFromStruct(q, v,
+	encrypted, err := encryptSecret(password)
+	if err != nil {
+		return err
+	}
+	c := *v
+	c.Password = querier.N(encrypted)
+	err = querier.UpdateOneFromStruct(q, &c,
 		"password",
 	)
+	if err != nil {
+		return err
+	}
+	v.Password = querier.N(password)
+	return nil
 }
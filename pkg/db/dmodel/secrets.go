@@ -0,0 +1,110 @@
+package dmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/dboxed/dboxed-volume/pkg/secrets"
+	"github.com/jmoiron/sqlx"
+)
+
+var secretsKeyring *secrets.Keyring
+
+// SetSecretsKeyring sets the keyring used to encrypt and decrypt secrets stored in the database. It must be called
+// before any repository or volume is created or loaded. Without a keyring, secrets are stored in plaintext, which
+// allows upgrading servers that don't have a master key configured yet.
+func SetSecretsKeyring(k *secrets.Keyring) {
+	secretsKeyring = k
+}
+
+func encryptSecret(s string) (string, error) {
+	if secretsKeyring == nil {
+		return s, nil
+	}
+	return secretsKeyring.Encrypt(s)
+}
+
+func decryptSecret(s string) (string, error) {
+	if secretsKeyring == nil {
+		if secrets.IsEncrypted(s) {
+			return "", fmt.Errorf("value is encrypted, but no master key is configured")
+		}
+		return s, nil
+	}
+	return secretsKeyring.Decrypt(s)
+}
+
+func decryptRepositorySecrets(r *Repository) error {
+	if r.S3 != nil {
+		s, err := decryptSecret(r.S3.SecretAccessKey.V)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret access key of repository %d: %w", r.ID, err)
+		}
+		r.S3.SecretAccessKey = querier.N(s)
+	}
+	if r.Rustic != nil {
+		s, err := decryptSecret(r.Rustic.Password.V)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt rustic password of repository %d: %w", r.ID, err)
+		}
+		r.Rustic.Password = querier.N(s)
+	}
+	return nil
+}
+
+var secretColumns = []struct {
+	table  string
+	column string
+}{
+	{"repository_storage_s3", "secret_access_key"},
+	{"repository_backup_rustic", "password"},
+	{"volume", "encryption_key"},
+}
+
+// ReencryptSecrets encrypts all plaintext secrets. If rotate is true, secrets that were encrypted with a previous
+// master key are re-wrapped with the current master key as well. It returns the number of updated values.
+func ReencryptSecrets(ctx context.Context, db *sqlx.DB, k *secrets.Keyring, rotate bool) (int, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	cnt := 0
+	for _, c := range secretColumns {
+		var l []struct {
+			ID    int64  `db:"id"`
+			Value string `db:"value"`
+		}
+		err = tx.SelectContext(ctx, &l, fmt.Sprintf("select id, %s as value from %s where %s is not null", c.column, c.table, c.column))
+		if err != nil {
+			return 0, err
+		}
+		for _, x := range l {
+			if secrets.IsEncrypted(x.Value) && !rotate {
+				continue
+			}
+			newValue, changed, err := k.Rewrap(x.Value)
+			if err != nil {
+				return 0, fmt.Errorf("failed to re-encrypt %s.%s of row %d: %w", c.table, c.column, x.ID, err)
+			}
+			if !changed {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, tx.Rebind(fmt.Sprintf("update %s set %s = ? where id = ?", c.table, c.column)), newValue, x.ID)
+			if err != nil {
+				return 0, err
+			}
+			cnt++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
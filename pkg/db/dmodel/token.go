@@ -1,38 +1,262 @@
 package dmodel
 
-import "github.com/dboxed/dboxed-common/db/querier"
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	TokenScopeReadOnly = "read-only"
+	TokenScopeServe    = "serve"
+	TokenScopeAdmin    = "admin"
+)
+
+var tokenScopeRoles = map[string]string{
+	TokenScopeReadOnly: RepositoryRoleViewer,
+	TokenScopeServe:    RepositoryRoleOperator,
+	TokenScopeAdmin:    RepositoryRoleOwner,
+}
+
+func IsValidTokenScope(scope string) bool {
+	_, ok := tokenScopeRoles[scope]
+	return ok
+}
+
+// tokenPrefixLen is the length of the public token prefix (including "dvt_"), which is stored in plaintext to find the
+// token before its hash is compared
+const tokenPrefixLen = 12
 
 type Token struct {
 	ID int64 `db:"id" omitCreate:"true"`
 	Times
 
-	Token string `db:"token"`
+	TokenPrefix string `db:"token_prefix"`
+	TokenSalt   string `db:"token_salt"`
+	TokenHash   string `db:"token_hash"`
 
 	Name   string `db:"name"`
 	UserID string `db:"user_id"`
 
+	Scope             string     `db:"scope"`
+	RestrictResources bool       `db:"restrict_resources"`
+	ExpiresAt         *time.Time `db:"expires_at"`
+
+	LastUsedAt *time.Time `db:"last_used_at"`
+	LastUsedIp *string    `db:"last_used_ip"`
+
 	User *User `join:"true" join_left_field:"user_id"`
+
+	Resources []TokenResource
+}
+
+// TokenResource limits a token with RestrictResources to a repository or to a single volume of a repository
+type TokenResource struct {
+	TokenID      int64  `db:"token_id"`
+	RepositoryID int64  `db:"repository_id"`
+	VolumeID     *int64 `db:"volume_id"`
 }
 
 func (v *Token) Create(q *querier.Querier) error {
-	return querier.Create(q, v)
+	err := querier.Create(q, v)
+	if err != nil {
+		return err
+	}
+	for i := range v.Resources {
+		v.Resources[i].TokenID = v.ID
+		err = querier.Create(q, &v.Resources[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func postprocessToken(q *querier.Querier, t *Token) (*Token, error) {
+	resources, err := querier.GetMany[TokenResource](q, map[string]any{
+		"token_id": t.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Resources = resources
+	return t, nil
 }
 
 func GetTokenById(q *querier.Querier, userId *string, id int64) (*Token, error) {
-	return querier.GetOne[Token](q, map[string]any{
+	t, err := querier.GetOne[Token](q, map[string]any{
 		"id":      id,
 		"user_id": querier.OmitIfNull(userId),
 	})
+	if err != nil {
+		return nil, err
+	}
+	return postprocessToken(q, t)
 }
 
+// GetTokenByToken finds the token by its prefix and verifies the hash in constant time
 func GetTokenByToken(q *querier.Querier, token string) (*Token, error) {
-	return querier.GetOne[Token](q, map[string]any{
-		"token": token,
+	if len(token) < tokenPrefixLen {
+		return nil, sql.ErrNoRows
+	}
+	l, err := querier.GetMany[Token](q, map[string]any{
+		"token_prefix": token[:tokenPrefixLen],
 	})
+	if err != nil {
+		return nil, err
+	}
+	t := findToken(l, token)
+	if t == nil {
+		return nil, sql.ErrNoRows
+	}
+	return postprocessToken(q, t)
+}
+
+// findToken returns the candidate matching the token. Prefixes are not unique, so all candidates with the same
+// prefix must be checked.
+func findToken(candidates []Token, token string) *Token {
+	for i := range candidates {
+		if candidates[i].CheckToken(token) {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// SetToken stores the prefix and a salted hash of the token. The token itself is never stored.
+func (v *Token) SetToken(token string) error {
+	if len(token) < tokenPrefixLen {
+		return fmt.Errorf("token is too short")
+	}
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	v.TokenPrefix = token[:tokenPrefixLen]
+	v.TokenSalt = hex.EncodeToString(salt)
+	v.TokenHash = HashToken(v.TokenSalt, token)
+	return nil
+}
+
+func (v *Token) CheckToken(token string) bool {
+	h := HashToken(v.TokenSalt, token)
+	return subtle.ConstantTimeCompare([]byte(h), []byte(v.TokenHash)) == 1
+}
+
+// HashToken is sufficient for tokens, as they are random and long enough to make brute forcing infeasible
+func HashToken(salt string, token string) string {
+	h := sha256.Sum256([]byte(salt + token))
+	return hex.EncodeToString(h[:])
 }
 
 func ListTokensForUser(q *querier.Querier, userId string) ([]Token, error) {
-	return querier.GetMany[Token](q, map[string]any{
+	l, err := querier.GetMany[Token](q, map[string]any{
 		"user_id": userId,
 	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range l {
+		_, err = postprocessToken(q, &l[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (v *Token) UpdateLastUsed(q *querier.Querier, t time.Time, ip string) error {
+	v.LastUsedAt = &t
+	v.LastUsedIp = &ip
+	return querier.UpdateOneFromStruct(q, v,
+		"last_used_at",
+		"last_used_ip",
+	)
+}
+
+func (v *Token) IsExpired() bool {
+	return v.ExpiresAt != nil && !time.Now().Before(*v.ExpiresAt)
+}
+
+// MaxRepositoryRole returns the highest repository role the token can act with, regardless of the roles of its user
+func (v *Token) MaxRepositoryRole() string {
+	return tokenScopeRoles[v.Scope]
+}
+
+// IsUnrestricted returns true if the token has the full rights of its user
+func (v *Token) IsUnrestricted() bool {
+	return v.Scope == TokenScopeAdmin && !v.RestrictResources
+}
+
+// AllowsRepository returns true if the token can access the repository or at least one of its volumes
+func (v *Token) AllowsRepository(repositoryId int64) bool {
+	if !v.RestrictResources {
+		return true
+	}
+	for _, r := range v.Resources {
+		if r.RepositoryID == repositoryId {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAllVolumes returns true if the token is not limited to single volumes of the repository
+func (v *Token) AllowsAllVolumes(repositoryId int64) bool {
+	if !v.RestrictResources {
+		return true
+	}
+	for _, r := range v.Resources {
+		if r.RepositoryID == repositoryId && r.VolumeID == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Token) AllowsVolume(repositoryId int64, volumeId int64) bool {
+	if v.AllowsAllVolumes(repositoryId) {
+		return true
+	}
+	for _, r := range v.Resources {
+		if r.RepositoryID == repositoryId && r.VolumeID != nil && *r.VolumeID == volumeId {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateTokenHashes replaces plaintext tokens from before tokens were hashed with their prefix and salted hash. It is
+// idempotent and runs on every server start, so that it also happens when migrations are applied out of band.
+func MigrateTokenHashes(ctx context.Context, db *sqlx.DB) error {
+	var l []struct {
+		ID    int64  `db:"id"`
+		Token string `db:"token"`
+	}
+	err := db.SelectContext(ctx, &l, "select id, token from token where token is not null and token_hash is null")
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		var t Token
+		err = t.SetToken(x.Token)
+		if err != nil {
+			return fmt.Errorf("failed to hash token %d: %w", x.ID, err)
+		}
+		_, err = db.ExecContext(ctx, db.Rebind("update token set token_prefix = ?, token_salt = ?, token_hash = ?, token = null where id = ?"),
+			t.TokenPrefix, t.TokenSalt, t.TokenHash, x.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
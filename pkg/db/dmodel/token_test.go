@@ -0,0 +1,235 @@
+package dmodel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const testToken = "dvt_0123456789abcdef"
+
+func TestSetAndCheckToken(t *testing.T) {
+	var tok Token
+	err := tok.SetToken(testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.TokenPrefix != testToken[:tokenPrefixLen] {
+		t.Fatalf("unexpected prefix %q", tok.TokenPrefix)
+	}
+	if tok.TokenHash == "" || tok.TokenHash == testToken || tok.TokenSalt == "" {
+		t.Fatal("token was not hashed")
+	}
+	if !tok.CheckToken(testToken) {
+		t.Fatal("token does not match its own hash")
+	}
+	if tok.CheckToken(testToken + "x") {
+		t.Fatal("wrong token matched")
+	}
+	if tok.CheckToken("") {
+		t.Fatal("empty token matched")
+	}
+
+	var tok2 Token
+	err = tok2.SetToken(testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.TokenSalt == tok2.TokenSalt || tok.TokenHash == tok2.TokenHash {
+		t.Fatal("tokens must be hashed with a random salt")
+	}
+
+	err = tok.SetToken("dvt_short")
+	if err == nil {
+		t.Fatal("short token was accepted")
+	}
+}
+
+func TestFindTokenSharedPrefix(t *testing.T) {
+	other := testToken[:tokenPrefixLen] + "ffffffff"
+
+	var t1, t2 Token
+	t1.ID = 1
+	t2.ID = 2
+	err := t1.SetToken(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = t2.SetToken(testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1.TokenPrefix != t2.TokenPrefix {
+		t.Fatal("test tokens must share the prefix")
+	}
+
+	candidates := []Token{t1, t2}
+	found := findToken(candidates, testToken)
+	if found == nil || found.ID != 2 {
+		t.Fatalf("expected token 2, got %v", found)
+	}
+	found = findToken(candidates, other)
+	if found == nil || found.ID != 1 {
+		t.Fatalf("expected token 1, got %v", found)
+	}
+	if findToken(candidates, testToken[:tokenPrefixLen]+"00000000") != nil {
+		t.Fatal("wrong token with a shared prefix matched")
+	}
+}
+
+func TestMigrateTokenHashes(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`create table token (
+		id integer primary key,
+		token text unique,
+		token_prefix text,
+		token_salt text,
+		token_hash text
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var migrated Token
+	err = migrated.SetToken("dvt_already-hashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into token (id, token) values (1, ?), (2, ?)", testToken, "dvt_fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("insert into token (id, token_prefix, token_salt, token_hash) values (3, ?, ?, ?)",
+		migrated.TokenPrefix, migrated.TokenSalt, migrated.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateTokenHashes(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []struct {
+		ID          int64   `db:"id"`
+		Token       *string `db:"token"`
+		TokenPrefix string  `db:"token_prefix"`
+		TokenSalt   string  `db:"token_salt"`
+		TokenHash   string  `db:"token_hash"`
+	}
+	err = db.Select(&rows, "select id, token, token_prefix, token_salt, token_hash from token order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int64]string{
+		1: testToken,
+		2: "dvt_fedcba9876543210",
+		3: "dvt_already-hashed",
+	}
+	for _, r := range rows {
+		if r.Token != nil {
+			t.Fatalf("plaintext token of row %d was not cleared", r.ID)
+		}
+		tok := Token{TokenPrefix: r.TokenPrefix, TokenSalt: r.TokenSalt, TokenHash: r.TokenHash}
+		if !tok.CheckToken(expected[r.ID]) {
+			t.Fatalf("row %d does not match its original token", r.ID)
+		}
+	}
+	if rows[2].TokenHash != migrated.TokenHash {
+		t.Fatal("already migrated token was changed")
+	}
+
+	// running it again must be a no-op
+	err = MigrateTokenHashes(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenMaxRepositoryRole(t *testing.T) {
+	tests := []struct {
+		scope string
+		role  string
+	}{
+		{TokenScopeReadOnly, RepositoryRoleViewer},
+		{TokenScopeServe, RepositoryRoleOperator},
+		{TokenScopeAdmin, RepositoryRoleOwner},
+		{"invalid", ""},
+	}
+	for _, tt := range tests {
+		tok := Token{Scope: tt.scope}
+		if got := tok.MaxRepositoryRole(); got != tt.role {
+			t.Fatalf("scope %s: got role %q, want %q", tt.scope, got, tt.role)
+		}
+	}
+
+	if !RepositoryRoleAllows(RepositoryRoleOwner, RepositoryRoleOperator) ||
+		!RepositoryRoleAllows(RepositoryRoleOperator, RepositoryRoleOperator) ||
+		RepositoryRoleAllows(RepositoryRoleViewer, RepositoryRoleOperator) ||
+		RepositoryRoleAllows("", RepositoryRoleViewer) {
+		t.Fatal("unexpected RepositoryRoleAllows result")
+	}
+}
+
+func TestTokenIsUnrestricted(t *testing.T) {
+	if !(&Token{Scope: TokenScopeAdmin}).IsUnrestricted() {
+		t.Fatal("admin token without resource restrictions must be unrestricted")
+	}
+	if (&Token{Scope: TokenScopeAdmin, RestrictResources: true}).IsUnrestricted() {
+		t.Fatal("token with resource restrictions must not be unrestricted")
+	}
+	if (&Token{Scope: TokenScopeServe}).IsUnrestricted() {
+		t.Fatal("serve token must not be unrestricted")
+	}
+}
+
+func TestTokenAllowsResources(t *testing.T) {
+	volumeId := int64(10)
+	unrestricted := Token{Scope: TokenScopeServe}
+	restricted := Token{
+		Scope:             TokenScopeServe,
+		RestrictResources: true,
+		Resources: []TokenResource{
+			{RepositoryID: 1},
+			{RepositoryID: 2, VolumeID: &volumeId},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		token      Token
+		repository int64
+		volume     int64
+		repo       bool
+		allVolumes bool
+		vol        bool
+	}{
+		{"unrestricted", unrestricted, 3, 30, true, true, true},
+		{"whole repository", restricted, 1, 30, true, true, true},
+		{"single volume", restricted, 2, volumeId, true, false, true},
+		{"other volume", restricted, 2, 30, true, false, false},
+		{"other repository", restricted, 3, volumeId, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.AllowsRepository(tt.repository); got != tt.repo {
+				t.Fatalf("AllowsRepository = %v, want %v", got, tt.repo)
+			}
+			if got := tt.token.AllowsAllVolumes(tt.repository); got != tt.allVolumes {
+				t.Fatalf("AllowsAllVolumes = %v, want %v", got, tt.allVolumes)
+			}
+			if got := tt.token.AllowsVolume(tt.repository, tt.volume); got != tt.vol {
+				t.Fatalf("AllowsVolume = %v, want %v", got, tt.vol)
+			}
+		})
+	}
+}
@@ -21,6 +21,14 @@ func GetUserById(q *querier.Querier, id string) (*User, error) {
 	})
 }
 
+// ListUsersByEmail returns all users with the given email. Emails are neither unique nor verified across OIDC issuers,
+// so callers must handle multiple users.
+func ListUsersByEmail(q *querier.Querier, email string) ([]User, error) {
+	return querier.GetMany[User](q, map[string]any{
+		"email": email,
+	})
+}
+
 func (v *User) CreateOrUpdate(q *querier.Querier) error {
 	return querier.CreateOrUpdate(q, v, "id")
 }
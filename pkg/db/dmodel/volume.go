@@ -1,6 +1,7 @@
 package dmodel
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/dboxed/dboxed-common/db/querier"
@@ -18,8 +19,64 @@ type Volume struct {
 	FsSize int64  `db:"fs_size"`
 	FsType string `db:"fs_type"`
 
+	Encryption    string  `db:"encryption"`
+	EncryptionKey *string `db:"encryption_key"`
+
 	LockId   *string `db:"lock_id"`
 	LockTime *int64  `db:"lock_time"`
+	LockTtl  int64   `db:"lock_ttl"`
+
+	LockUserId        *string `db:"lock_user_id"`
+	LockHostname      *string `db:"lock_hostname"`
+	LockPid           *int64  `db:"lock_pid"`
+	LockClientVersion *string `db:"lock_client_version"`
+	LockLabel         *string `db:"lock_label"`
+
+	LockBrokenBy     *string    `db:"lock_broken_by"`
+	LockBrokenAt     *time.Time `db:"lock_broken_at"`
+	LockBrokenReason *string    `db:"lock_broken_reason"`
+
+	LastBackupAt *time.Time `db:"last_backup_at"`
+
+	KeepLast    *int64 `db:"keep_last"`
+	KeepHourly  *int64 `db:"keep_hourly"`
+	KeepDaily   *int64 `db:"keep_daily"`
+	KeepWeekly  *int64 `db:"keep_weekly"`
+	KeepMonthly *int64 `db:"keep_monthly"`
+
+	SnapshotFreeze      bool    `db:"snapshot_freeze"`
+	PreSnapshotHook     *string `db:"pre_snapshot_hook"`
+	PostSnapshotHook    *string `db:"post_snapshot_hook"`
+	SnapshotHookTimeout *int64  `db:"snapshot_hook_timeout"`
+}
+
+type VolumeLockHolder struct {
+	UserId        *string
+	Hostname      *string
+	Pid           *int64
+	ClientVersion *string
+	Label         *string
+}
+
+// SetEncryptionKey stores the server-side encryption key encrypted with the master key
+func (v *Volume) SetEncryptionKey(key string) error {
+	encrypted, err := encryptSecret(key)
+	if err != nil {
+		return err
+	}
+	v.EncryptionKey = &encrypted
+	return nil
+}
+
+func (v *Volume) GetEncryptionKey() (string, error) {
+	if v.EncryptionKey == nil {
+		return "", fmt.Errorf("volume has no server-side encryption key")
+	}
+	key, err := decryptSecret(*v.EncryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt encryption key of volume %d: %w", v.ID, err)
+	}
+	return key, nil
 }
 
 func (v *Volume) Create(q *querier.Querier) error {
@@ -56,17 +113,140 @@ func ListVolumesForRepository(q *querier.Querier, repositoryId int64, skipDelete
 	})
 }
 
-func (v *Volume) UpdateLock(q *querier.Querier, newLockId string, newLockTime time.Time) error {
+func (v *Volume) UpdateLock(q *querier.Querier, newLockId string, newLockTime time.Time, holder VolumeLockHolder) error {
 	oldLockId := v.LockId
 	oldLockTime := v.LockTime
 	v.LockId = &newLockId
 	v.LockTime = util.Ptr(newLockTime.Unix())
+	v.setLockHolder(holder)
+	return querier.UpdateOneByFields[Volume](q, map[string]any{
+		"id":        v.ID,
+		"lock_id":   oldLockId,
+		"lock_time": oldLockTime,
+	}, map[string]any{
+		"lock_id":             v.LockId,
+		"lock_time":           v.LockTime,
+		"lock_user_id":        v.LockUserId,
+		"lock_hostname":       v.LockHostname,
+		"lock_pid":            v.LockPid,
+		"lock_client_version": v.LockClientVersion,
+		"lock_label":          v.LockLabel,
+	})
+}
+
+func (v *Volume) ClearLock(q *querier.Querier) error {
+	oldLockId := v.LockId
+	oldLockTime := v.LockTime
+	v.LockId = nil
+	v.LockTime = nil
+	v.setLockHolder(VolumeLockHolder{})
+	return querier.UpdateOneByFields[Volume](q, map[string]any{
+		"id":        v.ID,
+		"lock_id":   oldLockId,
+		"lock_time": oldLockTime,
+	}, map[string]any{
+		"lock_id":             nil,
+		"lock_time":           nil,
+		"lock_user_id":        nil,
+		"lock_hostname":       nil,
+		"lock_pid":            nil,
+		"lock_client_version": nil,
+		"lock_label":          nil,
+	})
+}
+
+func (v *Volume) GetLockHolder() VolumeLockHolder {
+	return VolumeLockHolder{
+		UserId:        v.LockUserId,
+		Hostname:      v.LockHostname,
+		Pid:           v.LockPid,
+		ClientVersion: v.LockClientVersion,
+		Label:         v.LockLabel,
+	}
+}
+
+func (v *Volume) setLockHolder(holder VolumeLockHolder) {
+	v.LockUserId = holder.UserId
+	v.LockHostname = holder.Hostname
+	v.LockPid = holder.Pid
+	v.LockClientVersion = holder.ClientVersion
+	v.LockLabel = holder.Label
+}
+
+func (v *Volume) UpdateLastBackupAt(q *querier.Querier, lastBackupAt time.Time) error {
+	v.LastBackupAt = &lastBackupAt
+	return querier.UpdateOneFromStruct(q, v,
+		"last_backup_at",
+	)
+}
+
+func (v *Volume) UpdateFsSize(q *querier.Querier, fsSize int64) error {
+	v.FsSize = fsSize
+	return querier.UpdateOneFromStruct(q, v,
+		"fs_size",
+	)
+}
+
+func (v *Volume) UpdateLockTtl(q *querier.Querier, lockTtl int64) error {
+	v.LockTtl = lockTtl
+	return querier.UpdateOneFromStruct(q, v,
+		"lock_ttl",
+	)
+}
+
+func (v *Volume) UpdateRetention(q *querier.Querier, keepLast *int64, keepHourly *int64, keepDaily *int64, keepWeekly *int64, keepMonthly *int64) error {
+	v.KeepLast = keepLast
+	v.KeepHourly = keepHourly
+	v.KeepDaily = keepDaily
+	v.KeepWeekly = keepWeekly
+	v.KeepMonthly = keepMonthly
+	return querier.UpdateOneFromStruct(q, v,
+		"keep_last",
+		"keep_hourly",
+		"keep_daily",
+		"keep_weekly",
+		"keep_monthly",
+	)
+}
+
+// BreakLock removes the lock regardless of who holds it and records who broke it and why. The lock is only broken if
+// it was not changed since the volume was loaded, otherwise a not found error is returned.
+func (v *Volume) BreakLock(q *querier.Querier, brokenBy string, reason string) error {
+	oldLockId := v.LockId
+	oldLockTime := v.LockTime
+	v.LockId = nil
+	v.LockTime = nil
+	v.setLockHolder(VolumeLockHolder{})
+	v.LockBrokenBy = &brokenBy
+	v.LockBrokenAt = util.Ptr(time.Now())
+	v.LockBrokenReason = &reason
 	return querier.UpdateOneByFields[Volume](q, map[string]any{
 		"id":        v.ID,
 		"lock_id":   oldLockId,
 		"lock_time": oldLockTime,
 	}, map[string]any{
-		"lock_id":   v.LockId,
-		"lock_time": v.LockTime,
+		"lock_id":             nil,
+		"lock_time":           nil,
+		"lock_user_id":        nil,
+		"lock_hostname":       nil,
+		"lock_pid":            nil,
+		"lock_client_version": nil,
+		"lock_label":          nil,
+		"lock_broken_by":      v.LockBrokenBy,
+		"lock_broken_at":      v.LockBrokenAt,
+		"lock_broken_reason":  v.LockBrokenReason,
 	})
 }
+
+func (v *Volume) UpdateSnapshotHooks(q *querier.Querier, freeze bool, preSnapshotHook *string, postSnapshotHook *string, timeout *int64) error {
+	v.SnapshotFreeze = freeze
+	v.PreSnapshotHook = preSnapshotHook
+	v.PostSnapshotHook = postSnapshotHook
+	v.SnapshotHookTimeout = timeout
+	return querier.UpdateOneFromStruct(q, v,
+		"snapshot_freeze",
+		"pre_snapshot_hook",
+		"post_snapshot_hook",
+		"snapshot_hook_timeout",
+	)
+}
@@ -0,0 +1,51 @@
+package dmodel
+
+import (
+	"time"
+
+	"github.com/dboxed/dboxed-common/db/querier"
+)
+
+type VolumeBackup struct {
+	ID int64 `db:"id" omitCreate:"true"`
+	Times
+
+	VolumeID int64 `db:"volume_id"`
+
+	SnapshotId   string    `db:"snapshot_id"`
+	SnapshotTime time.Time `db:"snapshot_time"`
+	Hostname     string    `db:"hostname"`
+	DurationMs   int64     `db:"duration_ms"`
+
+	TotalFiles      int64 `db:"total_files"`
+	TotalBytes      int64 `db:"total_bytes"`
+	FilesNew        int64 `db:"files_new"`
+	FilesChanged    int64 `db:"files_changed"`
+	FilesUnmodified int64 `db:"files_unmodified"`
+	DirsNew         int64 `db:"dirs_new"`
+	DirsChanged     int64 `db:"dirs_changed"`
+	DirsUnmodified  int64 `db:"dirs_unmodified"`
+	DataAdded       int64 `db:"data_added"`
+	DataAddedPacked int64 `db:"data_added_packed"`
+
+	Frozen                bool    `db:"frozen"`
+	PreSnapshotHookError  *string `db:"pre_snapshot_hook_error"`
+	PostSnapshotHookError *string `db:"post_snapshot_hook_error"`
+}
+
+func (v *VolumeBackup) Create(q *querier.Querier) error {
+	return querier.Create(q, v)
+}
+
+func GetVolumeBackupById(q *querier.Querier, volumeId int64, id int64) (*VolumeBackup, error) {
+	return querier.GetOne[VolumeBackup](q, map[string]any{
+		"volume_id": volumeId,
+		"id":        id,
+	})
+}
+
+func ListVolumeBackupsForVolume(q *querier.Querier, volumeId int64) ([]VolumeBackup, error) {
+	return querier.GetMany[VolumeBackup](q, map[string]any{
+		"volume_id": volumeId,
+	})
+}
@@ -0,0 +1,53 @@
+package dmodel
+
+import (
+	"github.com/dboxed/dboxed-common/db/querier"
+)
+
+const (
+	VolumeLockEventAcquired  = "acquired"
+	VolumeLockEventRefreshed = "refreshed"
+	VolumeLockEventExpired   = "expired"
+	VolumeLockEventStolen    = "stolen"
+	VolumeLockEventReleased  = "released"
+)
+
+type VolumeLockEvent struct {
+	ID int64 `db:"id" omitCreate:"true"`
+	Times
+
+	VolumeID int64 `db:"volume_id"`
+
+	Event  string `db:"event"`
+	LockId string `db:"lock_id"`
+
+	UserId        *string `db:"user_id"`
+	Hostname      *string `db:"hostname"`
+	Pid           *int64  `db:"pid"`
+	ClientVersion *string `db:"client_version"`
+	Label         *string `db:"label"`
+	Reason        *string `db:"reason"`
+}
+
+func NewVolumeLockEvent(volumeId int64, event string, lockId string, holder VolumeLockHolder) *VolumeLockEvent {
+	return &VolumeLockEvent{
+		VolumeID:      volumeId,
+		Event:         event,
+		LockId:        lockId,
+		UserId:        holder.UserId,
+		Hostname:      holder.Hostname,
+		Pid:           holder.Pid,
+		ClientVersion: holder.ClientVersion,
+		Label:         holder.Label,
+	}
+}
+
+func (v *VolumeLockEvent) Create(q *querier.Querier) error {
+	return querier.Create(q, v)
+}
+
+func ListVolumeLockEventsForVolume(q *querier.Querier, volumeId int64) ([]VolumeLockEvent, error) {
+	return querier.GetMany[VolumeLockEvent](q, map[string]any{
+		"volume_id": volumeId,
+	})
+}
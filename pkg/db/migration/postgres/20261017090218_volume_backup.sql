@@ -0,0 +1,32 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "last_backup_at" timestamptz NULL;
+-- create "volume_backup" table
+CREATE TABLE "volume_backup" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "volume_id" bigint NOT NULL,
+  "snapshot_id" text NOT NULL,
+  "snapshot_time" timestamptz NOT NULL,
+  "hostname" text NOT NULL,
+  "duration_ms" bigint NOT NULL,
+  "total_files" bigint NOT NULL,
+  "total_bytes" bigint NOT NULL,
+  "files_new" bigint NOT NULL,
+  "files_changed" bigint NOT NULL,
+  "files_unmodified" bigint NOT NULL,
+  "dirs_new" bigint NOT NULL,
+  "dirs_changed" bigint NOT NULL,
+  "dirs_unmodified" bigint NOT NULL,
+  "data_added" bigint NOT NULL,
+  "data_added_packed" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "volume_backup_volume_id_snapshot_id_key" UNIQUE ("volume_id", "snapshot_id"),
+  CONSTRAINT "volume_backup_volume_id_fkey" FOREIGN KEY ("volume_id") REFERENCES "volume" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- +goose Down
+-- reverse: create "volume_backup" table
+DROP TABLE "volume_backup";
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "last_backup_at";
@@ -0,0 +1,11 @@
+-- +goose Up
+-- modify "repository" table
+ALTER TABLE "repository" ADD COLUMN "prune_lock_id" text NULL, ADD COLUMN "prune_lock_time" bigint NULL;
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "keep_last" bigint NULL, ADD COLUMN "keep_hourly" bigint NULL, ADD COLUMN "keep_daily" bigint NULL, ADD COLUMN "keep_weekly" bigint NULL, ADD COLUMN "keep_monthly" bigint NULL;
+
+-- +goose Down
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "keep_monthly", DROP COLUMN "keep_weekly", DROP COLUMN "keep_daily", DROP COLUMN "keep_hourly", DROP COLUMN "keep_last";
+-- reverse: modify "repository" table
+ALTER TABLE "repository" DROP COLUMN "prune_lock_time", DROP COLUMN "prune_lock_id";
@@ -0,0 +1,7 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "lock_broken_by" text NULL, ADD COLUMN "lock_broken_at" timestamptz NULL, ADD COLUMN "lock_broken_reason" text NULL;
+
+-- +goose Down
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "lock_broken_reason", DROP COLUMN "lock_broken_at", DROP COLUMN "lock_broken_by";
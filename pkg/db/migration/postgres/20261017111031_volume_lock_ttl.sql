@@ -0,0 +1,7 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "lock_ttl" bigint NOT NULL DEFAULT 300;
+
+-- +goose Down
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "lock_ttl";
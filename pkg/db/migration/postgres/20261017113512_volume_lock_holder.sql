@@ -0,0 +1,25 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "lock_user_id" text NULL, ADD COLUMN "lock_hostname" text NULL, ADD COLUMN "lock_pid" bigint NULL, ADD COLUMN "lock_client_version" text NULL, ADD COLUMN "lock_label" text NULL;
+-- create "volume_lock_event" table
+CREATE TABLE "volume_lock_event" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "volume_id" bigint NOT NULL,
+  "event" text NOT NULL,
+  "lock_id" text NOT NULL,
+  "user_id" text NULL,
+  "hostname" text NULL,
+  "pid" bigint NULL,
+  "client_version" text NULL,
+  "label" text NULL,
+  "reason" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "volume_lock_event_volume_id_fkey" FOREIGN KEY ("volume_id") REFERENCES "volume" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- +goose Down
+-- reverse: create "volume_lock_event" table
+DROP TABLE "volume_lock_event";
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "lock_label", DROP COLUMN "lock_client_version", DROP COLUMN "lock_pid", DROP COLUMN "lock_hostname", DROP COLUMN "lock_user_id";
@@ -0,0 +1,7 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "encryption" text NOT NULL DEFAULT 'none', ADD COLUMN "encryption_key" text NULL;
+
+-- +goose Down
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "encryption_key", DROP COLUMN "encryption";
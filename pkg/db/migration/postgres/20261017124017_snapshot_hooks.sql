@@ -0,0 +1,11 @@
+-- +goose Up
+-- modify "volume" table
+ALTER TABLE "volume" ADD COLUMN "snapshot_freeze" boolean NOT NULL DEFAULT false, ADD COLUMN "pre_snapshot_hook" text NULL, ADD COLUMN "post_snapshot_hook" text NULL, ADD COLUMN "snapshot_hook_timeout" bigint NULL;
+-- modify "volume_backup" table
+ALTER TABLE "volume_backup" ADD COLUMN "frozen" boolean NOT NULL DEFAULT false, ADD COLUMN "pre_snapshot_hook_error" text NULL, ADD COLUMN "post_snapshot_hook_error" text NULL;
+
+-- +goose Down
+-- reverse: modify "volume_backup" table
+ALTER TABLE "volume_backup" DROP COLUMN "post_snapshot_hook_error", DROP COLUMN "pre_snapshot_hook_error", DROP COLUMN "frozen";
+-- reverse: modify "volume" table
+ALTER TABLE "volume" DROP COLUMN "snapshot_hook_timeout", DROP COLUMN "post_snapshot_hook", DROP COLUMN "pre_snapshot_hook", DROP COLUMN "snapshot_freeze";
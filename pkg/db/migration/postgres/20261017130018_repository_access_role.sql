@@ -0,0 +1,7 @@
+-- +goose Up
+-- modify "repository_access" table
+ALTER TABLE "repository_access" ADD COLUMN "role" text NOT NULL DEFAULT 'owner';
+
+-- +goose Down
+-- reverse: modify "repository_access" table
+ALTER TABLE "repository_access" DROP COLUMN "role";
@@ -0,0 +1,37 @@
+-- +goose Up
+-- create "organization" table
+CREATE TABLE "organization" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  "finalizers" text NOT NULL DEFAULT '{}',
+  "name" text NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- create index "organization_name" to table: "organization"
+CREATE UNIQUE INDEX "organization_name" ON "organization" ("name") WHERE ("deleted_at" IS NULL);
+-- create "organization_member" table
+CREATE TABLE "organization_member" (
+  "organization_id" bigint NOT NULL,
+  "user_id" text NOT NULL,
+  "role" text NOT NULL,
+  PRIMARY KEY ("organization_id", "user_id"),
+  CONSTRAINT "organization_member_organization_id_fkey" FOREIGN KEY ("organization_id") REFERENCES "organization" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "organization_member_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- modify "repository" table
+ALTER TABLE "repository" DROP CONSTRAINT "repository_name_key", ADD COLUMN "organization_id" bigint NULL, ADD CONSTRAINT "repository_organization_id_name_key" UNIQUE ("organization_id", "name"), ADD CONSTRAINT "repository_organization_id_fkey" FOREIGN KEY ("organization_id") REFERENCES "organization" ("id") ON UPDATE NO ACTION ON DELETE RESTRICT;
+-- create index "repository_name" to table: "repository"
+CREATE UNIQUE INDEX "repository_name" ON "repository" ("name") WHERE ("organization_id" IS NULL);
+
+-- +goose Down
+-- reverse: create index "repository_name" to table: "repository"
+DROP INDEX "repository_name";
+-- reverse: modify "repository" table
+ALTER TABLE "repository" DROP CONSTRAINT "repository_organization_id_fkey", DROP CONSTRAINT "repository_organization_id_name_key", DROP COLUMN "organization_id", ADD CONSTRAINT "repository_name_key" UNIQUE ("name");
+-- reverse: create "organization_member" table
+DROP TABLE "organization_member";
+-- reverse: create index "organization_name" to table: "organization"
+DROP INDEX "organization_name";
+-- reverse: create "organization" table
+DROP TABLE "organization";
@@ -0,0 +1,18 @@
+-- +goose Up
+-- modify "token" table
+ALTER TABLE "token" ADD COLUMN "scope" text NOT NULL DEFAULT 'admin', ADD COLUMN "restrict_resources" boolean NOT NULL DEFAULT false, ADD COLUMN "expires_at" timestamptz NULL, ADD COLUMN "last_used_at" timestamptz NULL, ADD COLUMN "last_used_ip" text NULL;
+-- create "token_resource" table
+CREATE TABLE "token_resource" (
+  "token_id" bigint NOT NULL,
+  "repository_id" bigint NOT NULL,
+  "volume_id" bigint NULL,
+  CONSTRAINT "token_resource_repository_id_fkey" FOREIGN KEY ("repository_id") REFERENCES "repository" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "token_resource_token_id_fkey" FOREIGN KEY ("token_id") REFERENCES "token" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "token_resource_volume_id_fkey" FOREIGN KEY ("volume_id") REFERENCES "volume" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- +goose Down
+-- reverse: create "token_resource" table
+DROP TABLE "token_resource";
+-- reverse: modify "token" table
+ALTER TABLE "token" DROP COLUMN "last_used_ip", DROP COLUMN "last_used_at", DROP COLUMN "expires_at", DROP COLUMN "restrict_resources", DROP COLUMN "scope";
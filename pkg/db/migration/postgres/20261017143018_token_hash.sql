@@ -0,0 +1,13 @@
+-- +goose Up
+-- modify "token" table
+ALTER TABLE "token" ALTER COLUMN "token" DROP NOT NULL, ADD COLUMN "token_prefix" text NULL, ADD COLUMN "token_salt" text NULL, ADD COLUMN "token_hash" text NULL;
+-- create index "token_token_prefix" to table: "token"
+CREATE INDEX "token_token_prefix" ON "token" ("token_prefix");
+
+-- +goose Down
+-- hashed tokens can't be converted back to plaintext, so tokens without a plaintext token are deleted
+DELETE FROM "token" WHERE "token" IS NULL;
+-- reverse: create index "token_token_prefix" to table: "token"
+DROP INDEX "token_token_prefix";
+-- reverse: modify "token" table
+ALTER TABLE "token" DROP COLUMN "token_hash", DROP COLUMN "token_salt", DROP COLUMN "token_prefix", ALTER COLUMN "token" SET NOT NULL;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- add column "last_backup_at" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `last_backup_at` datetime NULL;
+-- create "volume_backup" table
+CREATE TABLE `volume_backup` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime NOT NULL DEFAULT (current_timestamp),
+  `volume_id` bigint NOT NULL,
+  `snapshot_id` text NOT NULL,
+  `snapshot_time` datetime NOT NULL,
+  `hostname` text NOT NULL,
+  `duration_ms` bigint NOT NULL,
+  `total_files` bigint NOT NULL,
+  `total_bytes` bigint NOT NULL,
+  `files_new` bigint NOT NULL,
+  `files_changed` bigint NOT NULL,
+  `files_unmodified` bigint NOT NULL,
+  `dirs_new` bigint NOT NULL,
+  `dirs_changed` bigint NOT NULL,
+  `dirs_unmodified` bigint NOT NULL,
+  `data_added` bigint NOT NULL,
+  `data_added_packed` bigint NOT NULL,
+  CONSTRAINT `0` FOREIGN KEY (`volume_id`) REFERENCES `volume` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- create index "volume_backup_volume_id_snapshot_id" to table: "volume_backup"
+CREATE UNIQUE INDEX `volume_backup_volume_id_snapshot_id` ON `volume_backup` (`volume_id`, `snapshot_id`);
+
+-- +goose Down
+-- reverse: create index "volume_backup_volume_id_snapshot_id" to table: "volume_backup"
+DROP INDEX `volume_backup_volume_id_snapshot_id`;
+-- reverse: create "volume_backup" table
+DROP TABLE `volume_backup`;
+-- reverse: add column "last_backup_at" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `last_backup_at`;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- add column "prune_lock_id" to table: "repository"
+ALTER TABLE `repository` ADD COLUMN `prune_lock_id` text NULL;
+-- add column "prune_lock_time" to table: "repository"
+ALTER TABLE `repository` ADD COLUMN `prune_lock_time` bigint NULL;
+-- add column "keep_last" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `keep_last` bigint NULL;
+-- add column "keep_hourly" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `keep_hourly` bigint NULL;
+-- add column "keep_daily" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `keep_daily` bigint NULL;
+-- add column "keep_weekly" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `keep_weekly` bigint NULL;
+-- add column "keep_monthly" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `keep_monthly` bigint NULL;
+
+-- +goose Down
+-- reverse: add column "keep_monthly" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `keep_monthly`;
+-- reverse: add column "keep_weekly" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `keep_weekly`;
+-- reverse: add column "keep_daily" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `keep_daily`;
+-- reverse: add column "keep_hourly" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `keep_hourly`;
+-- reverse: add column "keep_last" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `keep_last`;
+-- reverse: add column "prune_lock_time" to table: "repository"
+ALTER TABLE `repository` DROP COLUMN `prune_lock_time`;
+-- reverse: add column "prune_lock_id" to table: "repository"
+ALTER TABLE `repository` DROP COLUMN `prune_lock_id`;
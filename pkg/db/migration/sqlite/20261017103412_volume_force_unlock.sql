@@ -0,0 +1,15 @@
+-- +goose Up
+-- add column "lock_broken_by" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_broken_by` text NULL;
+-- add column "lock_broken_at" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_broken_at` datetime NULL;
+-- add column "lock_broken_reason" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_broken_reason` text NULL;
+
+-- +goose Down
+-- reverse: add column "lock_broken_reason" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_broken_reason`;
+-- reverse: add column "lock_broken_at" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_broken_at`;
+-- reverse: add column "lock_broken_by" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_broken_by`;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- add column "lock_ttl" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_ttl` bigint NOT NULL DEFAULT 300;
+
+-- +goose Down
+-- reverse: add column "lock_ttl" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_ttl`;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- add column "lock_user_id" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_user_id` text NULL;
+-- add column "lock_hostname" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_hostname` text NULL;
+-- add column "lock_pid" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_pid` bigint NULL;
+-- add column "lock_client_version" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_client_version` text NULL;
+-- add column "lock_label" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `lock_label` text NULL;
+-- create "volume_lock_event" table
+CREATE TABLE `volume_lock_event` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime NOT NULL DEFAULT (current_timestamp),
+  `volume_id` bigint NOT NULL,
+  `event` text NOT NULL,
+  `lock_id` text NOT NULL,
+  `user_id` text NULL,
+  `hostname` text NULL,
+  `pid` bigint NULL,
+  `client_version` text NULL,
+  `label` text NULL,
+  `reason` text NULL,
+  CONSTRAINT `0` FOREIGN KEY (`volume_id`) REFERENCES `volume` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- +goose Down
+-- reverse: create "volume_lock_event" table
+DROP TABLE `volume_lock_event`;
+-- reverse: add column "lock_label" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_label`;
+-- reverse: add column "lock_client_version" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_client_version`;
+-- reverse: add column "lock_pid" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_pid`;
+-- reverse: add column "lock_hostname" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_hostname`;
+-- reverse: add column "lock_user_id" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `lock_user_id`;
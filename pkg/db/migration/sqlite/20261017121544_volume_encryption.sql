@@ -0,0 +1,11 @@
+-- +goose Up
+-- add column "encryption" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `encryption` text NOT NULL DEFAULT 'none';
+-- add column "encryption_key" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `encryption_key` text NULL;
+
+-- +goose Down
+-- reverse: add column "encryption_key" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `encryption_key`;
+-- reverse: add column "encryption" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `encryption`;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- add column "snapshot_freeze" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `snapshot_freeze` boolean NOT NULL DEFAULT false;
+-- add column "pre_snapshot_hook" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `pre_snapshot_hook` text NULL;
+-- add column "post_snapshot_hook" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `post_snapshot_hook` text NULL;
+-- add column "snapshot_hook_timeout" to table: "volume"
+ALTER TABLE `volume` ADD COLUMN `snapshot_hook_timeout` bigint NULL;
+-- add column "frozen" to table: "volume_backup"
+ALTER TABLE `volume_backup` ADD COLUMN `frozen` boolean NOT NULL DEFAULT false;
+-- add column "pre_snapshot_hook_error" to table: "volume_backup"
+ALTER TABLE `volume_backup` ADD COLUMN `pre_snapshot_hook_error` text NULL;
+-- add column "post_snapshot_hook_error" to table: "volume_backup"
+ALTER TABLE `volume_backup` ADD COLUMN `post_snapshot_hook_error` text NULL;
+
+-- +goose Down
+-- reverse: add column "post_snapshot_hook_error" to table: "volume_backup"
+ALTER TABLE `volume_backup` DROP COLUMN `post_snapshot_hook_error`;
+-- reverse: add column "pre_snapshot_hook_error" to table: "volume_backup"
+ALTER TABLE `volume_backup` DROP COLUMN `pre_snapshot_hook_error`;
+-- reverse: add column "frozen" to table: "volume_backup"
+ALTER TABLE `volume_backup` DROP COLUMN `frozen`;
+-- reverse: add column "snapshot_hook_timeout" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `snapshot_hook_timeout`;
+-- reverse: add column "post_snapshot_hook" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `post_snapshot_hook`;
+-- reverse: add column "pre_snapshot_hook" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `pre_snapshot_hook`;
+-- reverse: add column "snapshot_freeze" to table: "volume"
+ALTER TABLE `volume` DROP COLUMN `snapshot_freeze`;
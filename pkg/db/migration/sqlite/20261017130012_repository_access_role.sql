@@ -0,0 +1,7 @@
+-- +goose Up
+-- add column "role" to table: "repository_access"
+ALTER TABLE `repository_access` ADD COLUMN `role` text NOT NULL DEFAULT 'owner';
+
+-- +goose Down
+-- reverse: add column "role" to table: "repository_access"
+ALTER TABLE `repository_access` DROP COLUMN `role`;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- create "organization" table
+CREATE TABLE `organization` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime NOT NULL DEFAULT (current_timestamp),
+  `deleted_at` datetime NULL,
+  `finalizers` text NOT NULL DEFAULT '{}',
+  `name` text NOT NULL
+);
+-- create index "organization_name" to table: "organization"
+CREATE UNIQUE INDEX `organization_name` ON `organization` (`name`) WHERE (`deleted_at` IS NULL);
+-- create "organization_member" table
+CREATE TABLE `organization_member` (
+  `organization_id` bigint NOT NULL,
+  `user_id` text NOT NULL,
+  `role` text NOT NULL,
+  PRIMARY KEY (`organization_id`, `user_id`),
+  CONSTRAINT `0` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT `1` FOREIGN KEY (`organization_id`) REFERENCES `organization` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- add column "organization_id" to table: "repository"
+ALTER TABLE `repository` ADD COLUMN `organization_id` bigint NULL REFERENCES `organization` (`id`) ON UPDATE NO ACTION ON DELETE RESTRICT;
+-- drop index "repository_name" from table: "repository"
+DROP INDEX `repository_name`;
+-- create index "repository_organization_id_name" to table: "repository"
+CREATE UNIQUE INDEX `repository_organization_id_name` ON `repository` (`organization_id`, `name`);
+-- create index "repository_name" to table: "repository"
+CREATE UNIQUE INDEX `repository_name` ON `repository` (`name`) WHERE (`organization_id` IS NULL);
+
+-- +goose Down
+-- reverse: create index "repository_name" to table: "repository"
+DROP INDEX `repository_name`;
+-- reverse: create index "repository_organization_id_name" to table: "repository"
+DROP INDEX `repository_organization_id_name`;
+-- reverse: drop index "repository_name" from table: "repository"
+CREATE UNIQUE INDEX `repository_name` ON `repository` (`name`);
+-- reverse: add column "organization_id" to table: "repository"
+ALTER TABLE `repository` DROP COLUMN `organization_id`;
+-- reverse: create "organization_member" table
+DROP TABLE `organization_member`;
+-- reverse: create index "organization_name" to table: "organization"
+DROP INDEX `organization_name`;
+-- reverse: create "organization" table
+DROP TABLE `organization`;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- add column "scope" to table: "token"
+ALTER TABLE `token` ADD COLUMN `scope` text NOT NULL DEFAULT 'admin';
+-- add column "restrict_resources" to table: "token"
+ALTER TABLE `token` ADD COLUMN `restrict_resources` boolean NOT NULL DEFAULT false;
+-- add column "expires_at" to table: "token"
+ALTER TABLE `token` ADD COLUMN `expires_at` datetime NULL;
+-- add column "last_used_at" to table: "token"
+ALTER TABLE `token` ADD COLUMN `last_used_at` datetime NULL;
+-- add column "last_used_ip" to table: "token"
+ALTER TABLE `token` ADD COLUMN `last_used_ip` text NULL;
+-- create "token_resource" table
+CREATE TABLE `token_resource` (
+  `token_id` bigint NOT NULL,
+  `repository_id` bigint NOT NULL,
+  `volume_id` bigint NULL,
+  CONSTRAINT `0` FOREIGN KEY (`volume_id`) REFERENCES `volume` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT `1` FOREIGN KEY (`repository_id`) REFERENCES `repository` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT `2` FOREIGN KEY (`token_id`) REFERENCES `token` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- +goose Down
+-- reverse: create "token_resource" table
+DROP TABLE `token_resource`;
+-- reverse: add column "last_used_ip" to table: "token"
+ALTER TABLE `token` DROP COLUMN `last_used_ip`;
+-- reverse: add column "last_used_at" to table: "token"
+ALTER TABLE `token` DROP COLUMN `last_used_at`;
+-- reverse: add column "expires_at" to table: "token"
+ALTER TABLE `token` DROP COLUMN `expires_at`;
+-- reverse: add column "restrict_resources" to table: "token"
+ALTER TABLE `token` DROP COLUMN `restrict_resources`;
+-- reverse: add column "scope" to table: "token"
+ALTER TABLE `token` DROP COLUMN `scope`;
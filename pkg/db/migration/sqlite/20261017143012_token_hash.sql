@@ -0,0 +1,63 @@
+-- +goose Up
+-- disable the enforcement of foreign-keys constraints
+PRAGMA foreign_keys = off;
+-- create "new_token" table
+CREATE TABLE `new_token` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime NOT NULL DEFAULT (current_timestamp),
+  `token` text NULL,
+  `token_prefix` text NULL,
+  `token_salt` text NULL,
+  `token_hash` text NULL,
+  `name` text NOT NULL,
+  `user_id` text NOT NULL,
+  `scope` text NOT NULL DEFAULT 'admin',
+  `restrict_resources` boolean NOT NULL DEFAULT false,
+  `expires_at` datetime NULL,
+  `last_used_at` datetime NULL,
+  `last_used_ip` text NULL,
+  CONSTRAINT `0` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- copy rows from old table "token" to new temporary table "new_token"
+INSERT INTO `new_token` (`id`, `created_at`, `token`, `name`, `user_id`, `scope`, `restrict_resources`, `expires_at`, `last_used_at`, `last_used_ip`) SELECT `id`, `created_at`, `token`, `name`, `user_id`, `scope`, `restrict_resources`, `expires_at`, `last_used_at`, `last_used_ip` FROM `token`;
+-- drop "token" table after copying rows
+DROP TABLE `token`;
+-- rename temporary table "new_token" to "token"
+ALTER TABLE `new_token` RENAME TO `token`;
+-- create index "token_token" to table: "token"
+CREATE UNIQUE INDEX `token_token` ON `token` (`token`);
+-- create index "token_token_prefix" to table: "token"
+CREATE INDEX `token_token_prefix` ON `token` (`token_prefix`);
+-- enable back the enforcement of foreign-keys constraints
+PRAGMA foreign_keys = on;
+
+-- +goose Down
+-- hashed tokens can't be converted back to plaintext, so tokens without a plaintext token are deleted
+DELETE FROM `token_resource` WHERE `token_id` IN (SELECT `id` FROM `token` WHERE `token` IS NULL);
+DELETE FROM `token` WHERE `token` IS NULL;
+-- disable the enforcement of foreign-keys constraints
+PRAGMA foreign_keys = off;
+-- create "new_token" table
+CREATE TABLE `new_token` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime NOT NULL DEFAULT (current_timestamp),
+  `token` text NOT NULL,
+  `name` text NOT NULL,
+  `user_id` text NOT NULL,
+  `scope` text NOT NULL DEFAULT 'admin',
+  `restrict_resources` boolean NOT NULL DEFAULT false,
+  `expires_at` datetime NULL,
+  `last_used_at` datetime NULL,
+  `last_used_ip` text NULL,
+  CONSTRAINT `0` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- copy rows from old table "token" to new temporary table "new_token"
+INSERT INTO `new_token` (`id`, `created_at`, `token`, `name`, `user_id`, `scope`, `restrict_resources`, `expires_at`, `last_used_at`, `last_used_ip`) SELECT `id`, `created_at`, `token`, `name`, `user_id`, `scope`, `restrict_resources`, `expires_at`, `last_used_at`, `last_used_ip` FROM `token`;
+-- drop "token" table after copying rows
+DROP TABLE `token`;
+-- rename temporary table "new_token" to "token"
+ALTER TABLE `new_token` RENAME TO `token`;
+-- create index "token_token" to table: "token"
+CREATE UNIQUE INDEX `token_token` ON `token` (`token`);
+-- enable back the enforcement of foreign-keys constraints
+PRAGMA foreign_keys = on;
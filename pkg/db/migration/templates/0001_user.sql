@@ -13,8 +13,43 @@ create table token
     id         TYPES_INT_PRIMARY_KEY,
     created_at TYPES_DATETIME not null default current_timestamp,
 
-    token      text           not null unique,
+    -- plaintext tokens are only kept until they are migrated to token_hash
+    token      text           unique,
+
+    token_prefix text,
+    token_salt   text,
+    token_hash   text,
 
     name       text           not null,
-    user_id    text           not null references "user" (id) on delete cascade
+    user_id    text           not null references "user" (id) on delete cascade,
+
+    scope              text           not null default 'admin',
+    restrict_resources boolean        not null default false,
+    expires_at         TYPES_DATETIME,
+
+    last_used_at TYPES_DATETIME,
+    last_used_ip text
+);
+
+create index token_token_prefix on token (token_prefix);
+
+create table organization
+(
+    id         TYPES_INT_PRIMARY_KEY,
+    created_at TYPES_DATETIME not null default current_timestamp,
+    deleted_at TYPES_DATETIME,
+    finalizers text           not null default '{}',
+
+    name       text           not null
+);
+
+create unique index organization_name on organization (name) where deleted_at is null;
+
+create table organization_member
+(
+    organization_id bigint not null references organization (id) on delete cascade,
+    user_id         text   not null references "user" (id) on delete cascade,
+    role            text   not null,
+
+    primary key (organization_id, user_id)
 );
@@ -1,20 +1,28 @@
 create table repository
 (
-    id         TYPES_INT_PRIMARY_KEY,
-    created_at TYPES_DATETIME not null default current_timestamp,
-    deleted_at TYPES_DATETIME,
-    finalizers text           not null default '{}',
+    id              TYPES_INT_PRIMARY_KEY,
+    created_at      TYPES_DATETIME not null default current_timestamp,
+    deleted_at      TYPES_DATETIME,
+    finalizers      text           not null default '{}',
 
-    name       text           not null,
-    uuid       text           not null unique,
+    organization_id bigint references organization (id) on delete restrict,
 
-    unique (name)
+    name            text           not null,
+    uuid            text           not null unique,
+
+    prune_lock_id   text,
+    prune_lock_time bigint,
+
+    unique (organization_id, name)
 );
 
+create unique index repository_name on repository (name) where organization_id is null;
+
 create table repository_access
 (
     repository_id bigint not null references repository (id) on delete cascade,
     user_id       text   not null references "user" (id) on delete restrict,
+    role          text   not null default 'owner',
 
     primary key (repository_id, user_id)
 );
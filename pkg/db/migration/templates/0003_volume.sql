@@ -1,21 +1,103 @@
 create table volume
 (
-    id            TYPES_INT_PRIMARY_KEY,
-    created_at    TYPES_DATETIME not null default current_timestamp,
-    deleted_at    TYPES_DATETIME,
-    finalizers    text           not null default '{}',
+    id                  TYPES_INT_PRIMARY_KEY,
+    created_at          TYPES_DATETIME not null default current_timestamp,
+    deleted_at          TYPES_DATETIME,
+    finalizers          text           not null default '{}',
 
-    repository_id bigint         not null references repository (id) on delete restrict,
+    repository_id       bigint         not null references repository (id) on delete restrict,
 
-    uuid          text           not null unique,
-    name          text           not null,
+    uuid                text           not null unique,
+    name                text           not null,
 
-    fs_size       bigint         not null,
-    fs_type       text           not null,
+    fs_size             bigint         not null,
+    fs_type             text           not null,
 
-    lock_id       text,
-    lock_time     bigint,
+    encryption          text           not null default 'none',
+    encryption_key      text,
+
+    lock_id             text,
+    lock_time           bigint,
+    lock_ttl            bigint         not null default 300,
+
+    lock_user_id        text,
+    lock_hostname       text,
+    lock_pid            bigint,
+    lock_client_version text,
+    lock_label          text,
+
+    lock_broken_by      text,
+    lock_broken_at      TYPES_DATETIME,
+    lock_broken_reason  text,
+
+    last_backup_at      TYPES_DATETIME,
+
+    keep_last           bigint,
+    keep_hourly         bigint,
+    keep_daily          bigint,
+    keep_weekly         bigint,
+    keep_monthly        bigint,
+
+    snapshot_freeze       boolean        not null default false,
+    pre_snapshot_hook     text,
+    post_snapshot_hook    text,
+    snapshot_hook_timeout bigint,
 
     unique (repository_id, uuid),
     unique (repository_id, name)
 );
+
+create table volume_backup
+(
+    id                TYPES_INT_PRIMARY_KEY,
+    created_at        TYPES_DATETIME not null default current_timestamp,
+
+    volume_id         bigint         not null references volume (id) on delete cascade,
+
+    snapshot_id       text           not null,
+    snapshot_time     TYPES_DATETIME not null,
+    hostname          text           not null,
+    duration_ms       bigint         not null,
+
+    total_files       bigint         not null,
+    total_bytes       bigint         not null,
+    files_new         bigint         not null,
+    files_changed     bigint         not null,
+    files_unmodified  bigint         not null,
+    dirs_new          bigint         not null,
+    dirs_changed      bigint         not null,
+    dirs_unmodified   bigint         not null,
+    data_added        bigint         not null,
+    data_added_packed bigint         not null,
+
+    frozen                   boolean        not null default false,
+    pre_snapshot_hook_error  text,
+    post_snapshot_hook_error text,
+
+    unique (volume_id, snapshot_id)
+);
+
+create table volume_lock_event
+(
+    id             TYPES_INT_PRIMARY_KEY,
+    created_at     TYPES_DATETIME not null default current_timestamp,
+
+    volume_id      bigint         not null references volume (id) on delete cascade,
+
+    event          text           not null,
+    lock_id        text           not null,
+
+    user_id        text,
+    hostname       text,
+    pid            bigint,
+    client_version text,
+    label          text,
+    reason         text
+);
+
+create table token_resource
+(
+    token_id      bigint not null references token (id) on delete cascade,
+    repository_id bigint not null references repository (id) on delete cascade,
+    volume_id     bigint references volume (id) on delete cascade
+);
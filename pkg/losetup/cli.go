@@ -0,0 +1,58 @@
+package losetup
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+type cli struct {
+}
+
+type holder struct {
+	Loopdevices []Entry `json:"loopdevices"`
+}
+
+func NewCli() Backend {
+	return &cli{}
+}
+
+func (c *cli) List() ([]Entry, error) {
+	stdout, err := util.RunCommandStdout("losetup", "-J")
+	if err != nil {
+		return nil, err
+	}
+
+	var h holder
+	err = json.Unmarshal(stdout, &h)
+	if err != nil {
+		return nil, err
+	}
+	return h.Loopdevices, nil
+}
+
+func (c *cli) Attach(file string) (string, error) {
+	stdout, err := util.RunCommandStdout("losetup", "-f", "--show", file)
+	if err != nil {
+		return "", err
+	}
+	loDev := strings.TrimSpace(string(stdout))
+	return loDev, nil
+}
+
+func (c *cli) Detach(loDev string) error {
+	err := util.RunCommand("losetup", "-d", loDev)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *cli) SetCapacity(loDev string) error {
+	err := util.RunCommand("losetup", "-c", loDev)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,11 +1,9 @@
 package losetup
 
 import (
-	"encoding/json"
+	"log/slog"
 	"os"
-	"strings"
-
-	"github.com/dboxed/dboxed-volume/pkg/util"
+	"sync"
 )
 
 type Entry struct {
@@ -19,31 +17,45 @@ type Entry struct {
 	LogSec    int    `json:"log-sec"`
 }
 
-type holder struct {
-	Loopdevices []Entry `json:"loopdevices"`
+// Backend is implemented by the native ioctl based backend and by the losetup command line backend
+type Backend interface {
+	List() ([]Entry, error)
+	Attach(file string) (string, error)
+	Detach(loDev string) error
+	SetCapacity(loDev string) error
 }
 
-func List() ([]Entry, error) {
-	stdout, err := util.RunCommandStdout("losetup", "-J")
-	if err != nil {
-		return nil, err
-	}
+var backend Backend
+var backendMutex sync.Mutex
 
-	var h holder
-	err = json.Unmarshal(stdout, &h)
-	if err != nil {
-		return nil, err
+func getBackend() Backend {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+	if backend == nil {
+		b, err := NewNative()
+		if err != nil {
+			slog.Debug("native loop backend not available, falling back to losetup", slog.Any("error", err))
+			backend = NewCli()
+		} else {
+			backend = b
+		}
 	}
-	return h.Loopdevices, nil
+	return backend
+}
+
+// SetBackend overrides the automatically selected backend
+func SetBackend(b Backend) {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+	backend = b
+}
+
+func List() ([]Entry, error) {
+	return getBackend().List()
 }
 
 func Attach(file string) (string, error) {
-	stdout, err := util.RunCommandStdout("losetup", "-f", "--show", file)
-	if err != nil {
-		return "", err
-	}
-	loDev := strings.TrimSpace(string(stdout))
-	return loDev, nil
+	return getBackend().Attach(file)
 }
 
 func GetOrAttach(file string, allowAttach bool) (string, bool, error) {
@@ -69,9 +81,10 @@ func GetOrAttach(file string, allowAttach bool) (string, bool, error) {
 }
 
 func Detach(loDev string) error {
-	err := util.RunCommand("losetup", "-d", loDev)
-	if err != nil {
-		return err
-	}
-	return nil
+	return getBackend().Detach(loDev)
+}
+
+// SetCapacity lets the loop device pick up a changed size of the backing file
+func SetCapacity(loDev string) error {
+	return getBackend().SetCapacity(loDev)
 }
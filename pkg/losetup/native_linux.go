@@ -0,0 +1,177 @@
+//go:build linux
+
+package losetup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const loopControl = "/dev/loop-control"
+
+// attachRetries limits how often we retry to grab a free loop device when other processes grab it in-between
+const attachRetries = 10
+
+type native struct {
+}
+
+// NewNative returns a backend that talks to the loop driver via ioctls and reads loop device state from sysfs
+func NewNative() (Backend, error) {
+	_, err := os.Stat(loopControl)
+	if err != nil {
+		return nil, err
+	}
+	return &native{}, nil
+}
+
+func (n *native) List() ([]Entry, error) {
+	sysDirs, err := filepath.Glob("/sys/block/loop*")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Entry
+	for _, sysDir := range sysDirs {
+		backFile, err := os.ReadFile(filepath.Join(sysDir, "loop", "backing_file"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// not attached
+				continue
+			}
+			return nil, err
+		}
+
+		e := Entry{
+			Name:     filepath.Join("/dev", filepath.Base(sysDir)),
+			BackFile: strings.TrimSpace(string(backFile)),
+		}
+		e.Offset, err = readSysInt(sysDir, "loop/offset")
+		if err != nil {
+			return nil, err
+		}
+		e.Sizelimit, err = readSysInt(sysDir, "loop/sizelimit")
+		if err != nil {
+			return nil, err
+		}
+		e.LogSec, err = readSysInt(sysDir, "queue/logical_block_size")
+		if err != nil {
+			return nil, err
+		}
+		autoclear, err := readSysInt(sysDir, "loop/autoclear")
+		if err != nil {
+			return nil, err
+		}
+		dio, err := readSysInt(sysDir, "loop/dio")
+		if err != nil {
+			return nil, err
+		}
+		ro, err := readSysInt(sysDir, "ro")
+		if err != nil {
+			return nil, err
+		}
+		e.Autoclear = autoclear != 0
+		e.Dio = dio != 0
+		e.Ro = ro != 0
+
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+func readSysInt(sysDir string, name string) (int, error) {
+	b, err := os.ReadFile(filepath.Join(sysDir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (n *native) Attach(file string) (string, error) {
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	ctl, err := os.OpenFile(loopControl, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer ctl.Close()
+
+	for range attachRetries {
+		nr, err := unix.IoctlRetInt(int(ctl.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return "", fmt.Errorf("LOOP_CTL_GET_FREE failed: %w", err)
+		}
+		loDev := fmt.Sprintf("/dev/loop%d", nr)
+
+		err = n.configure(loDev, f, file)
+		if errors.Is(err, unix.EBUSY) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return loDev, nil
+	}
+	return "", fmt.Errorf("failed to grab a free loop device after %d attempts", attachRetries)
+}
+
+func (n *native) configure(loDev string, f *os.File, file string) error {
+	dev, err := os.OpenFile(loDev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+	fd := int(dev.Fd())
+
+	config := unix.LoopConfig{
+		Fd: uint32(f.Fd()),
+	}
+	// the name is only informational and truncated by the kernel anyway, the last byte must stay 0
+	copy(config.Info.File_name[:len(config.Info.File_name)-1], file)
+
+	err = unix.IoctlLoopConfigure(fd, &config)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTTY) {
+		// LOOP_CONFIGURE is only available since Linux 5.8
+		err = unix.IoctlSetInt(fd, unix.LOOP_SET_FD, int(f.Fd()))
+		if err != nil {
+			return err
+		}
+		err = unix.IoctlLoopSetStatus64(fd, &config.Info)
+		if err != nil {
+			_ = unix.IoctlSetInt(fd, unix.LOOP_CLR_FD, 0)
+			return err
+		}
+		return nil
+	}
+	return err
+}
+
+func (n *native) Detach(loDev string) error {
+	return n.ioctl(loDev, unix.LOOP_CLR_FD)
+}
+
+func (n *native) SetCapacity(loDev string) error {
+	return n.ioctl(loDev, unix.LOOP_SET_CAPACITY)
+}
+
+func (n *native) ioctl(loDev string, req uint) error {
+	dev, err := os.OpenFile(loDev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+	err = unix.IoctlSetInt(int(dev.Fd()), req, 0)
+	if err != nil {
+		return fmt.Errorf("ioctl on %s failed: %w", loDev, err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package losetup
+
+import "fmt"
+
+func NewNative() (Backend, error) {
+	return nil, fmt.Errorf("native loop backend is only supported on linux")
+}
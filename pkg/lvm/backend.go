@@ -0,0 +1,126 @@
+package lvm
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// Backend is implemented by all lvm backends
+type Backend interface {
+	ListPVs() ([]PVEntry, error)
+	ListVGs() ([]VGEntry, error)
+	ListLVs() ([]LVEntry, error)
+
+	PVCreate(dev string) error
+	PVGet(pvName string) (*PVEntry, error)
+	PVResize(dev string) error
+
+	VGCreate(vgName string, devs ...string) error
+	VGGet(vgName string) (*VGEntry, error)
+	VGDeactivate(vgName string) error
+
+	TPCreate100(vgName string, tpName string, tags []string) error
+	TPExtendMetadata(vgName string, tpName string, size int64) error
+	TPRepair(vgName string, tpName string) error
+
+	LVGet(vgName string, lvName string) (*LVEntry, error)
+	LVCreate(vgName string, lvName string, size int64, tags []string) error
+	TLVCreate(vgName string, tpName string, lvName string, size int64, tags []string) error
+	TLVSnapCreate(vgName string, lvName string, tpName string, snapName string) error
+	LVExtend(vgName string, lvName string, size int64) error
+	LVExtend100(vgName string, lvName string) error
+	LVRemove(vgName string, lvName string) error
+	LVRename(vgName string, lvName string, newLvName string) error
+	LVAddTag(vgName string, lvName string, tag string) error
+	LVDelTag(vgName string, lvName string, tag string) error
+	LVActivate(vgName string, lvName string, activate bool) error
+
+	FindPVLVs(pvName string) ([]LVEntry, error)
+}
+
+// Cli runs the lvm command line tools. Without devices, every command scans all block devices of the host, which
+// gets slow on hosts with many volumes. With devices, all commands are restricted to the given devices.
+type Cli struct {
+	devices []string
+}
+
+var useGlobalScans = false
+
+// UseGlobalScans disables scoping of lvm commands to the devices of a volume
+func UseGlobalScans() {
+	useGlobalScans = true
+}
+
+func NewGlobal() *Cli {
+	return &Cli{}
+}
+
+// NewScoped returns a backend that only looks at the given devices. It falls back to global scans if the installed
+// lvm version does not support the --devices option.
+func NewScoped(devices ...string) Backend {
+	if useGlobalScans || !supportsDevicesOption() {
+		return NewGlobal()
+	}
+	return &Cli{
+		devices: devices,
+	}
+}
+
+func (c *Cli) buildArgs(args []string) []string {
+	if len(c.devices) == 0 {
+		return args
+	}
+	return append([]string{"--devices", strings.Join(c.devices, ",")}, args...)
+}
+
+func (c *Cli) run(command string, args ...string) error {
+	return util.RunCommand(command, c.buildArgs(args)...)
+}
+
+func runJson[T any](c *Cli, command string, args ...string) (*T, error) {
+	return util.RunCommandJson[T](command, c.buildArgs(args)...)
+}
+
+var supportsDevicesOption = sync.OnceValue(func() bool {
+	stdout, err := util.RunCommandStdout("lvm", "version")
+	if err != nil {
+		slog.Warn("failed to determine lvm version, falling back to global scans", slog.Any("error", err))
+		return false
+	}
+	major, minor, patch, ok := parseLvmVersion(string(stdout))
+	if !ok {
+		slog.Warn("failed to parse lvm version, falling back to global scans")
+		return false
+	}
+	// --devices was added in 2.03.12
+	if major != 2 {
+		return major > 2
+	}
+	if minor != 3 {
+		return minor > 3
+	}
+	return patch >= 12
+})
+
+var lvmVersionRegex = regexp.MustCompile(`LVM version:\s+(\d+)\.(\d+)\.(\d+)`)
+
+func parseLvmVersion(s string) (int, int, int, bool) {
+	m := lvmVersionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, 0, false
+	}
+	var v [3]int
+	for i := range v {
+		x, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		v[i] = x
+	}
+	return v[0], v[1], v[2], true
+}
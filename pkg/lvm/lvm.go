@@ -4,18 +4,20 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
-
-	"github.com/dboxed/dboxed-volume/pkg/util"
+	"time"
 )
 
 type PVEntry struct {
-	PvName string `json:"pv_name"`
-	VgName string `json:"vg_name"`
-	PvFmt  string `json:"pv_fmt"`
-	PvAttr string `json:"pv_attr"`
-	PvSize string `json:"pv_size"`
-	PvFree string `json:"pv_free"`
+	PvName  string `json:"pv_name"`
+	VgName  string `json:"vg_name"`
+	PvFmt   string `json:"pv_fmt"`
+	PvAttr  string `json:"pv_attr"`
+	PvSize  string `json:"pv_size"`
+	PvFree  string `json:"pv_free"`
+	PeStart string `json:"pe_start"`
 }
 
 type pvsReport struct {
@@ -32,6 +34,8 @@ type VGEntry struct {
 	VgAttr    string `json:"vg_attr"`
 	VgSize    string `json:"vg_size"`
 	VgFree    string `json:"vg_free"`
+
+	VgExtentSize string `json:"vg_extent_size"`
 }
 type vgsReport struct {
 	Report []struct {
@@ -48,10 +52,13 @@ type LVEntry struct {
 	Origin          string `json:"origin"`
 	DataPercent     string `json:"data_percent"`
 	MetadataPercent string `json:"metadata_percent"`
+	LvMetadataSize  string `json:"lv_metadata_size"`
 	MovePv          string `json:"move_pv"`
 	MirrorLog       string `json:"mirror_log"`
 	CopyPercent     string `json:"copy_percent"`
 	ConvertLv       string `json:"convert_lv"`
+	ThinId          string `json:"thin_id"`
+	LvTime          string `json:"lv_time"`
 
 	LvTags string `json:"lv_tags"`
 }
@@ -75,52 +82,52 @@ func buildColNames[T any]() []string {
 	return ret
 }
 
-func ListPVs() ([]PVEntry, error) {
-	h, err := util.RunCommandJson[pvsReport]("pvs", "--reportformat=json", "-o", strings.Join(buildColNames[PVEntry](), ","))
+func (c *Cli) ListPVs() ([]PVEntry, error) {
+	h, err := runJson[pvsReport](c, "pvs", "--reportformat=json", "--units", "b", "--nosuffix", "-o", strings.Join(buildColNames[PVEntry](), ","))
 	if err != nil {
 		return nil, err
 	}
 	return h.Report[0].Pv, nil
 }
 
-func ListVGs() ([]VGEntry, error) {
-	h, err := util.RunCommandJson[vgsReport]("vgs", "--reportformat=json", "-o", strings.Join(buildColNames[VGEntry](), ","))
+func (c *Cli) ListVGs() ([]VGEntry, error) {
+	h, err := runJson[vgsReport](c, "vgs", "--reportformat=json", "--units", "b", "--nosuffix", "-o", strings.Join(buildColNames[VGEntry](), ","))
 	if err != nil {
 		return nil, err
 	}
 	return h.Report[0].Vg, nil
 }
 
-func ListLVs() ([]LVEntry, error) {
-	h, err := util.RunCommandJson[lvsReport]("lvs", "--reportformat=json", "-o", strings.Join(buildColNames[LVEntry](), ","))
+func (c *Cli) ListLVs() ([]LVEntry, error) {
+	h, err := runJson[lvsReport](c, "lvs", "--reportformat=json", "--units", "b", "--nosuffix", "-o", strings.Join(buildColNames[LVEntry](), ","))
 	if err != nil {
 		return nil, err
 	}
 	return h.Report[0].Lv, nil
 }
 
-func PVCreate(dev string) error {
-	err := util.RunCommand("pvcreate", dev)
+func (c *Cli) PVCreate(dev string) error {
+	err := c.run("pvcreate", dev)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func VGCreate(vgName string, devs ...string) error {
+func (c *Cli) VGCreate(vgName string, devs ...string) error {
 	args := []string{
 		vgName,
 	}
 	args = append(args, devs...)
-	err := util.RunCommand("vgcreate", args...)
+	err := c.run("vgcreate", args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func VGGet(vgName string) (*VGEntry, error) {
-	vgs, err := ListVGs()
+func (c *Cli) VGGet(vgName string) (*VGEntry, error) {
+	vgs, err := c.ListVGs()
 	if err != nil {
 		return nil, err
 	}
@@ -132,15 +139,36 @@ func VGGet(vgName string) (*VGEntry, error) {
 	return nil, os.ErrNotExist
 }
 
-func VGDeactivate(vgName string) error {
-	err := util.RunCommand("vgchange", "-an", vgName)
+func (c *Cli) PVGet(pvName string) (*PVEntry, error) {
+	pvs, err := c.ListPVs()
+	if err != nil {
+		return nil, err
+	}
+	for _, pv := range pvs {
+		if pv.PvName == pvName {
+			return &pv, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (c *Cli) PVResize(dev string) error {
+	err := c.run("pvresize", dev)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func TPCreate100(vgName string, tpName string, tags []string) error {
+func (c *Cli) VGDeactivate(vgName string) error {
+	err := c.run("vgchange", "-an", vgName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Cli) TPCreate100(vgName string, tpName string, tags []string) error {
 	args := []string{
 		"-l100%FREE",
 		"--thinpool", tpName,
@@ -149,15 +177,15 @@ func TPCreate100(vgName string, tpName string, tags []string) error {
 	for _, t := range tags {
 		args = append(args, "--addtag", t)
 	}
-	err := util.RunCommand("lvcreate", args...)
+	err := c.run("lvcreate", args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func LVGet(vgName string, lvName string) (*LVEntry, error) {
-	lvs, err := ListLVs()
+func (c *Cli) LVGet(vgName string, lvName string) (*LVEntry, error) {
+	lvs, err := c.ListLVs()
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +197,7 @@ func LVGet(vgName string, lvName string) (*LVEntry, error) {
 	return nil, os.ErrNotExist
 }
 
-func LVCreate(vgName string, lvName string, size int64, tags []string) error {
+func (c *Cli) LVCreate(vgName string, lvName string, size int64, tags []string) error {
 	args := []string{
 		"--name", lvName,
 		"-L", fmt.Sprintf("%dB", size),
@@ -178,14 +206,14 @@ func LVCreate(vgName string, lvName string, size int64, tags []string) error {
 	for _, t := range tags {
 		args = append(args, "--addtag", t)
 	}
-	err := util.RunCommand("lvcreate", args...)
+	err := c.run("lvcreate", args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func TLVCreate(vgName string, tpName string, lvName string, size int64, tags []string) error {
+func (c *Cli) TLVCreate(vgName string, tpName string, lvName string, size int64, tags []string) error {
 	args := []string{
 		"--name", lvName,
 		"--thin",
@@ -195,36 +223,96 @@ func TLVCreate(vgName string, tpName string, lvName string, size int64, tags []s
 	for _, t := range tags {
 		args = append(args, "--addtag", t)
 	}
-	err := util.RunCommand("lvcreate", args...)
+	err := c.run("lvcreate", args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func TLVSnapCreate(vgName string, lvName string, tpName string, snapName string) error {
+func (c *Cli) TLVSnapCreate(vgName string, lvName string, tpName string, snapName string) error {
 	args := []string{
 		"--name", snapName,
 		"--type", "thin",
 		"--thinpool", tpName,
 		fmt.Sprintf("%s/%s", vgName, lvName),
 	}
-	err := util.RunCommand("lvcreate", args...)
+	err := c.run("lvcreate", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// LVExtend extends the logical volume to the given size in bytes
+func (c *Cli) LVExtend(vgName string, lvName string, size int64) error {
+	err := c.run("lvextend", "-L", fmt.Sprintf("%dB", size), fmt.Sprintf("%s/%s", vgName, lvName))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// LVExtend100 extends the logical volume by all free space of the volume group
+func (c *Cli) LVExtend100(vgName string, lvName string) error {
+	err := c.run("lvextend", "-l+100%FREE", fmt.Sprintf("%s/%s", vgName, lvName))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// TPExtendMetadata extends the metadata of the thin pool by the given size in bytes
+func (c *Cli) TPExtendMetadata(vgName string, tpName string, size int64) error {
+	err := c.run("lvextend", "--poolmetadatasize", fmt.Sprintf("+%dB", size), fmt.Sprintf("%s/%s", vgName, tpName))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// TPRepair repairs the metadata of an inactive thin pool
+func (c *Cli) TPRepair(vgName string, tpName string) error {
+	err := c.run("lvconvert", "--repair", fmt.Sprintf("%s/%s", vgName, tpName))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Cli) LVRemove(vgName string, lvName string) error {
+	err := c.run("lvremove", fmt.Sprintf("%s/%s", vgName, lvName), "-f")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Cli) LVRename(vgName string, lvName string, newLvName string) error {
+	err := c.run("lvrename", vgName, lvName, newLvName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Cli) LVAddTag(vgName string, lvName string, tag string) error {
+	err := c.run("lvchange", "--addtag", tag, fmt.Sprintf("%s/%s", vgName, lvName))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func LVRemove(vgName string, lvName string) error {
-	err := util.RunCommand("lvremove", fmt.Sprintf("%s/%s", vgName, lvName), "-f")
+func (c *Cli) LVDelTag(vgName string, lvName string, tag string) error {
+	err := c.run("lvchange", "--deltag", tag, fmt.Sprintf("%s/%s", vgName, lvName))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func LVActivate(vgName string, lvName string, activate bool) error {
+func (c *Cli) LVActivate(vgName string, lvName string, activate bool) error {
 	args := []string{
 		"-K",
 	}
@@ -234,15 +322,19 @@ func LVActivate(vgName string, lvName string, activate bool) error {
 		args = append(args, "-an")
 	}
 	args = append(args, fmt.Sprintf("%s/%s", vgName, lvName))
-	err := util.RunCommand("lvchange", args...)
+	err := c.run("lvchange", args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func FindPVLVs(pvName string) ([]LVEntry, error) {
-	pvs, err := ListPVs()
+func (c *Cli) FindPVLVs(pvName string) ([]LVEntry, error) {
+	if len(c.devices) != 0 {
+		return c.findScopedPVLVs(pvName)
+	}
+
+	pvs, err := c.ListPVs()
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +353,7 @@ func FindPVLVs(pvName string) ([]LVEntry, error) {
 		return nil, fmt.Errorf("physical volume %s seems to not have a volume group", pvName)
 	}
 
-	vgs, err := ListVGs()
+	vgs, err := c.ListVGs()
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +369,7 @@ func FindPVLVs(pvName string) ([]LVEntry, error) {
 		return nil, fmt.Errorf("volume group %s not found in list of volume groups", foundPv.VgName)
 	}
 
-	lvs, err := ListLVs()
+	lvs, err := c.ListLVs()
 	if err != nil {
 		return nil, err
 	}
@@ -290,3 +382,36 @@ func FindPVLVs(pvName string) ([]LVEntry, error) {
 	}
 	return ret, nil
 }
+
+// ParseSize parses sizes as reported by the list functions, which always report in bytes
+func ParseSize(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ParseTime parses the lv_time column
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05 -0700", s)
+}
+
+// ParsePercent parses percentages as reported by the list functions. Empty values are treated as 0.
+func ParsePercent(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// findScopedPVLVs only needs a single lvs call, as the scoped backend only sees the volume group of its devices
+func (c *Cli) findScopedPVLVs(pvName string) ([]LVEntry, error) {
+	if !slices.Contains(c.devices, pvName) {
+		return nil, fmt.Errorf("physical volume %s is not part of the scoped devices", pvName)
+	}
+	lvs, err := c.ListLVs()
+	if err != nil {
+		return nil, err
+	}
+	if len(lvs) == 0 {
+		return nil, fmt.Errorf("physical volume %s seems to not have any logical volumes", pvName)
+	}
+	return lvs, nil
+}
@@ -0,0 +1,88 @@
+package lvm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// BlockRange is a range of bytes on a thin device
+type BlockRange struct {
+	Begin  int64
+	Length int64
+}
+
+// ThinDelta returns all byte ranges that differ between the two thin devices of the given pool. poolDmName is the
+// device mapper name of the pool (<vg>-<pool>-tpool) and metadataDev the pool's metadata device. As the pool is in
+// use, thin_delta works on a metadata snapshot, which is reserved for the duration of the call.
+func ThinDelta(poolDmName string, metadataDev string, thinId1 string, thinId2 string) ([]BlockRange, error) {
+	err := util.RunCommand("dmsetup", "message", poolDmName, "0", "reserve_metadata_snap")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = util.RunCommand("dmsetup", "message", poolDmName, "0", "release_metadata_snap")
+	}()
+
+	stdout, err := util.RunCommandStdout("thin_delta", "--metadata-snap", "--snap1", thinId1, "--snap2", thinId2, metadataDev)
+	if err != nil {
+		return nil, err
+	}
+	return parseThinDelta(string(stdout))
+}
+
+// parseThinDelta parses the xml output of thin_delta. Ranges are reported in data blocks, which are converted to bytes
+// with the data block size (in 512 byte sectors) of the superblock.
+func parseThinDelta(s string) ([]BlockRange, error) {
+	d := xml.NewDecoder(strings.NewReader(s))
+
+	var blockSize int64
+	var ret []BlockRange
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, a := range se.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+
+		switch se.Name.Local {
+		case "superblock":
+			sectors, err := strconv.ParseInt(attrs["data_block_size"], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid data_block_size in thin_delta output: %w", err)
+			}
+			blockSize = sectors * 512
+		case "different", "left_only", "right_only":
+			if blockSize == 0 {
+				return nil, fmt.Errorf("thin_delta output has no superblock")
+			}
+			begin, err := strconv.ParseInt(attrs["begin"], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			length, err := strconv.ParseInt(attrs["length"], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, BlockRange{
+				Begin:  begin * blockSize,
+				Length: length * blockSize,
+			})
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,201 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values that were encrypted by a Keyring. Values are stored as
+// "dvenc:v1:<master key id>:<wrapped data key>:<ciphertext>"
+const encryptedPrefix = "dvenc:v1:"
+
+const keySize = 32
+
+// Keyring implements envelope encryption. Each value is encrypted with its own random data key, which is then
+// wrapped (encrypted) with the current master key. Previous master keys are only used to unwrap data keys, which
+// allows rotating the master key without touching the encrypted values themselves.
+type Keyring struct {
+	currentId string
+	keys      map[string][]byte
+}
+
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{
+		keys: map[string][]byte{},
+	}
+	for _, key := range append([][]byte{current}, previous...) {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("invalid master key size %d, must be %d bytes", len(key), keySize)
+		}
+		k.keys[keyId(key)] = key
+	}
+	k.currentId = keyId(current)
+	return k, nil
+}
+
+// ParseKey decodes a base64 encoded master key, e.g. generated via "openssl rand -base64 32"
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid master key size %d, must be %d bytes", len(key), keySize)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random master key in the format expected by ParseKey
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func keyId(key []byte) string {
+	h := sha256.Sum256(key)
+	return hex.EncodeToString(h[:8])
+}
+
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encryptedPrefix)
+}
+
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.keys[k.currentId], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return encode(k.currentId, wrappedKey, ciphertext), nil
+}
+
+// Decrypt decrypts a value that was encrypted by Encrypt. Values that are not encrypted are returned as is, so that
+// rows which were not migrated yet stay readable.
+func (k *Keyring) Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	id, wrappedKey, ciphertext, err := decode(s)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(id, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap encrypts plaintext values and re-wraps the data key of values that were encrypted with a previous master
+// key. It returns false if the value is already encrypted with the current master key.
+func (k *Keyring) Rewrap(s string) (string, bool, error) {
+	if !IsEncrypted(s) {
+		ret, err := k.Encrypt(s)
+		if err != nil {
+			return "", false, err
+		}
+		return ret, true, nil
+	}
+	id, wrappedKey, ciphertext, err := decode(s)
+	if err != nil {
+		return "", false, err
+	}
+	if id == k.currentId {
+		return s, false, nil
+	}
+	dataKey, err := k.unwrap(id, wrappedKey)
+	if err != nil {
+		return "", false, err
+	}
+	wrappedKey, err = seal(k.keys[k.currentId], dataKey)
+	if err != nil {
+		return "", false, err
+	}
+	return encode(k.currentId, wrappedKey, ciphertext), true, nil
+}
+
+func (k *Keyring) unwrap(id string, wrappedKey []byte) ([]byte, error) {
+	masterKey, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("value was encrypted with unknown master key %s", id)
+	}
+	dataKey, err := open(masterKey, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func encode(id string, wrappedKey []byte, ciphertext []byte) string {
+	return encryptedPrefix + id + ":" + base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" + base64.RawStdEncoding.EncodeToString(ciphertext)
+}
+
+func decode(s string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(s, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("invalid encrypted value")
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	return parts[0], wrappedKey, ciphertext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, b []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+}
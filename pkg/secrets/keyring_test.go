@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func mustKeyring(t *testing.T, current []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestRoundTrip(t *testing.T) {
+	k := mustKeyring(t, testKey(1))
+
+	e, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(e) || strings.Contains(e, "secret") {
+		t.Fatalf("value was not encrypted: %s", e)
+	}
+
+	e2, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e == e2 {
+		t.Fatal("encrypting the same value twice must not result in the same ciphertext")
+	}
+
+	d, err := k.Decrypt(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != "secret" {
+		t.Fatalf("got %q, want %q", d, "secret")
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	k := mustKeyring(t, testKey(1))
+
+	d, err := k.Decrypt("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != "plain" {
+		t.Fatalf("got %q, want %q", d, "plain")
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	e, err := mustKeyring(t, testKey(1)).Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mustKeyring(t, testKey(2)).Decrypt(e)
+	if err == nil {
+		t.Fatal("decrypting with the wrong key must fail")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	k := mustKeyring(t, testKey(1))
+	e, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, wrappedKey, ciphertext, err := decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = k.Decrypt(encode(id, wrappedKey, ciphertext))
+	if err == nil {
+		t.Fatal("decrypting a tampered value must fail")
+	}
+
+	_, err = k.Decrypt(encryptedPrefix + "invalid")
+	if err == nil {
+		t.Fatal("decrypting an invalid value must fail")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKey := testKey(1)
+	newKey := testKey(2)
+
+	e, err := mustKeyring(t, oldKey).Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotating := mustKeyring(t, newKey, oldKey)
+	r, changed, err := rotating.Rewrap(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("value encrypted with the previous key was not re-wrapped")
+	}
+
+	_, changed, err = rotating.Rewrap(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("value encrypted with the current key must not be re-wrapped again")
+	}
+
+	// after rotation, the old key is not needed anymore
+	d, err := mustKeyring(t, newKey).Decrypt(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != "secret" {
+		t.Fatalf("got %q, want %q", d, "secret")
+	}
+	_, err = mustKeyring(t, oldKey).Decrypt(r)
+	if err == nil {
+		t.Fatal("re-wrapped value must not be decryptable with the old key")
+	}
+
+	_, _, err = mustKeyring(t, newKey).Rewrap(e)
+	if err == nil {
+		t.Fatal("re-wrapping without the previous key must fail")
+	}
+}
+
+func TestRewrapPlaintext(t *testing.T) {
+	k := mustKeyring(t, testKey(1))
+
+	r, changed, err := k.Rewrap("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || !IsEncrypted(r) {
+		t.Fatal("plaintext value was not encrypted")
+	}
+	d, err := k.Decrypt(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != "plain" {
+		t.Fatalf("got %q, want %q", d, "plain")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	s, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(s + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != keySize {
+		t.Fatalf("got key size %d", len(key))
+	}
+
+	_, err = ParseKey("dG9vIHNob3J0")
+	if err == nil {
+		t.Fatal("parsing a short key must fail")
+	}
+	_, err = NewKeyring([]byte("short"))
+	if err == nil {
+		t.Fatal("creating a keyring with a short key must fail")
+	}
+}
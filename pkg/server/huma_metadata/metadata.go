@@ -9,9 +9,21 @@ const SkipAuth = "skip-auth"
 const NeedAdmin = "need-admin"
 const NoToken = "no-token"
 
+// NeedRepositoryRole is evaluated by the repository middleware. Operations without it require the viewer role.
+const NeedRepositoryRole = "need-repository-role"
+
+// NeedAllVolumes is evaluated by the repository middleware and rejects tokens that are limited to single volumes
+const NeedAllVolumes = "need-all-volumes"
+
 func NeedAdminModifier() func(o *huma.Operation) {
 	return huma_utils.MetadataModifier(NeedAdmin, true)
 }
 func NoTokenModifier() func(o *huma.Operation) {
 	return huma_utils.MetadataModifier(NoToken, true)
 }
+func NeedRepositoryRoleModifier(role string) func(o *huma.Operation) {
+	return huma_utils.MetadataModifier(NeedRepositoryRole, role)
+}
+func NeedAllVolumesModifier() func(o *huma.Operation) {
+	return huma_utils.MetadataModifier(NeedAllVolumes, true)
+}
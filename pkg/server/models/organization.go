@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+)
+
+type Organization struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Name string `json:"name"`
+}
+
+type CreateOrganization struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMember struct {
+	UserId string `json:"userId"`
+	EMail  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+type CreateOrganizationMember struct {
+	UserId *string `json:"userId,omitempty"`
+	EMail  *string `json:"email,omitempty"`
+	Role   string  `json:"role"`
+}
+
+func OrganizationFromDB(v dmodel.Organization) Organization {
+	return Organization{
+		ID:        v.ID,
+		CreatedAt: v.CreatedAt,
+		Name:      v.Name,
+	}
+}
+
+func OrganizationMemberFromDB(v dmodel.OrganizationMember, u *dmodel.User) OrganizationMember {
+	ret := OrganizationMember{
+		UserId: v.UserId,
+		Role:   v.Role,
+	}
+	if u != nil {
+		ret.EMail = u.Email
+		ret.Name = u.Name
+	}
+	return ret
+}
@@ -10,6 +10,8 @@ type Repository struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
 
+	OrganizationId *int64 `json:"organizationId,omitempty"`
+
 	Uuid string `json:"uuid"`
 
 	S3 *RepositoryStorageS3 `json:"s3"`
@@ -25,11 +27,13 @@ type RepositoryStorageS3 struct {
 }
 
 type RepositoryBackupRustic struct {
-	Password string `json:"password"`
+	// Password is only returned to operators and owners
+	Password string `json:"password,omitempty"`
 }
 
 type CreateRepository struct {
-	Name string `json:"name"`
+	Name           string `json:"name"`
+	OrganizationId *int64 `json:"organizationId,omitempty"`
 
 	S3 *CreateRepositoryStorageS3 `json:"s3"`
 
@@ -49,6 +53,19 @@ type CreateRepositoryBackupRustic struct {
 	Password string `json:"password"`
 }
 
+type RepositoryPruneLockRequest struct {
+	PrevLockId *string `json:"prevLockId"`
+}
+
+type RepositoryPruneUnlockRequest struct {
+	LockId string `json:"lockId"`
+}
+
+type RepositoryPruneLock struct {
+	LockId   string `json:"lockId"`
+	LockTime int64  `json:"lockTime"`
+}
+
 type UpdateRepository struct {
 	S3     *UpdateRepositoryStorageS3    `json:"s3"`
 	Rustic *UpdateRepositoryBackupRustic `json:"rustic"`
@@ -67,11 +84,37 @@ type UpdateRepositoryBackupRustic struct {
 	Password *string `json:"password,omitempty"`
 }
 
-func RepositoryFromDB(v dmodel.Repository) Repository {
+type RepositoryAccess struct {
+	UserId string `json:"userId"`
+	EMail  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+type CreateRepositoryAccess struct {
+	UserId *string `json:"userId,omitempty"`
+	EMail  *string `json:"email,omitempty"`
+	Role   string  `json:"role"`
+}
+
+func RepositoryAccessFromDB(v dmodel.RepositoryAccess, u *dmodel.User) RepositoryAccess {
+	ret := RepositoryAccess{
+		UserId: v.UserId,
+		Role:   v.Role,
+	}
+	if u != nil {
+		ret.EMail = u.Email
+		ret.Name = u.Name
+	}
+	return ret
+}
+
+func RepositoryFromDB(v dmodel.Repository, withPassword bool) Repository {
 	ret := Repository{
-		ID:        v.ID,
-		CreatedAt: v.CreatedAt,
-		Uuid:      v.Uuid,
+		ID:             v.ID,
+		CreatedAt:      v.CreatedAt,
+		OrganizationId: v.OrganizationId,
+		Uuid:           v.Uuid,
 	}
 	if v.S3 != nil {
 		ret.S3 = &RepositoryStorageS3{
@@ -82,8 +125,9 @@ func RepositoryFromDB(v dmodel.Repository) Repository {
 		}
 	}
 	if v.Rustic != nil {
-		ret.Rustic = &RepositoryBackupRustic{
-			Password: v.Rustic.Password.V,
+		ret.Rustic = &RepositoryBackupRustic{}
+		if withPassword {
+			ret.Rustic.Password = v.Rustic.Password.V
 		}
 	}
 	return ret
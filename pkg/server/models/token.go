@@ -11,10 +11,29 @@ type Token struct {
 	CreatedAt time.Time `json:"createdAt"`
 
 	Name string `json:"name"`
+
+	Scope     string          `json:"scope"`
+	Resources []TokenResource `json:"resources,omitempty"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIp *string    `json:"lastUsedIp,omitempty"`
+}
+
+// TokenResource references a whole repository or, if VolumeId is set, a single volume of the repository
+type TokenResource struct {
+	RepositoryId int64  `json:"repositoryId"`
+	VolumeId     *int64 `json:"volumeId,omitempty"`
 }
 
 type CreateToken struct {
 	Name string `json:"name"`
+
+	// Scope is one of read-only, serve or admin. Defaults to admin, which gives the token the full rights of its user.
+	Scope string `json:"scope,omitempty"`
+	// Resources limits the token to the given repositories and volumes. If empty, the token is not limited.
+	Resources []TokenResource `json:"resources,omitempty"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
 }
 
 type CreateTokenResult struct {
@@ -24,9 +43,20 @@ type CreateTokenResult struct {
 }
 
 func TokenFromDB(v dmodel.Token) Token {
-	return Token{
-		ID:        v.ID,
-		CreatedAt: v.CreatedAt,
-		Name:      v.Name,
+	ret := Token{
+		ID:         v.ID,
+		CreatedAt:  v.CreatedAt,
+		Name:       v.Name,
+		Scope:      v.Scope,
+		ExpiresAt:  v.ExpiresAt,
+		LastUsedAt: v.LastUsedAt,
+		LastUsedIp: v.LastUsedIp,
+	}
+	for _, r := range v.Resources {
+		ret.Resources = append(ret.Resources, TokenResource{
+			RepositoryId: r.RepositoryID,
+			VolumeId:     r.VolumeID,
+		})
 	}
+	return ret
 }
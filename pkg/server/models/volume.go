@@ -18,31 +18,140 @@ type Volume struct {
 	FsSize int64  `json:"fsSize"`
 	FsType string `json:"fsType"`
 
+	Encryption string `json:"encryption"`
+
 	LockId   *string `json:"lockId,omitempty"`
 	LockTime *int64  `json:"lockTime,omitempty"`
+	LockTtl  int64   `json:"lockTtl"`
+
+	LockHolder *VolumeLockHolder `json:"lockHolder,omitempty"`
+
+	LockBrokenBy     *string    `json:"lockBrokenBy,omitempty"`
+	LockBrokenAt     *time.Time `json:"lockBrokenAt,omitempty"`
+	LockBrokenReason *string    `json:"lockBrokenReason,omitempty"`
+
+	LastBackupAt *time.Time `json:"lastBackupAt,omitempty"`
+
+	Retention     VolumeRetention     `json:"retention"`
+	SnapshotHooks VolumeSnapshotHooks `json:"snapshotHooks"`
+}
+
+type VolumeRetention struct {
+	KeepLast    *int64 `json:"keepLast,omitempty"`
+	KeepHourly  *int64 `json:"keepHourly,omitempty"`
+	KeepDaily   *int64 `json:"keepDaily,omitempty"`
+	KeepWeekly  *int64 `json:"keepWeekly,omitempty"`
+	KeepMonthly *int64 `json:"keepMonthly,omitempty"`
+}
+
+type VolumeSnapshotHooks struct {
+	// Freeze runs fsfreeze on the mounted filesystem while the snapshot is taken
+	Freeze bool `json:"freeze"`
+
+	// PreSnapshot and PostSnapshot are either shell commands or http(s) URLs, which receive a POST request
+	PreSnapshot  *string `json:"preSnapshot,omitempty"`
+	PostSnapshot *string `json:"postSnapshot,omitempty"`
+
+	// Timeout in seconds for each hook
+	Timeout *int64 `json:"timeout,omitempty"`
 }
 
 type CreateVolume struct {
 	Name   string `json:"name"`
 	FsSize int64  `json:"fsSize"`
 	FsType string `json:"fsType"`
+
+	// Encryption is one of none, server-key and local-key. Defaults to none.
+	Encryption string `json:"encryption,omitempty"`
+
+	LockTtl       *int64               `json:"lockTtl,omitempty"`
+	Retention     *VolumeRetention     `json:"retention,omitempty"`
+	SnapshotHooks *VolumeSnapshotHooks `json:"snapshotHooks,omitempty"`
+}
+
+type UpdateVolume struct {
+	FsSize        *int64               `json:"fsSize,omitempty"`
+	LockTtl       *int64               `json:"lockTtl,omitempty"`
+	Retention     *VolumeRetention     `json:"retention,omitempty"`
+	SnapshotHooks *VolumeSnapshotHooks `json:"snapshotHooks,omitempty"`
+}
+
+type VolumeEncryptionKey struct {
+	Key string `json:"key"`
+}
+
+type VolumeEncryptionKeyRequest struct {
+	LockId string `json:"lockId"`
+}
+
+type VolumeLockHolder struct {
+	UserId        *string `json:"userId,omitempty"`
+	Hostname      *string `json:"hostname,omitempty"`
+	Pid           *int64  `json:"pid,omitempty"`
+	ClientVersion *string `json:"clientVersion,omitempty"`
+	Label         *string `json:"label,omitempty"`
 }
 
 type VolumeLockRequest struct {
 	PrevLockId *string `json:"prevLockId"`
+
+	Hostname      *string `json:"hostname,omitempty"`
+	Pid           *int64  `json:"pid,omitempty"`
+	ClientVersion *string `json:"clientVersion,omitempty"`
+	Label         *string `json:"label,omitempty"`
+}
+
+type VolumeUnlockRequest struct {
+	LockId string `json:"lockId"`
+}
+
+type VolumeForceUnlockRequest struct {
+	Reason string `json:"reason"`
 }
 
 func VolumeFromDB(v dmodel.Volume) Volume {
 	ret := Volume{
-		ID:           v.ID,
-		CreatedAt:    v.CreatedAt,
-		Name:         v.Name,
-		Uuid:         v.Uuid,
-		RepositoryID: v.RepositoryID,
-		FsSize:       v.FsSize,
-		FsType:       v.FsType,
-		LockId:       v.LockId,
-		LockTime:     v.LockTime,
+		ID:               v.ID,
+		CreatedAt:        v.CreatedAt,
+		Name:             v.Name,
+		Uuid:             v.Uuid,
+		RepositoryID:     v.RepositoryID,
+		FsSize:           v.FsSize,
+		FsType:           v.FsType,
+		Encryption:       v.Encryption,
+		LockId:           v.LockId,
+		LockTime:         v.LockTime,
+		LockTtl:          v.LockTtl,
+		LockBrokenBy:     v.LockBrokenBy,
+		LockBrokenAt:     v.LockBrokenAt,
+		LockBrokenReason: v.LockBrokenReason,
+		LastBackupAt:     v.LastBackupAt,
+		Retention: VolumeRetention{
+			KeepLast:    v.KeepLast,
+			KeepHourly:  v.KeepHourly,
+			KeepDaily:   v.KeepDaily,
+			KeepWeekly:  v.KeepWeekly,
+			KeepMonthly: v.KeepMonthly,
+		},
+		SnapshotHooks: VolumeSnapshotHooks{
+			Freeze:       v.SnapshotFreeze,
+			PreSnapshot:  v.PreSnapshotHook,
+			PostSnapshot: v.PostSnapshotHook,
+			Timeout:      v.SnapshotHookTimeout,
+		},
+	}
+	if v.LockId != nil {
+		ret.LockHolder = &VolumeLockHolder{
+			UserId:        v.LockUserId,
+			Hostname:      v.LockHostname,
+			Pid:           v.LockPid,
+			ClientVersion: v.LockClientVersion,
+			Label:         v.LockLabel,
+		}
 	}
 	return ret
 }
+
+func (r *VolumeRetention) IsEmpty() bool {
+	return r.KeepLast == nil && r.KeepHourly == nil && r.KeepDaily == nil && r.KeepWeekly == nil && r.KeepMonthly == nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+)
+
+type VolumeBackup struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	VolumeID int64 `json:"volumeId"`
+
+	SnapshotId   string    `json:"snapshotId"`
+	SnapshotTime time.Time `json:"snapshotTime"`
+	Hostname     string    `json:"hostname"`
+	DurationMs   int64     `json:"durationMs"`
+
+	Summary       VolumeBackupSummary       `json:"summary"`
+	SnapshotHooks VolumeBackupSnapshotHooks `json:"snapshotHooks"`
+}
+
+type VolumeBackupSummary struct {
+	TotalFiles      int64 `json:"totalFiles"`
+	TotalBytes      int64 `json:"totalBytes"`
+	FilesNew        int64 `json:"filesNew"`
+	FilesChanged    int64 `json:"filesChanged"`
+	FilesUnmodified int64 `json:"filesUnmodified"`
+	DirsNew         int64 `json:"dirsNew"`
+	DirsChanged     int64 `json:"dirsChanged"`
+	DirsUnmodified  int64 `json:"dirsUnmodified"`
+	DataAdded       int64 `json:"dataAdded"`
+	DataAddedPacked int64 `json:"dataAddedPacked"`
+}
+
+type VolumeBackupSnapshotHooks struct {
+	Frozen bool `json:"frozen"`
+
+	PreSnapshotError  *string `json:"preSnapshotError,omitempty"`
+	PostSnapshotError *string `json:"postSnapshotError,omitempty"`
+}
+
+type CreateVolumeBackup struct {
+	LockId string `json:"lockId"`
+
+	SnapshotId   string    `json:"snapshotId"`
+	SnapshotTime time.Time `json:"snapshotTime"`
+	Hostname     string    `json:"hostname"`
+	DurationMs   int64     `json:"durationMs"`
+
+	Summary       VolumeBackupSummary       `json:"summary"`
+	SnapshotHooks VolumeBackupSnapshotHooks `json:"snapshotHooks"`
+}
+
+func VolumeBackupFromDB(v dmodel.VolumeBackup) VolumeBackup {
+	return VolumeBackup{
+		ID:           v.ID,
+		CreatedAt:    v.CreatedAt,
+		VolumeID:     v.VolumeID,
+		SnapshotId:   v.SnapshotId,
+		SnapshotTime: v.SnapshotTime,
+		Hostname:     v.Hostname,
+		DurationMs:   v.DurationMs,
+		Summary: VolumeBackupSummary{
+			TotalFiles:      v.TotalFiles,
+			TotalBytes:      v.TotalBytes,
+			FilesNew:        v.FilesNew,
+			FilesChanged:    v.FilesChanged,
+			FilesUnmodified: v.FilesUnmodified,
+			DirsNew:         v.DirsNew,
+			DirsChanged:     v.DirsChanged,
+			DirsUnmodified:  v.DirsUnmodified,
+			DataAdded:       v.DataAdded,
+			DataAddedPacked: v.DataAddedPacked,
+		},
+		SnapshotHooks: VolumeBackupSnapshotHooks{
+			Frozen:            v.Frozen,
+			PreSnapshotError:  v.PreSnapshotHookError,
+			PostSnapshotError: v.PostSnapshotHookError,
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+)
+
+type VolumeLockEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	VolumeID int64 `json:"volumeId"`
+
+	Event  string `json:"event"`
+	LockId string `json:"lockId"`
+
+	// Holder describes the holder of the lock. For stolen events, it only contains the user that broke the lock.
+	Holder VolumeLockHolder `json:"holder"`
+	Reason *string          `json:"reason,omitempty"`
+}
+
+func VolumeLockEventFromDB(v dmodel.VolumeLockEvent) VolumeLockEvent {
+	return VolumeLockEvent{
+		ID:        v.ID,
+		CreatedAt: v.CreatedAt,
+		VolumeID:  v.VolumeID,
+		Event:     v.Event,
+		LockId:    v.LockId,
+		Holder: VolumeLockHolder{
+			UserId:        v.UserId,
+			Hostname:      v.Hostname,
+			Pid:           v.Pid,
+			ClientVersion: v.ClientVersion,
+			Label:         v.Label,
+		},
+		Reason: v.Reason,
+	}
+}
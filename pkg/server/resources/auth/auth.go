@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"reflect"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/danielgtaylor/huma/v2"
@@ -23,6 +25,9 @@ import (
 
 const TokenPrefix = "dvt_"
 
+// the last used time of tokens is only updated in this interval to avoid a write on every request
+const tokenLastUsedInterval = time.Minute
+
 type AuthHandler struct {
 	config config.Config
 
@@ -143,7 +148,7 @@ func (s *AuthHandler) AuthMiddleware(api huma.API) func(ctx huma.Context, next f
 			next(ctx)
 			return
 		}
-		noToken := huma_utils.HasMetadataTrue(ctx, huma_metadata.SkipAuth)
+		noToken := huma_utils.HasMetadataTrue(ctx, huma_metadata.NoToken)
 
 		authz, err := GetAuthorizationToken(ctx)
 		if err != nil {
@@ -152,12 +157,13 @@ func (s *AuthHandler) AuthMiddleware(api huma.API) func(ctx huma.Context, next f
 		}
 
 		var user *models.User
+		var token *dmodel.Token
 		if strings.HasPrefix(authz, TokenPrefix) {
 			if noToken {
-				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, err.Error(), err)
+				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "tokens can not be used for this operation")
 				return
 			}
-			user, err = s.checkDboxedToken(ctx, authz)
+			user, token, err = s.checkDboxedToken(ctx, authz)
 			if err != nil {
 				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, err.Error(), err)
 				return
@@ -176,30 +182,53 @@ func (s *AuthHandler) AuthMiddleware(api huma.API) func(ctx huma.Context, next f
 		}
 
 		ctx = huma.WithValue(ctx, "user", user)
+		if token != nil {
+			ctx = huma.WithValue(ctx, "token", token)
+		}
 
 		if huma_utils.HasMetadataTrue(ctx, huma_metadata.NeedAdmin) {
 			if !user.IsAdmin {
 				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "must be admin")
 				return
 			}
+			if token != nil && !token.IsUnrestricted() {
+				_ = huma.WriteErr(api, ctx, http.StatusForbidden, "restricted tokens can not be used for admin operations")
+				return
+			}
 		}
 
 		next(ctx)
 	}
 }
 
-func (s *AuthHandler) checkDboxedToken(ctx huma.Context, authz string) (*models.User, error) {
+func (s *AuthHandler) checkDboxedToken(ctx huma.Context, authz string) (*models.User, *dmodel.Token, error) {
 	q := querier.GetQuerier(ctx.Context())
 	t, err := dmodel.GetTokenByToken(q, authz)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if t.IsExpired() {
+		return nil, nil, fmt.Errorf("token expired")
 	}
+
+	now := time.Now()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= tokenLastUsedInterval {
+		ip, _, err := net.SplitHostPort(ctx.RemoteAddr())
+		if err != nil {
+			ip = ctx.RemoteAddr()
+		}
+		err = t.UpdateLastUsed(q, now, ip)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	isAdmin := false
 	if slices.Contains(s.config.Auth.AdminUsers, t.UserID) {
 		isAdmin = true
 	}
 	u := models.UserFromDB(*t.User, isAdmin)
-	return &u, nil
+	return &u, t, nil
 }
 
 func (s *AuthHandler) checkOidcToken(ctx huma.Context, authz string) (*models.User, error) {
@@ -258,6 +287,12 @@ func GetUser(ctx context.Context) *models.User {
 	return user
 }
 
+// GetToken returns the token used to authenticate the request or nil if it was authenticated via OIDC
+func GetToken(ctx context.Context) *dmodel.Token {
+	t, _ := ctx.Value("token").(*dmodel.Token)
+	return t
+}
+
 func MustGetUser(ctx context.Context) models.User {
 	user := GetUser(ctx)
 	if user == nil {
@@ -271,5 +306,8 @@ func EnsureAdmin(c context.Context) error {
 	if !u.IsAdmin {
 		return huma.Error401Unauthorized("must be an admin")
 	}
+	if t := GetToken(c); t != nil && !t.IsUnrestricted() {
+		return huma.Error403Forbidden("restricted tokens can not be used for admin operations")
+	}
 	return nil
 }
@@ -0,0 +1,267 @@
+package organizations
+
+import (
+	"context"
+	"slices"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/dboxed/dboxed-common/huma_utils"
+	"github.com/dboxed/dboxed-common/util"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/auth"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/users"
+)
+
+type Organizations struct {
+}
+
+func New() *Organizations {
+	return &Organizations{}
+}
+
+func (s *Organizations) Init(api huma.API) error {
+	huma.Get(api, "/v1/organizations", s.restListOrganizations)
+
+	huma.Post(api, "/v1/admin/organizations", s.restCreateOrganization, huma_metadata.NeedAdminModifier())
+	huma.Get(api, "/v1/admin/organizations", s.restAdminListOrganizations, huma_metadata.NeedAdminModifier())
+	huma.Get(api, "/v1/admin/organizations/{id}", s.restGetOrganization, huma_metadata.NeedAdminModifier())
+	huma.Delete(api, "/v1/admin/organizations/{id}", s.restDeleteOrganization, huma_metadata.NeedAdminModifier())
+
+	huma.Get(api, "/v1/admin/organizations/{id}/members", s.restListOrganizationMembers, huma_metadata.NeedAdminModifier())
+	huma.Post(api, "/v1/admin/organizations/{id}/members", s.restCreateOrganizationMember, huma_metadata.NeedAdminModifier())
+	huma.Delete(api, "/v1/admin/organizations/{id}/members/{userId}", s.restDeleteOrganizationMember, huma_metadata.NeedAdminModifier())
+
+	return nil
+}
+
+func (s *Organizations) restListOrganizations(ctx context.Context, i *struct{}) (*huma_utils.List[models.Organization], error) {
+	q := querier.GetQuerier(ctx)
+	user := auth.MustGetUser(ctx)
+
+	l, err := dmodel.ListOrganizationsForUser(q, user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationList(l), nil
+}
+
+func (s *Organizations) restAdminListOrganizations(ctx context.Context, i *struct{}) (*huma_utils.List[models.Organization], error) {
+	q := querier.GetQuerier(ctx)
+
+	l, err := dmodel.ListOrganizations(q, true)
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationList(l), nil
+}
+
+func toOrganizationList(l []dmodel.Organization) *huma_utils.List[models.Organization] {
+	var ret []models.Organization
+	for _, o := range l {
+		ret = append(ret, models.OrganizationFromDB(o))
+	}
+	return huma_utils.NewList(ret, len(ret))
+}
+
+func (s *Organizations) restCreateOrganization(ctx context.Context, i *huma_utils.JsonBody[models.CreateOrganization]) (*huma_utils.JsonBody[models.Organization], error) {
+	q := querier.GetQuerier(ctx)
+
+	err := util.CheckName(i.Body.Name)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid name", err)
+	}
+
+	o := dmodel.Organization{
+		Name: i.Body.Name,
+	}
+	err = o.Create(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return huma_utils.NewJsonBody(models.OrganizationFromDB(o)), nil
+}
+
+func (s *Organizations) restGetOrganization(ctx context.Context, i *huma_utils.IdByPath) (*huma_utils.JsonBody[models.Organization], error) {
+	o, err := getOrganization(ctx, i.Id)
+	if err != nil {
+		return nil, err
+	}
+	return huma_utils.NewJsonBody(models.OrganizationFromDB(*o)), nil
+}
+
+func (s *Organizations) restDeleteOrganization(ctx context.Context, i *huma_utils.IdByPath) (*huma_utils.Empty, error) {
+	q := querier.GetQuerier(ctx)
+
+	o, err := getOrganization(ctx, i.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := dmodel.ListRepositoriesByOrganization(q, o.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) != 0 {
+		return nil, huma.Error409Conflict("organization still owns repositories")
+	}
+
+	err = dmodel.SoftDeleteWithConstraintsByIds[dmodel.Organization](q, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &huma_utils.Empty{}, nil
+}
+
+func (s *Organizations) restListOrganizationMembers(ctx context.Context, i *huma_utils.IdByPath) (*huma_utils.List[models.OrganizationMember], error) {
+	q := querier.GetQuerier(ctx)
+
+	o, err := getOrganization(ctx, i.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := dmodel.ListOrganizationMembers(q, o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []models.OrganizationMember
+	for _, m := range l {
+		u, err := dmodel.GetUserById(q, m.UserId)
+		if err != nil && !util.IsSqlNotFoundError(err) {
+			return nil, err
+		}
+		ret = append(ret, models.OrganizationMemberFromDB(m, u))
+	}
+	return huma_utils.NewList(ret, len(ret)), nil
+}
+
+type restCreateOrganizationMemberInput struct {
+	huma_utils.IdByPath
+	huma_utils.JsonBody[models.CreateOrganizationMember]
+}
+
+// restCreateOrganizationMember adds a member or changes the role of an existing member
+func (s *Organizations) restCreateOrganizationMember(ctx context.Context, i *restCreateOrganizationMemberInput) (*huma_utils.JsonBody[models.OrganizationMember], error) {
+	q := querier.GetQuerier(ctx)
+
+	o, err := getOrganization(ctx, i.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dmodel.IsValidRepositoryRole(i.Body.Role) {
+		return nil, huma.Error400BadRequest("invalid role")
+	}
+
+	u, err := users.FindUser(q, i.Body.UserId, i.Body.EMail)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := dmodel.ListOrganizationMembers(q, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(members, func(m dmodel.OrganizationMember) bool {
+		return m.UserId == u.ID
+	})
+	if idx == -1 {
+		m := dmodel.OrganizationMember{
+			OrganizationId: o.ID,
+			UserId:         u.ID,
+			Role:           i.Body.Role,
+		}
+		err = m.Create(q)
+		if err != nil {
+			return nil, err
+		}
+		return huma_utils.NewJsonBody(models.OrganizationMemberFromDB(m, u)), nil
+	}
+
+	m := &members[idx]
+	if i.Body.Role != dmodel.RepositoryRoleOwner {
+		err = checkNotLastOwner(members, m.UserId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = m.UpdateRole(q, i.Body.Role)
+	if err != nil {
+		return nil, err
+	}
+	return huma_utils.NewJsonBody(models.OrganizationMemberFromDB(*m, u)), nil
+}
+
+type restDeleteOrganizationMemberInput struct {
+	huma_utils.IdByPath
+	UserId string `path:"userId"`
+}
+
+// restDeleteOrganizationMember removes a member. The repositories of the organization stay untouched.
+func (s *Organizations) restDeleteOrganizationMember(ctx context.Context, i *restDeleteOrganizationMemberInput) (*huma_utils.Empty, error) {
+	q := querier.GetQuerier(ctx)
+
+	o, err := getOrganization(ctx, i.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dmodel.GetOrganizationMember(q, o.ID, i.UserId)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error404NotFound("organization member not found")
+		}
+		return nil, err
+	}
+
+	members, err := dmodel.ListOrganizationMembers(q, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNotLastOwner(members, i.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dmodel.DeleteOrganizationMember(q, o.ID, i.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &huma_utils.Empty{}, nil
+}
+
+// checkNotLastOwner prevents that an organization loses its last owner, as nobody could create repositories in it
+// anymore
+func checkNotLastOwner(members []dmodel.OrganizationMember, userId string) error {
+	isOwner := false
+	for _, m := range members {
+		if m.Role != dmodel.RepositoryRoleOwner {
+			continue
+		}
+		if m.UserId != userId {
+			return nil
+		}
+		isOwner = true
+	}
+	if !isOwner {
+		return nil
+	}
+	return huma.Error409Conflict("can not remove or demote the last owner of an organization")
+}
+
+func getOrganization(ctx context.Context, id int64) (*dmodel.Organization, error) {
+	q := querier.GetQuerier(ctx)
+	o, err := dmodel.GetOrganizationById(q, id, true)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error404NotFound("organization not found")
+		}
+		return nil, err
+	}
+	return o, nil
+}
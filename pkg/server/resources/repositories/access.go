@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/dboxed/dboxed-common/huma_utils"
+	"github.com/dboxed/dboxed-common/util"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/users"
+)
+
+func (s *Repositories) restListRepositoryAccess(c context.Context, i *RepositoryId) (*huma_utils.List[models.RepositoryAccess], error) {
+	q := querier.GetQuerier(c)
+
+	r, err := checkRepositoryAccess(c, i.RepositoryId, dmodel.RepositoryRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []models.RepositoryAccess
+	for _, ra := range r.Access {
+		u, err := dmodel.GetUserById(q, ra.UserId)
+		if err != nil && !util.IsSqlNotFoundError(err) {
+			return nil, err
+		}
+		ret = append(ret, models.RepositoryAccessFromDB(ra, u))
+	}
+	return huma_utils.NewList(ret, len(ret)), nil
+}
+
+type restCreateRepositoryAccessInput struct {
+	RepositoryId
+	huma_utils.JsonBody[models.CreateRepositoryAccess]
+}
+
+// restCreateRepositoryAccess grants access to a user or changes the role of an existing grant
+func (s *Repositories) restCreateRepositoryAccess(c context.Context, i *restCreateRepositoryAccessInput) (*huma_utils.JsonBody[models.RepositoryAccess], error) {
+	r, err := checkWholeRepositoryAccess(c, i.RepositoryId.RepositoryId, dmodel.RepositoryRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	q := querier.GetQuerier(c)
+
+	if !dmodel.IsValidRepositoryRole(i.Body.Role) {
+		return nil, huma.Error400BadRequest("invalid role")
+	}
+
+	u, err := users.FindUser(q, i.Body.UserId, i.Body.EMail)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(r.Access, func(access dmodel.RepositoryAccess) bool {
+		return access.UserId == u.ID
+	})
+	if idx == -1 {
+		ra := dmodel.RepositoryAccess{
+			RepositoryId: r.ID,
+			UserId:       u.ID,
+			Role:         i.Body.Role,
+		}
+		err = ra.Create(q)
+		if err != nil {
+			return nil, err
+		}
+		return huma_utils.NewJsonBody(models.RepositoryAccessFromDB(ra, u)), nil
+	}
+
+	ra := &r.Access[idx]
+	if ra.Role == dmodel.RepositoryRoleOwner && i.Body.Role != dmodel.RepositoryRoleOwner {
+		err = checkNotLastOwner(r, u.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = ra.UpdateRole(q, i.Body.Role)
+	if err != nil {
+		return nil, err
+	}
+	return huma_utils.NewJsonBody(models.RepositoryAccessFromDB(*ra, u)), nil
+}
+
+type restDeleteRepositoryAccessInput struct {
+	RepositoryId
+	UserId string `path:"userId"`
+}
+
+func (s *Repositories) restDeleteRepositoryAccess(c context.Context, i *restDeleteRepositoryAccessInput) (*huma_utils.Empty, error) {
+	r, err := checkWholeRepositoryAccess(c, i.RepositoryId.RepositoryId, dmodel.RepositoryRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	q := querier.GetQuerier(c)
+
+	idx := slices.IndexFunc(r.Access, func(access dmodel.RepositoryAccess) bool {
+		return access.UserId == i.UserId
+	})
+	if idx == -1 {
+		return nil, huma.Error404NotFound("access grant not found")
+	}
+	if r.Access[idx].Role == dmodel.RepositoryRoleOwner {
+		err = checkNotLastOwner(r, i.UserId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = dmodel.DeleteRepositoryAccess(q, r.ID, i.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &huma_utils.Empty{}, nil
+}
+
+// checkNotLastOwner ensures that a repository never ends up without an owner. Repositories of organizations are
+// owned by the organization instead.
+func checkNotLastOwner(r *dmodel.Repository, userId string) error {
+	if r.OrganizationId != nil {
+		return nil
+	}
+	for _, ra := range r.Access {
+		if ra.UserId != userId && ra.Role == dmodel.RepositoryRoleOwner {
+			return nil
+		}
+	}
+	return huma.Error409Conflict("can not remove the last owner of a repository")
+}
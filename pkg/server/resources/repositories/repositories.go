@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
 	"slices"
 	"strconv"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/dboxed/dboxed-common/db/querier"
@@ -22,6 +24,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// PruneLockTimeout is the time after which a prune lock that was not refreshed is considered stale
+const PruneLockTimeout = time.Minute * 10
+
 type Repositories struct {
 }
 
@@ -36,9 +41,17 @@ func (s *Repositories) Init(api huma.API) error {
 	huma.Get(api, "/v1/repositories", s.restListRepositories)
 	huma.Get(api, "/v1/repositories/{repositoryId}", s.restGetRepository)
 	huma.Get(api, "/v1/repositories/by-name/{repositoryName}", s.restGetRepositoryByName)
+	huma.Get(api, "/v1/repositories/by-name/{organizationName}/{repositoryName}", s.restGetOrganizationRepositoryByName)
 	huma.Patch(api, "/v1/repositories/{repositoryId}", s.restUpdateRepository)
 	huma.Delete(api, "/v1/repositories/{repositoryId}", s.restDeleteRepository)
 
+	huma.Post(api, "/v1/repositories/{repositoryId}/prune-lock", s.restPruneLock)
+	huma.Post(api, "/v1/repositories/{repositoryId}/prune-unlock", s.restPruneUnlock)
+
+	huma.Get(api, "/v1/repositories/{repositoryId}/access", s.restListRepositoryAccess)
+	huma.Post(api, "/v1/repositories/{repositoryId}/access", s.restCreateRepositoryAccess)
+	huma.Delete(api, "/v1/repositories/{repositoryId}/access/{userId}", s.restDeleteRepositoryAccess)
+
 	huma.Get(api, "/v1/admin/repositories", s.restAdminListRepositories, huma_metadata.NeedAdminModifier())
 
 	return nil
@@ -48,6 +61,10 @@ func (s *Repositories) restCreateRepository(ctx context.Context, i *huma_utils.J
 	q := querier.GetQuerier(ctx)
 	user := auth.MustGetUser(ctx)
 
+	if t := auth.GetToken(ctx); t != nil && !t.IsUnrestricted() {
+		return nil, huma.Error403Forbidden("restricted tokens can not create repositories")
+	}
+
 	err := util.CheckName(i.Body.Name)
 	if err != nil {
 		return nil, huma.Error400BadRequest("invalid name", err)
@@ -66,9 +83,17 @@ func (s *Repositories) restCreateRepository(ctx context.Context, i *huma_utils.J
 		}
 	}
 
+	if i.Body.OrganizationId != nil {
+		err = checkOrganizationOwner(ctx, *i.Body.OrganizationId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	r := dmodel.Repository{
-		Uuid: uuid.NewString(),
-		Name: i.Body.Name,
+		OrganizationId: i.Body.OrganizationId,
+		Uuid:           uuid.NewString(),
+		Name:           i.Body.Name,
 	}
 
 	err = r.Create(q)
@@ -76,13 +101,17 @@ func (s *Repositories) restCreateRepository(ctx context.Context, i *huma_utils.J
 		return nil, err
 	}
 
-	ra := dmodel.RepositoryAccess{
-		RepositoryId: r.ID,
-		UserId:       user.ID,
-	}
-	err = ra.Create(q)
-	if err != nil {
-		return nil, err
+	// repositories of organizations are accessed through the membership, so that they don't depend on their creator
+	if r.OrganizationId == nil {
+		ra := dmodel.RepositoryAccess{
+			RepositoryId: r.ID,
+			UserId:       user.ID,
+			Role:         dmodel.RepositoryRoleOwner,
+		}
+		err = ra.Create(q)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if i.Body.S3 != nil {
@@ -122,7 +151,7 @@ func (s *Repositories) restCreateRepository(ctx context.Context, i *huma_utils.J
 		}
 	}
 
-	return huma_utils.NewJsonBody(models.RepositoryFromDB(r)), nil
+	return huma_utils.NewJsonBody(models.RepositoryFromDB(r, true)), nil
 }
 
 func (s *Repositories) restListRepositories(ctx context.Context, i *struct{}) (*huma_utils.List[models.Repository], error) {
@@ -148,9 +177,16 @@ func (s *Repositories) doRestListRepositories(ctx context.Context, i *struct{},
 		return nil, err
 	}
 
+	t := auth.GetToken(ctx)
 	var ret []models.Repository
 	for _, r := range l {
-		mm := models.RepositoryFromDB(r)
+		if t != nil && !t.AllowsRepository(r.ID) {
+			continue
+		}
+		mm, err := repositoryToModel(ctx, &r)
+		if err != nil {
+			return nil, err
+		}
 		ret = append(ret, mm)
 	}
 	return huma_utils.NewList(ret, len(ret)), nil
@@ -161,12 +197,15 @@ type RepositoryId struct {
 }
 
 func (s *Repositories) restGetRepository(c context.Context, i *RepositoryId) (*huma_utils.JsonBody[models.Repository], error) {
-	r, err := checkRepositoryAccess(c, i.RepositoryId)
+	r, err := checkRepositoryAccess(c, i.RepositoryId, dmodel.RepositoryRoleViewer)
 	if err != nil {
 		return nil, err
 	}
 
-	m := models.RepositoryFromDB(*r)
+	m, err := repositoryToModel(c, r)
+	if err != nil {
+		return nil, err
+	}
 	return huma_utils.NewJsonBody(m), nil
 }
 
@@ -175,18 +214,43 @@ type RepositoryName struct {
 }
 
 func (s *Repositories) restGetRepositoryByName(c context.Context, i *RepositoryName) (*huma_utils.JsonBody[models.Repository], error) {
+	return s.doGetRepositoryByName(c, nil, i.RepositoryName)
+}
+
+type OrganizationRepositoryName struct {
+	OrganizationName string `path:"organizationName"`
+	RepositoryName   string `path:"repositoryName"`
+}
+
+func (s *Repositories) restGetOrganizationRepositoryByName(c context.Context, i *OrganizationRepositoryName) (*huma_utils.JsonBody[models.Repository], error) {
+	q := querier.GetQuerier(c)
+
+	o, err := dmodel.GetOrganizationByName(q, i.OrganizationName, true)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error404NotFound("organization not found")
+		}
+		return nil, err
+	}
+	return s.doGetRepositoryByName(c, &o.ID, i.RepositoryName)
+}
+
+func (s *Repositories) doGetRepositoryByName(c context.Context, organizationId *int64, name string) (*huma_utils.JsonBody[models.Repository], error) {
 	q := querier.GetQuerier(c)
 
-	r, err := dmodel.GetRepositoryByName(q, i.RepositoryName, true)
+	r, err := dmodel.GetRepositoryByName(q, organizationId, name, true)
 	if err != nil {
 		return nil, err
 	}
-	_, err = checkRepositoryAccess(c, r.ID)
+	_, err = checkRepositoryAccess(c, r.ID, dmodel.RepositoryRoleViewer)
 	if err != nil {
 		return nil, err
 	}
 
-	m := models.RepositoryFromDB(*r)
+	m, err := repositoryToModel(c, r)
+	if err != nil {
+		return nil, err
+	}
 	return huma_utils.NewJsonBody(m), nil
 }
 
@@ -196,7 +260,7 @@ type restUpdateRepositoryInput struct {
 }
 
 func (s *Repositories) restUpdateRepository(c context.Context, i *restUpdateRepositoryInput) (*huma_utils.JsonBody[models.Repository], error) {
-	r, err := checkRepositoryAccess(c, i.RepositoryId.RepositoryId)
+	r, err := checkWholeRepositoryAccess(c, i.RepositoryId.RepositoryId, dmodel.RepositoryRoleOwner)
 	if err != nil {
 		return nil, err
 	}
@@ -206,7 +270,10 @@ func (s *Repositories) restUpdateRepository(c context.Context, i *restUpdateRepo
 		return nil, err
 	}
 
-	m := models.RepositoryFromDB(*r)
+	m, err := repositoryToModel(c, r)
+	if err != nil {
+		return nil, err
+	}
 
 	return huma_utils.NewJsonBody(m), nil
 }
@@ -272,14 +339,96 @@ func (s *Repositories) doUpdateRepository(c context.Context, r *dmodel.Repositor
 }
 
 func (s *Repositories) restDeleteRepository(c context.Context, i *RepositoryId) (*huma_utils.Empty, error) {
+	_, err := checkWholeRepositoryAccess(c, i.RepositoryId, dmodel.RepositoryRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
 	q := querier.GetQuerier(c)
 
-	_, err := checkRepositoryAccess(c, i.RepositoryId)
+	err = dmodel.SoftDeleteWithConstraintsByIds[dmodel.Repository](q, i.RepositoryId)
 	if err != nil {
 		return nil, err
 	}
 
-	err = dmodel.SoftDeleteWithConstraintsByIds[dmodel.Repository](q, i.RepositoryId)
+	return &huma_utils.Empty{}, nil
+}
+
+type restPruneLockInput struct {
+	RepositoryId
+	Body models.RepositoryPruneLockRequest
+}
+
+func (s *Repositories) restPruneLock(c context.Context, i *restPruneLockInput) (*huma_utils.JsonBody[models.RepositoryPruneLock], error) {
+	r, err := checkWholeRepositoryAccess(c, i.RepositoryId.RepositoryId, dmodel.RepositoryRoleOperator)
+	if err != nil {
+		return nil, err
+	}
+
+	q := querier.GetQuerier(c)
+
+	log := slog.With(slog.Any("repoId", r.ID))
+
+	strConv := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	allow := false
+	lockUuid := ""
+	if r.PruneLockId == nil {
+		allow = true
+		lockUuid = uuid.NewString()
+		log = log.With(slog.Any("newLockId", lockUuid))
+		log.Info("locking repository for prune")
+	} else {
+		if strConv(r.PruneLockId) == strConv(i.Body.PrevLockId) {
+			allow = true
+			lockUuid = *r.PruneLockId
+			log.Info("refreshing prune lock")
+		} else if *r.PruneLockTime+int64(PruneLockTimeout.Seconds()) < time.Now().Unix() {
+			allow = true
+			lockUuid = uuid.NewString()
+			log = log.With(slog.Any("newLockId", lockUuid))
+			log.Info("old prune lock expired, re-locking")
+		}
+	}
+	if !allow {
+		return nil, huma.Error409Conflict("repository is already locked for prune")
+	}
+
+	err = r.UpdatePruneLock(q, &lockUuid, util.Ptr(time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+
+	return huma_utils.NewJsonBody(models.RepositoryPruneLock{
+		LockId:   *r.PruneLockId,
+		LockTime: *r.PruneLockTime,
+	}), nil
+}
+
+type restPruneUnlockInput struct {
+	RepositoryId
+	Body models.RepositoryPruneUnlockRequest
+}
+
+func (s *Repositories) restPruneUnlock(c context.Context, i *restPruneUnlockInput) (*huma_utils.Empty, error) {
+	r, err := checkWholeRepositoryAccess(c, i.RepositoryId.RepositoryId, dmodel.RepositoryRoleOperator)
+	if err != nil {
+		return nil, err
+	}
+
+	q := querier.GetQuerier(c)
+
+	if r.PruneLockId == nil || *r.PruneLockId != i.Body.LockId {
+		return nil, huma.Error409Conflict("prune lock is not held by the given lock id")
+	}
+
+	slog.Info("unlocking repository after prune", slog.Any("repoId", r.ID), slog.Any("lockId", i.Body.LockId))
+	err = r.UpdatePruneLock(q, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -287,7 +436,10 @@ func (s *Repositories) restDeleteRepository(c context.Context, i *RepositoryId)
 	return &huma_utils.Empty{}, nil
 }
 
-func checkRepositoryAccess(ctx context.Context, id int64) (*dmodel.Repository, error) {
+// checkRepositoryAccess verifies that the current user has at least the given role on the repository. Admins are
+// treated as owners of all repositories. When a token is used, its scope limits the role and its resources limit the
+// accessible repositories.
+func checkRepositoryAccess(ctx context.Context, id int64, role string) (*dmodel.Repository, error) {
 	q := querier.GetQuerier(ctx)
 	user := auth.MustGetUser(ctx)
 
@@ -299,17 +451,120 @@ func checkRepositoryAccess(ctx context.Context, id int64) (*dmodel.Repository, e
 		return nil, err
 	}
 
-	if !user.IsAdmin {
-		if !slices.ContainsFunc(r.Access, func(access dmodel.RepositoryAccess) bool {
-			return access.UserId == user.ID
-		}) {
-			return nil, huma.Error403Forbidden("access to repository not allowed")
+	userRole, err := getRepositoryRole(q, user, r)
+	if err != nil {
+		return nil, err
+	}
+	if t := auth.GetToken(ctx); t != nil && userRole != "" {
+		if !t.AllowsRepository(r.ID) {
+			return nil, huma.Error403Forbidden("token is not allowed to access this repository")
 		}
+		userRole = limitRoleByToken(ctx, userRole)
+	}
+	if userRole == "" {
+		return nil, huma.Error403Forbidden("access to repository not allowed")
+	}
+	if !dmodel.RepositoryRoleAllows(userRole, role) {
+		return nil, huma.Error403Forbidden(fmt.Sprintf("repository role %s is required", role))
 	}
 
 	return r, nil
 }
 
+// checkWholeRepositoryAccess is like checkRepositoryAccess, but is used for operations that affect the whole
+// repository. Tokens that are limited to single volumes of the repository are rejected before the repository is even
+// loaded.
+func checkWholeRepositoryAccess(ctx context.Context, id int64, role string) (*dmodel.Repository, error) {
+	if t := auth.GetToken(ctx); t != nil && !t.AllowsAllVolumes(id) {
+		return nil, huma.Error403Forbidden("token is limited to single volumes of this repository")
+	}
+	return checkRepositoryAccess(ctx, id, role)
+}
+
+// limitRoleByToken limits the role to the scope of the token in use, if any
+func limitRoleByToken(ctx context.Context, role string) string {
+	t := auth.GetToken(ctx)
+	if t == nil || role == "" {
+		return role
+	}
+	if !dmodel.RepositoryRoleAllows(t.MaxRepositoryRole(), role) {
+		return t.MaxRepositoryRole()
+	}
+	return role
+}
+
+// repositoryToModel only includes the rustic password for operators and owners, as viewers must not be able to
+// decrypt the backups
+func repositoryToModel(ctx context.Context, r *dmodel.Repository) (models.Repository, error) {
+	q := querier.GetQuerier(ctx)
+	user := auth.MustGetUser(ctx)
+
+	role, err := getRepositoryRole(q, user, r)
+	if err != nil {
+		return models.Repository{}, err
+	}
+	role = limitRoleByToken(ctx, role)
+	return models.RepositoryFromDB(*r, dmodel.RepositoryRoleAllows(role, dmodel.RepositoryRoleOperator)), nil
+}
+
+// CheckRepositoryAccess is used by other resources which reference repositories
+func CheckRepositoryAccess(ctx context.Context, id int64, role string) (*dmodel.Repository, error) {
+	return checkRepositoryAccess(ctx, id, role)
+}
+
+// getRepositoryRole returns the highest role the user has on the repository, either from a direct grant or from the
+// membership in the organization that owns the repository
+func getRepositoryRole(q *querier.Querier, user models.User, r *dmodel.Repository) (string, error) {
+	if user.IsAdmin {
+		return dmodel.RepositoryRoleOwner, nil
+	}
+	role := ""
+	idx := slices.IndexFunc(r.Access, func(access dmodel.RepositoryAccess) bool {
+		return access.UserId == user.ID
+	})
+	if idx != -1 {
+		role = r.Access[idx].Role
+	}
+	if r.OrganizationId != nil {
+		m, err := dmodel.GetOrganizationMember(q, *r.OrganizationId, user.ID)
+		if err != nil {
+			if !util.IsSqlNotFoundError(err) {
+				return "", err
+			}
+		} else if dmodel.RepositoryRoleAllows(m.Role, role) {
+			role = m.Role
+		}
+	}
+	return role, nil
+}
+
+func checkOrganizationOwner(ctx context.Context, organizationId int64) error {
+	q := querier.GetQuerier(ctx)
+	user := auth.MustGetUser(ctx)
+
+	_, err := dmodel.GetOrganizationById(q, organizationId, true)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return huma.Error404NotFound("organization not found")
+		}
+		return err
+	}
+	if user.IsAdmin {
+		return nil
+	}
+	m, err := dmodel.GetOrganizationMember(q, organizationId, user.ID)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return huma.Error403Forbidden("access to organization not allowed")
+		}
+		return err
+	}
+	if m.Role != dmodel.RepositoryRoleOwner {
+		return huma.Error403Forbidden("only owners of the organization can create repositories")
+	}
+	return nil
+}
+
 func RepositoryMiddleware(api huma.API) func(ctx huma.Context, next func(huma.Context)) {
 	return func(ctx huma.Context, next func(huma.Context)) {
 		repositoryIdStr := ctx.Param("repositoryId")
@@ -324,7 +579,17 @@ func RepositoryMiddleware(api huma.API) func(ctx huma.Context, next func(huma.Co
 			return
 		}
 
-		r, err := checkRepositoryAccess(ctx.Context(), repositoryId)
+		role := dmodel.RepositoryRoleViewer
+		if x, ok := ctx.Operation().Metadata[huma_metadata.NeedRepositoryRole].(string); ok {
+			role = x
+		}
+
+		check := checkRepositoryAccess
+		if x, ok := ctx.Operation().Metadata[huma_metadata.NeedAllVolumes].(bool); ok && x {
+			check = checkWholeRepositoryAccess
+		}
+
+		r, err := check(ctx.Context(), repositoryId, role)
 		if err != nil {
 			var err2 huma.StatusError
 			if errors.As(err, &err2) {
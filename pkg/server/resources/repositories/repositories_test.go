@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+)
+
+func newVolumeTokenContext() context.Context {
+	volumeId := int64(10)
+	ctx := context.WithValue(context.Background(), "user", &models.User{ID: "user", IsAdmin: true})
+	return context.WithValue(ctx, "token", &dmodel.Token{
+		Scope:             dmodel.TokenScopeAdmin,
+		RestrictResources: true,
+		Resources: []dmodel.TokenResource{
+			{RepositoryID: 1, VolumeID: &volumeId},
+		},
+	})
+}
+
+func TestVolumeTokenCanNotManageRepository(t *testing.T) {
+	ctx := newVolumeTokenContext()
+
+	s := &Repositories{}
+	repositoryId := RepositoryId{RepositoryId: 1}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"delete", func() error {
+			_, err := s.restDeleteRepository(ctx, &repositoryId)
+			return err
+		}},
+		{"update", func() error {
+			_, err := s.restUpdateRepository(ctx, &restUpdateRepositoryInput{RepositoryId: repositoryId})
+			return err
+		}},
+		{"create access", func() error {
+			i := &restCreateRepositoryAccessInput{RepositoryId: repositoryId}
+			i.Body.Role = dmodel.RepositoryRoleViewer
+			_, err := s.restCreateRepositoryAccess(ctx, i)
+			return err
+		}},
+		{"delete access", func() error {
+			_, err := s.restDeleteRepositoryAccess(ctx, &restDeleteRepositoryAccessInput{RepositoryId: repositoryId, UserId: "other"})
+			return err
+		}},
+		{"prune lock", func() error {
+			_, err := s.restPruneLock(ctx, &restPruneLockInput{RepositoryId: repositoryId})
+			return err
+		}},
+		{"prune unlock", func() error {
+			_, err := s.restPruneUnlock(ctx, &restPruneUnlockInput{RepositoryId: repositoryId})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			var se huma.StatusError
+			if !errors.As(err, &se) || se.GetStatus() != http.StatusForbidden {
+				t.Fatalf("expected 403, got %v", err)
+			}
+		})
+	}
+}
+
+// TestVolumeTokenCanNotWriteToStorage registers delete-object the same way the s3proxy does
+func TestVolumeTokenCanNotWriteToStorage(t *testing.T) {
+	_, api := humatest.New(t)
+	repoGroup := huma.NewGroup(api, "/v1/repositories/{repositoryId}")
+	repoGroup.UseMiddleware(RepositoryMiddleware(api))
+	huma.Post(repoGroup, "/s3proxy/delete-object", func(ctx context.Context, i *struct{}) (*struct{}, error) {
+		t.Fatal("object was deleted with a volume limited token")
+		return nil, nil
+	}, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator), huma_metadata.NeedAllVolumesModifier())
+
+	resp := api.PostCtx(newVolumeTokenContext(), "/v1/repositories/1/s3proxy/delete-object", map[string]any{
+		"key": "blocks/other-volume/chunk",
+	})
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
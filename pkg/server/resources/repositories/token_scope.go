@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/auth"
+)
+
+// Tokens can be limited to single volumes of a repository. The repository storage is shared between all volumes of a
+// repository, so volume limited tokens can only read from it through the s3proxy. Writing to it and operations on the
+// repository itself require a token that is not limited to single volumes, see checkWholeRepositoryAccess.
+
+// CheckVolumeAccess verifies that the token used for the request (if any) may access the volume of the current
+// repository
+func CheckVolumeAccess(ctx context.Context, volumeId int64) error {
+	t := auth.GetToken(ctx)
+	if t == nil {
+		return nil
+	}
+	r := GetRepository(ctx)
+	if !t.AllowsVolume(r.ID, volumeId) {
+		return huma.Error403Forbidden("token is not allowed to access this volume")
+	}
+	return nil
+}
+
+// CheckAllVolumesAccess verifies that the token used for the request (if any) is not limited to single volumes of the
+// current repository
+func CheckAllVolumesAccess(ctx context.Context) error {
+	t := auth.GetToken(ctx)
+	if t == nil {
+		return nil
+	}
+	r := GetRepository(ctx)
+	if !t.AllowsAllVolumes(r.ID) {
+		return huma.Error403Forbidden("token is limited to single volumes of this repository")
+	}
+	return nil
+}
+
+// IsVolumeAllowed is like CheckVolumeAccess and is used to filter lists
+func IsVolumeAllowed(ctx context.Context, volumeId int64) bool {
+	return CheckVolumeAccess(ctx, volumeId) == nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/dboxed/dboxed-common/huma_utils"
 	"github.com/dboxed/dboxed-volume/pkg/config"
 	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
 	"github.com/dboxed/dboxed-volume/pkg/server/s3utils"
@@ -36,10 +37,11 @@ func (s *S3Proxy) Init(api huma.API) error {
 	repoGroup := huma.NewGroup(api, "/v1/repositories/{repositoryId}")
 	repoGroup.UseMiddleware(repositories.RepositoryMiddleware(api))
 
+	// the objects of all volumes are stored in the same bucket, so tokens that are limited to single volumes can't write
 	huma.Post(repoGroup, "/s3proxy/list-objects", s.restListObjects)
-	huma.Post(repoGroup, "/s3proxy/presign-put", s.restPresignPut)
-	huma.Post(repoGroup, "/s3proxy/rename-object", s.restRenameObject)
-	huma.Post(repoGroup, "/s3proxy/delete-object", s.restDeleteObject)
+	huma.Post(repoGroup, "/s3proxy/presign-put", s.restPresignPut, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator), huma_metadata.NeedAllVolumesModifier())
+	huma.Post(repoGroup, "/s3proxy/rename-object", s.restRenameObject, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator), huma_metadata.NeedAllVolumesModifier())
+	huma.Post(repoGroup, "/s3proxy/delete-object", s.restDeleteObject, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator), huma_metadata.NeedAllVolumesModifier())
 
 	return nil
 }
@@ -2,6 +2,8 @@ package tokens
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/dboxed/dboxed-common/db/querier"
@@ -11,6 +13,7 @@ import (
 	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/auth"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
 	"github.com/google/uuid"
 )
 
@@ -40,10 +43,40 @@ func (s *Tokens) restCreateToken(ctx context.Context, i *huma_utils.JsonBody[mod
 		return nil, err
 	}
 
+	scope := i.Body.Scope
+	if scope == "" {
+		scope = dmodel.TokenScopeAdmin
+	}
+	if !dmodel.IsValidTokenScope(scope) {
+		return nil, huma.Error400BadRequest("invalid scope")
+	}
+	if i.Body.ExpiresAt != nil && !i.Body.ExpiresAt.After(time.Now()) {
+		return nil, huma.Error400BadRequest("expiresAt must be in the future")
+	}
+
+	tokenStr := auth.TokenPrefix + uuid.NewString()
 	t := dmodel.Token{
-		Token:  auth.TokenPrefix + uuid.NewString(),
-		Name:   i.Body.Name,
-		UserID: user.ID,
+		Name:              i.Body.Name,
+		UserID:            user.ID,
+		Scope:             scope,
+		RestrictResources: len(i.Body.Resources) != 0,
+		ExpiresAt:         i.Body.ExpiresAt,
+	}
+
+	for _, r := range i.Body.Resources {
+		err = s.checkTokenResource(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		t.Resources = append(t.Resources, dmodel.TokenResource{
+			RepositoryID: r.RepositoryId,
+			VolumeID:     r.VolumeId,
+		})
+	}
+
+	err = t.SetToken(tokenStr)
+	if err != nil {
+		return nil, err
 	}
 
 	err = t.Create(q)
@@ -53,10 +86,30 @@ func (s *Tokens) restCreateToken(ctx context.Context, i *huma_utils.JsonBody[mod
 
 	return huma_utils.NewJsonBody(models.CreateTokenResult{
 		Token:    models.TokenFromDB(t),
-		TokenStr: t.Token,
+		TokenStr: tokenStr,
 	}), nil
 }
 
+// checkTokenResource ensures that tokens can only reference resources the user has access to
+func (s *Tokens) checkTokenResource(ctx context.Context, r models.TokenResource) error {
+	q := querier.GetQuerier(ctx)
+
+	_, err := repositories.CheckRepositoryAccess(ctx, r.RepositoryId, dmodel.RepositoryRoleViewer)
+	if err != nil {
+		return err
+	}
+	if r.VolumeId != nil {
+		_, err = dmodel.GetVolumeById(q, &r.RepositoryId, *r.VolumeId, true)
+		if err != nil {
+			if util.IsSqlNotFoundError(err) {
+				return huma.Error404NotFound(fmt.Sprintf("volume %d not found in repository %d", *r.VolumeId, r.RepositoryId))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Tokens) restListTokens(ctx context.Context, i *struct{}) (*huma_utils.List[models.Token], error) {
 	q := querier.GetQuerier(ctx)
 	user := auth.MustGetUser(ctx)
@@ -7,6 +7,7 @@ import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/dboxed/dboxed-common/db/querier"
 	"github.com/dboxed/dboxed-common/huma_utils"
+	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/pkg/config"
 	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
 	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
@@ -56,3 +57,34 @@ func (s *Users) restGetUser(ctx context.Context, i *huma_utils.StringIdByPath) (
 	m := models.UserFromDB(*v, isAdmin)
 	return huma_utils.NewJsonBody(m), nil
 }
+
+// FindUser looks up a user by ID or email, exactly one of them must be set
+func FindUser(q *querier.Querier, userId *string, email *string) (*dmodel.User, error) {
+	var u *dmodel.User
+	var err error
+	if userId != nil && email == nil {
+		u, err = dmodel.GetUserById(q, *userId)
+	} else if userId == nil && email != nil {
+		var l []dmodel.User
+		l, err = dmodel.ListUsersByEmail(q, *email)
+		if err != nil {
+			return nil, err
+		}
+		if len(l) > 1 {
+			return nil, huma.Error409Conflict("multiple users have this email, reference the user by its id instead")
+		}
+		if len(l) == 0 {
+			return nil, huma.Error404NotFound("user not found, users must log in at least once before they can be referenced")
+		}
+		u = &l[0]
+	} else {
+		return nil, huma.Error400BadRequest("exactly one of userId and email must be set")
+	}
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error404NotFound("user not found, users must log in at least once before they can be referenced")
+		}
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,132 @@
+package volumes
+
+import (
+	"context"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/dboxed/dboxed-common/huma_utils"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
+)
+
+type restCreateVolumeBackup struct {
+	huma_utils.IdByPath
+	Body models.CreateVolumeBackup
+}
+
+func (s *Volumes) restCreateVolumeBackup(c context.Context, i *restCreateVolumeBackup) (*huma_utils.JsonBody[models.VolumeBackup], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.LockId == nil || *v.LockId != i.Body.LockId {
+		return nil, huma.Error409Conflict("backups can only be reported by the lock holder")
+	}
+	if i.Body.SnapshotId == "" {
+		return nil, huma.Error400BadRequest("missing snapshotId")
+	}
+
+	b := dmodel.VolumeBackup{
+		VolumeID:        v.ID,
+		SnapshotId:      i.Body.SnapshotId,
+		SnapshotTime:    i.Body.SnapshotTime,
+		Hostname:        i.Body.Hostname,
+		DurationMs:      i.Body.DurationMs,
+		TotalFiles:      i.Body.Summary.TotalFiles,
+		TotalBytes:      i.Body.Summary.TotalBytes,
+		FilesNew:        i.Body.Summary.FilesNew,
+		FilesChanged:    i.Body.Summary.FilesChanged,
+		FilesUnmodified: i.Body.Summary.FilesUnmodified,
+		DirsNew:         i.Body.Summary.DirsNew,
+		DirsChanged:     i.Body.Summary.DirsChanged,
+		DirsUnmodified:  i.Body.Summary.DirsUnmodified,
+		DataAdded:       i.Body.Summary.DataAdded,
+		DataAddedPacked: i.Body.Summary.DataAddedPacked,
+
+		Frozen:                i.Body.SnapshotHooks.Frozen,
+		PreSnapshotHookError:  i.Body.SnapshotHooks.PreSnapshotError,
+		PostSnapshotHookError: i.Body.SnapshotHooks.PostSnapshotError,
+	}
+	err = b.Create(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.LastBackupAt == nil || v.LastBackupAt.Before(b.SnapshotTime) {
+		err = v.UpdateLastBackupAt(q, b.SnapshotTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return huma_utils.NewJsonBody(models.VolumeBackupFromDB(b)), nil
+}
+
+func (s *Volumes) restListVolumeBackups(c context.Context, i *huma_utils.IdByPath) (*huma_utils.List[models.VolumeBackup], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := dmodel.ListVolumeBackupsForVolume(q, v.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []models.VolumeBackup
+	for _, b := range l {
+		ret = append(ret, models.VolumeBackupFromDB(b))
+	}
+	return huma_utils.NewList(ret, len(ret)), nil
+}
+
+type restGetVolumeBackup struct {
+	huma_utils.IdByPath
+	BackupId int64 `path:"backupId"`
+}
+
+func (s *Volumes) restGetVolumeBackup(c context.Context, i *restGetVolumeBackup) (*huma_utils.JsonBody[models.VolumeBackup], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := dmodel.GetVolumeBackupById(q, v.ID, i.BackupId)
+	if err != nil {
+		return nil, err
+	}
+
+	return huma_utils.NewJsonBody(models.VolumeBackupFromDB(*b)), nil
+}
+
+func (s *Volumes) restDeleteVolumeBackup(c context.Context, i *restGetVolumeBackup) (*huma_utils.Empty, error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = querier.DeleteOneByFields[dmodel.VolumeBackup](q, map[string]any{
+		"id":        i.BackupId,
+		"volume_id": v.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &huma_utils.Empty{}, nil
+}
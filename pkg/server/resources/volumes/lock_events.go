@@ -0,0 +1,32 @@
+package volumes
+
+import (
+	"context"
+
+	"github.com/dboxed/dboxed-common/db/querier"
+	"github.com/dboxed/dboxed-common/huma_utils"
+	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
+)
+
+func (s *Volumes) restListVolumeLockEvents(c context.Context, i *huma_utils.IdByPath) (*huma_utils.List[models.VolumeLockEvent], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := dmodel.ListVolumeLockEventsForVolume(q, v.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []models.VolumeLockEvent
+	for _, e := range l {
+		ret = append(ret, models.VolumeLockEventFromDB(e))
+	}
+	return huma_utils.NewList(ret, len(ret)), nil
+}
@@ -2,8 +2,13 @@ package volumes
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -12,13 +17,21 @@ import (
 	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/pkg/config"
 	"github.com/dboxed/dboxed-volume/pkg/db/dmodel"
+	"github.com/dboxed/dboxed-volume/pkg/server/huma_metadata"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/auth"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
 	"github.com/dboxed/dboxed-volume/pkg/volume"
 	"github.com/dustin/go-humanize"
 	"github.com/google/uuid"
 )
 
+// DefaultLockTtl is used for volumes that were created without an explicit lock TTL
+const DefaultLockTtl = 5 * time.Minute
+
+// MinLockTtl makes sure that clients, which refresh their lock every TTL/20, don't overload the server
+const MinLockTtl = 20 * time.Second
+
 type Volumes struct {
 }
 
@@ -30,14 +43,26 @@ func New(config config.Config) *Volumes {
 func (s *Volumes) Init(api huma.API) error {
 	repoGroup := huma.NewGroup(api, "/v1/repositories/{repositoryId}")
 	repoGroup.UseMiddleware(repositories.RepositoryMiddleware(api))
+	repoGroup.UseMiddleware(volumeScopeMiddleware(api))
 
-	huma.Post(repoGroup, "/volumes", s.restCreateVolume)
+	huma.Post(repoGroup, "/volumes", s.restCreateVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOwner))
 	huma.Get(repoGroup, "/volumes", s.restListVolumes)
 	huma.Get(repoGroup, "/volumes/{id}", s.restGetVolume)
 	huma.Get(repoGroup, "/volumes/by-name/{volumeName}", s.restGetVolumeByName)
-	huma.Delete(repoGroup, "/volumes/{id}", s.restDeleteVolume)
+	huma.Patch(repoGroup, "/volumes/{id}", s.restUpdateVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOwner))
+	huma.Delete(repoGroup, "/volumes/{id}", s.restDeleteVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOwner))
+	huma.Get(repoGroup, "/volumes/{id}/encryption-key", s.restGetVolumeEncryptionKey, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOwner))
+	huma.Post(repoGroup, "/volumes/{id}/encryption-key", s.restGetVolumeEncryptionKeyWithLock, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator))
+
+	huma.Post(repoGroup, "/volumes/{id}/lock", s.restLockVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator))
+	huma.Post(repoGroup, "/volumes/{id}/unlock", s.restUnlockVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator))
+	huma.Post(repoGroup, "/volumes/{id}/force-unlock", s.restForceUnlockVolume, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOwner))
+	huma.Get(repoGroup, "/volumes/{id}/lock-events", s.restListVolumeLockEvents)
 
-	huma.Post(repoGroup, "/volumes/{id}/lock", s.restLockVolume)
+	huma.Post(repoGroup, "/volumes/{id}/backups", s.restCreateVolumeBackup, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator))
+	huma.Get(repoGroup, "/volumes/{id}/backups", s.restListVolumeBackups)
+	huma.Get(repoGroup, "/volumes/{id}/backups/{backupId}", s.restGetVolumeBackup)
+	huma.Delete(repoGroup, "/volumes/{id}/backups/{backupId}", s.restDeleteVolumeBackup, huma_metadata.NeedRepositoryRoleModifier(dmodel.RepositoryRoleOperator))
 
 	return nil
 }
@@ -46,13 +71,25 @@ func (s *Volumes) restCreateVolume(ctx context.Context, i *huma_utils.JsonBody[m
 	q := querier.GetQuerier(ctx)
 	r := repositories.GetRepository(ctx)
 
+	err := repositories.CheckAllVolumesAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	if i.Body.FsSize <= humanize.MiByte {
 		return nil, huma.Error400BadRequest("fsSize is too small")
 	}
 	if !slices.Contains(volume.AllowedFsTypes, i.Body.FsType) {
 		return nil, huma.Error400BadRequest("unsupported or invalid fsType")
 	}
-	err := util.CheckName(i.Body.Name)
+	encryption := i.Body.Encryption
+	if encryption == "" {
+		encryption = volume.EncryptionNone
+	}
+	if !slices.Contains(volume.AllowedEncryptions, encryption) {
+		return nil, huma.Error400BadRequest("unsupported or invalid encryption")
+	}
+	err = util.CheckName(i.Body.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +100,51 @@ func (s *Volumes) restCreateVolume(ctx context.Context, i *huma_utils.JsonBody[m
 		RepositoryID: r.ID,
 		FsSize:       i.Body.FsSize,
 		FsType:       i.Body.FsType,
+		Encryption:   encryption,
+		LockTtl:      int64(DefaultLockTtl.Seconds()),
+	}
+
+	if encryption == volume.EncryptionServerKey {
+		key := make([]byte, 32)
+		_, err = rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		err = v.SetEncryptionKey(hex.EncodeToString(key))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if i.Body.LockTtl != nil {
+		err = checkLockTtl(*i.Body.LockTtl)
+		if err != nil {
+			return nil, err
+		}
+		v.LockTtl = *i.Body.LockTtl
+	}
+
+	if i.Body.Retention != nil {
+		err = checkRetention(*i.Body.Retention)
+		if err != nil {
+			return nil, err
+		}
+		v.KeepLast = i.Body.Retention.KeepLast
+		v.KeepHourly = i.Body.Retention.KeepHourly
+		v.KeepDaily = i.Body.Retention.KeepDaily
+		v.KeepWeekly = i.Body.Retention.KeepWeekly
+		v.KeepMonthly = i.Body.Retention.KeepMonthly
+	}
+
+	if i.Body.SnapshotHooks != nil {
+		err = checkSnapshotHooks(*i.Body.SnapshotHooks)
+		if err != nil {
+			return nil, err
+		}
+		v.SnapshotFreeze = i.Body.SnapshotHooks.Freeze
+		v.PreSnapshotHook = i.Body.SnapshotHooks.PreSnapshot
+		v.PostSnapshotHook = i.Body.SnapshotHooks.PostSnapshot
+		v.SnapshotHookTimeout = i.Body.SnapshotHooks.Timeout
 	}
 
 	err = v.Create(q)
@@ -84,6 +166,9 @@ func (s *Volumes) restListVolumes(ctx context.Context, i *struct{}) (*huma_utils
 
 	var ret []models.Volume
 	for _, r := range l {
+		if !repositories.IsVolumeAllowed(ctx, r.ID) {
+			continue
+		}
 		mm := models.VolumeFromDB(r)
 		ret = append(ret, mm)
 	}
@@ -115,15 +200,146 @@ func (s *Volumes) restGetVolumeByName(c context.Context, i *VolumeName) (*huma_u
 	if err != nil {
 		return nil, err
 	}
+	err = repositories.CheckVolumeAccess(c, v.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	m := models.VolumeFromDB(*v)
 	return huma_utils.NewJsonBody(m), nil
 }
 
+type restUpdateVolumeInput struct {
+	huma_utils.IdByPath
+	huma_utils.JsonBody[models.UpdateVolume]
+}
+
+func (s *Volumes) restUpdateVolume(c context.Context, i *restUpdateVolumeInput) (*huma_utils.JsonBody[models.Volume], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.doUpdateVolume(c, v, i.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := models.VolumeFromDB(*v)
+	return huma_utils.NewJsonBody(m), nil
+}
+
+func (s *Volumes) doUpdateVolume(c context.Context, v *dmodel.Volume, body models.UpdateVolume) error {
+	q := querier.GetQuerier(c)
+	if body.FsSize != nil {
+		if *body.FsSize < v.FsSize {
+			return huma.Error400BadRequest("fsSize can only be increased")
+		}
+		err := v.UpdateFsSize(q, *body.FsSize)
+		if err != nil {
+			return err
+		}
+	}
+	if body.LockTtl != nil {
+		err := checkLockTtl(*body.LockTtl)
+		if err != nil {
+			return err
+		}
+		err = v.UpdateLockTtl(q, *body.LockTtl)
+		if err != nil {
+			return err
+		}
+	}
+	if body.Retention != nil {
+		err := checkRetention(*body.Retention)
+		if err != nil {
+			return err
+		}
+		err = v.UpdateRetention(q,
+			body.Retention.KeepLast,
+			body.Retention.KeepHourly,
+			body.Retention.KeepDaily,
+			body.Retention.KeepWeekly,
+			body.Retention.KeepMonthly,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if body.SnapshotHooks != nil {
+		err := checkSnapshotHooks(*body.SnapshotHooks)
+		if err != nil {
+			return err
+		}
+		err = v.UpdateSnapshotHooks(q,
+			body.SnapshotHooks.Freeze,
+			body.SnapshotHooks.PreSnapshot,
+			body.SnapshotHooks.PostSnapshot,
+			body.SnapshotHooks.Timeout,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// volumeScopeMiddleware rejects requests for volumes which the token is not allowed to access
+func volumeScopeMiddleware(api huma.API) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		volumeIdStr := ctx.Param("id")
+		if volumeIdStr != "" {
+			volumeId, err := strconv.ParseInt(volumeIdStr, 10, 64)
+			if err != nil {
+				huma.WriteErr(api, ctx, http.StatusBadRequest, "invalid volume id", err)
+				return
+			}
+			err = repositories.CheckVolumeAccess(ctx.Context(), volumeId)
+			if err != nil {
+				huma.WriteErr(api, ctx, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+		next(ctx)
+	}
+}
+
+func checkLockTtl(lockTtl int64) error {
+	if lockTtl < int64(MinLockTtl.Seconds()) {
+		return huma.Error400BadRequest(fmt.Sprintf("lockTtl must be at least %d seconds", int64(MinLockTtl.Seconds())))
+	}
+	return nil
+}
+
+func checkRetention(r models.VolumeRetention) error {
+	for _, x := range []*int64{r.KeepLast, r.KeepHourly, r.KeepDaily, r.KeepWeekly, r.KeepMonthly} {
+		if x != nil && *x < 0 {
+			return huma.Error400BadRequest("retention values can not be negative")
+		}
+	}
+	return nil
+}
+
+func checkSnapshotHooks(h models.VolumeSnapshotHooks) error {
+	if h.Timeout != nil && *h.Timeout <= 0 {
+		return huma.Error400BadRequest("snapshot hook timeout must be positive")
+	}
+	return nil
+}
+
 func (s *Volumes) restDeleteVolume(c context.Context, i *huma_utils.IdByPath) (*huma_utils.Empty, error) {
 	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
 
-	err := dmodel.SoftDeleteWithConstraintsByIds[dmodel.Volume](q, i.Id)
+	err = dmodel.SoftDeleteWithConstraintsByIds[dmodel.Volume](q, v.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -131,6 +347,59 @@ func (s *Volumes) restDeleteVolume(c context.Context, i *huma_utils.IdByPath) (*
 	return &huma_utils.Empty{}, nil
 }
 
+func (s *Volumes) restGetVolumeEncryptionKey(c context.Context, i *huma_utils.IdByPath) (*huma_utils.JsonBody[models.VolumeEncryptionKey], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+	if v.Encryption != volume.EncryptionServerKey || v.EncryptionKey == nil {
+		return nil, huma.Error400BadRequest("volume has no server-side encryption key")
+	}
+	key, err := v.GetEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return huma_utils.NewJsonBody(models.VolumeEncryptionKey{
+		Key: key,
+	}), nil
+}
+
+type restGetVolumeEncryptionKeyWithLock struct {
+	huma_utils.IdByPath
+	Body models.VolumeEncryptionKeyRequest
+}
+
+// restGetVolumeEncryptionKeyWithLock returns the encryption key to operators, but only while they hold the lock of
+// the volume themselves, which is the case when serving the volume
+func (s *Volumes) restGetVolumeEncryptionKeyWithLock(c context.Context, i *restGetVolumeEncryptionKeyWithLock) (*huma_utils.JsonBody[models.VolumeEncryptionKey], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+	user := auth.MustGetUser(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+	if v.LockId == nil || *v.LockId != i.Body.LockId || v.LockUserId == nil || *v.LockUserId != user.ID {
+		return nil, huma.Error403Forbidden("volume is not locked by the given lock id and user")
+	}
+	if v.Encryption != volume.EncryptionServerKey || v.EncryptionKey == nil {
+		return nil, huma.Error400BadRequest("volume has no server-side encryption key")
+	}
+	key, err := v.GetEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return huma_utils.NewJsonBody(models.VolumeEncryptionKey{
+		Key: key,
+	}), nil
+}
+
 type restLockVolume struct {
 	huma_utils.IdByPath
 	Body models.VolumeLockRequest
@@ -139,6 +408,7 @@ type restLockVolume struct {
 func (s *Volumes) restLockVolume(c context.Context, i *restLockVolume) (*huma_utils.JsonBody[models.Volume], error) {
 	q := querier.GetQuerier(c)
 	r := repositories.GetRepository(c)
+	user := auth.MustGetUser(c)
 
 	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
 	if err != nil {
@@ -147,6 +417,16 @@ func (s *Volumes) restLockVolume(c context.Context, i *restLockVolume) (*huma_ut
 
 	log := slog.With(slog.Any("repoId", r.ID), slog.Any("volId", v.ID))
 
+	now := time.Now()
+	holder := dmodel.VolumeLockHolder{
+		UserId:        &user.ID,
+		Hostname:      i.Body.Hostname,
+		Pid:           i.Body.Pid,
+		ClientVersion: i.Body.ClientVersion,
+		Label:         i.Body.Label,
+	}
+	var events []*dmodel.VolumeLockEvent
+
 	strConv := func(s *string) string {
 		if s == nil {
 			return ""
@@ -154,7 +434,7 @@ func (s *Volumes) restLockVolume(c context.Context, i *restLockVolume) (*huma_ut
 		return *s
 	}
 
-	lockTimeout := time.Minute * 5
+	lockTimeout := time.Duration(v.LockTtl) * time.Second
 	allow := false
 	lockUuid := ""
 	if v.LockId == nil {
@@ -162,23 +442,123 @@ func (s *Volumes) restLockVolume(c context.Context, i *restLockVolume) (*huma_ut
 		lockUuid = uuid.NewString()
 		log = log.With(slog.Any("newLockId", lockUuid))
 		log.Info("locking volume")
+		events = append(events, dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventAcquired, lockUuid, holder))
 	} else {
 		if strConv(v.LockId) == strConv(i.Body.PrevLockId) {
 			allow = true
 			lockUuid = *v.LockId
 			log.Info("refreshing lock")
-		} else if *v.LockTime+int64(lockTimeout.Seconds()) < time.Now().Unix() {
+			// refreshes happen very often, so we only record the first refresh of every hour
+			if !time.Unix(*v.LockTime, 0).Truncate(time.Hour).Equal(now.Truncate(time.Hour)) {
+				events = append(events, dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventRefreshed, lockUuid, holder))
+			}
+		} else if *v.LockTime+int64(lockTimeout.Seconds()) < now.Unix() {
 			allow = true
 			lockUuid = uuid.NewString()
 			log = log.With(slog.Any("newLockId", lockUuid))
 			log.Info("old lock expired, re-locking")
+			events = append(events, dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventExpired, *v.LockId, v.GetLockHolder()))
+			events = append(events, dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventAcquired, lockUuid, holder))
 		}
 	}
 	if !allow {
 		return nil, huma.Error409Conflict("volume is already locked")
 	}
 
-	err = v.UpdateLock(q, lockUuid, time.Now())
+	err = v.UpdateLock(q, lockUuid, now, holder)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		err = e.Create(q)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := models.VolumeFromDB(*v)
+	return huma_utils.NewJsonBody(m), nil
+}
+
+type restUnlockVolume struct {
+	huma_utils.IdByPath
+	Body models.VolumeUnlockRequest
+}
+
+func (s *Volumes) restUnlockVolume(c context.Context, i *restUnlockVolume) (*huma_utils.JsonBody[models.Volume], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.LockId == nil || *v.LockId != i.Body.LockId {
+		return nil, huma.Error409Conflict("volume is not locked by the given lock id")
+	}
+
+	slog.Info("unlocking volume", slog.Any("repoId", r.ID), slog.Any("volId", v.ID), slog.Any("lockId", i.Body.LockId))
+	event := dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventReleased, *v.LockId, v.GetLockHolder())
+	err = v.ClearLock(q)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error409Conflict("volume lock changed while unlocking it")
+		}
+		return nil, err
+	}
+	err = event.Create(q)
+	if err != nil {
+		return nil, err
+	}
+
+	m := models.VolumeFromDB(*v)
+	return huma_utils.NewJsonBody(m), nil
+}
+
+type restForceUnlockVolume struct {
+	huma_utils.IdByPath
+	Body models.VolumeForceUnlockRequest
+}
+
+func (s *Volumes) restForceUnlockVolume(c context.Context, i *restForceUnlockVolume) (*huma_utils.JsonBody[models.Volume], error) {
+	q := querier.GetQuerier(c)
+	r := repositories.GetRepository(c)
+	user := auth.MustGetUser(c)
+
+	if i.Body.Reason == "" {
+		return nil, huma.Error400BadRequest("a reason for breaking the lock is required")
+	}
+
+	v, err := dmodel.GetVolumeById(q, &r.ID, i.Id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.LockId == nil {
+		return nil, huma.Error409Conflict("volume is not locked")
+	}
+
+	slog.Warn("breaking volume lock",
+		slog.Any("repoId", r.ID),
+		slog.Any("volId", v.ID),
+		slog.Any("lockId", *v.LockId),
+		slog.Any("user", user.ID),
+		slog.Any("reason", i.Body.Reason),
+	)
+	event := dmodel.NewVolumeLockEvent(v.ID, dmodel.VolumeLockEventStolen, *v.LockId, dmodel.VolumeLockHolder{
+		UserId: &user.ID,
+	})
+	event.Reason = &i.Body.Reason
+	err = v.BreakLock(q, user.ID, i.Body.Reason)
+	if err != nil {
+		if util.IsSqlNotFoundError(err) {
+			return nil, huma.Error409Conflict("volume lock changed while breaking it, please retry")
+		}
+		return nil, err
+	}
+	err = event.Create(q)
 	if err != nil {
 		return nil, err
 	}
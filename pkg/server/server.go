@@ -8,6 +8,7 @@ import (
 	"github.com/dboxed/dboxed-volume/pkg/config"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/auth"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/healthz"
+	"github.com/dboxed/dboxed-volume/pkg/server/resources/organizations"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/repositories"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/s3proxy"
 	"github.com/dboxed/dboxed-volume/pkg/server/resources/tokens"
@@ -27,13 +28,14 @@ type DboxedVolumeServer struct {
 	api        huma.API
 	humaConfig huma.Config
 
-	healthz      *healthz.HealthzServer
-	auth         *auth.AuthHandler
-	users        *users.Users
-	tokens       *tokens.Tokens
-	repositories *repositories.Repositories
-	volumes      *volumes.Volumes
-	s3proxy      *s3proxy.S3Proxy
+	healthz       *healthz.HealthzServer
+	auth          *auth.AuthHandler
+	users         *users.Users
+	tokens        *tokens.Tokens
+	organizations *organizations.Organizations
+	repositories  *repositories.Repositories
+	volumes       *volumes.Volumes
+	s3proxy       *s3proxy.S3Proxy
 }
 
 func NewDboxedVolumeServer(ctx context.Context, config config.Config) (*DboxedVolumeServer, error) {
@@ -53,6 +55,7 @@ func NewDboxedVolumeServer(ctx context.Context, config config.Config) (*DboxedVo
 	s.auth = auth.NewAuthHandler(config)
 	s.users = users.New()
 	s.tokens = tokens.New()
+	s.organizations = organizations.New()
 	s.repositories = repositories.New(config)
 	s.volumes = volumes.New(config)
 	s.s3proxy = s3proxy.New(config)
@@ -85,6 +88,11 @@ func (s *DboxedVolumeServer) InitApi(ctx context.Context) error {
 		return err
 	}
 
+	err = s.organizations.Init(s.api)
+	if err != nil {
+		return err
+	}
+
 	err = s.repositories.Init(s.api)
 	if err != nil {
 		return err
@@ -18,6 +18,7 @@ type CommandHelper struct {
 
 	CatchStdout bool
 	Dir         string
+	Stdin       []byte
 
 	Stdout []byte
 }
@@ -27,6 +28,9 @@ func (c *CommandHelper) Run() error {
 
 	cmd := exec.Command(c.Command, c.Args...)
 	cmd.Dir = c.Dir
+	if c.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(c.Stdin)
+	}
 	if c.CatchStdout {
 		cmd.Stdout = &cmdStdout
 	} else {
@@ -0,0 +1,115 @@
+package volume
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+)
+
+const (
+	BackendLvmThin = "lvm-thin"
+	BackendBtrfs   = "btrfs"
+	BackendDir     = "dir"
+)
+
+var AllowedBackends = []string{
+	BackendLvmThin,
+	BackendBtrfs,
+	BackendDir,
+}
+
+// ImageSizeFactor defines how much bigger than the filesystem the image of lvm-thin volumes is, so that the thin pool
+// has room for backup snapshots
+const ImageSizeFactor = 2
+
+// VolumeBackend is implemented by all local storage backends
+type VolumeBackend interface {
+	Mount(mountTarget string) error
+	Unmount(mountTarget string) error
+	Remount(mountTarget string, readOnly bool) error
+	Freeze(mountTarget string) error
+	Thaw(mountTarget string) error
+
+	CreateSnapshot(snapshotName string, overwrite bool) error
+	DeleteSnapshot(snapshotName string) error
+	MountSnapshot(snapshotName string, mountTarget string) error
+	UnmountSnapshot(snapshotName string) error
+	ListSnapshots() ([]SnapshotInfo, error)
+	// Rollback replaces the content of the volume with the given snapshot, which is kept. The volume must not be
+	// mounted.
+	Rollback(snapshotName string) error
+
+	// GetSize returns the current size of the volume
+	GetSize() (int64, error)
+	// Grow grows the volume to fsSize while it stays mounted at mountTarget
+	Grow(fsSize int64, mountTarget string) error
+
+	// Release frees all resources held by the volume after it was unmounted
+	Release() error
+	// Close frees the resources acquired by OpenBackend, but leaves volumes alone that were already active before,
+	// e.g. because volume serve is running
+	Close() error
+	// Delete releases the volume and deletes all its data
+	Delete() error
+}
+
+// ThinPoolBackend is implemented by backends that store data in a thin pool which must be monitored
+type ThinPoolBackend interface {
+	GetThinPoolUsage() (*ThinPoolUsage, error)
+	GetImageSize() (int64, error)
+	ExtendThinPool(imageSize int64, metadataGrowth int64) error
+}
+
+// BlockBackend is implemented by backends which can report the blocks that changed between two snapshots, which
+// allows block level incremental backups
+type BlockBackend interface {
+	VolumeBackend
+
+	SnapshotDevName(snapshotName string) string
+	GetSnapshotSize(snapshotName string) (int64, error)
+	GetChangedRanges(oldSnapshotName string, newSnapshotName string) ([]lvm.BlockRange, error)
+
+	// DevName returns the raw block device of the volume, which is the target of block level restores
+	DevName() string
+	Discard() error
+}
+
+type SnapshotInfo struct {
+	Name string
+	Time time.Time
+}
+
+// CreateBackend creates a new local volume with the given backend. For lvm-thin, ImagePath specifies the image file,
+// for btrfs the subvolume and for dir the directory to create.
+func CreateBackend(backend string, opts CreateOptions) error {
+	if opts.EncryptionKey != nil && backend != BackendLvmThin {
+		return fmt.Errorf("encryption is not supported by the %s backend", backend)
+	}
+	switch backend {
+	case BackendLvmThin:
+		return Create(opts)
+	case BackendBtrfs:
+		return createBtrfs(opts)
+	case BackendDir:
+		return createDir(opts)
+	default:
+		return fmt.Errorf("unsupported backend %s", backend)
+	}
+}
+
+func OpenBackend(backend string, path string, encryptionKey []byte) (VolumeBackend, error) {
+	if encryptionKey != nil && backend != BackendLvmThin {
+		return nil, fmt.Errorf("encryption is not supported by the %s backend", backend)
+	}
+	switch backend {
+	case BackendLvmThin:
+		return Open(path, encryptionKey)
+	case BackendBtrfs:
+		return openBtrfs(path)
+	case BackendDir:
+		return openDir(path)
+	default:
+		return nil, fmt.Errorf("unsupported backend %s", backend)
+	}
+}
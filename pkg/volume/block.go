@@ -0,0 +1,48 @@
+package volume
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// SnapshotDevName returns the raw block device of a snapshot. For encrypted volumes, this is the encrypted device.
+func (v *Volume) SnapshotDevName(snapshotName string) string {
+	return buildDevName(v.fsLv.VgName, snapshotName)
+}
+
+func (v *Volume) GetSnapshotSize(snapshotName string) (int64, error) {
+	lv, err := v.lvm.LVGet(v.fsLv.VgName, snapshotName)
+	if err != nil {
+		return 0, err
+	}
+	return lvm.ParseSize(lv.LvSize)
+}
+
+// GetChangedRanges returns the byte ranges that differ between two snapshots
+func (v *Volume) GetChangedRanges(oldSnapshotName string, newSnapshotName string) ([]lvm.BlockRange, error) {
+	oldLv, err := v.lvm.LVGet(v.fsLv.VgName, oldSnapshotName)
+	if err != nil {
+		return nil, err
+	}
+	newLv, err := v.lvm.LVGet(v.fsLv.VgName, newSnapshotName)
+	if err != nil {
+		return nil, err
+	}
+	if oldLv.ThinId == "" || newLv.ThinId == "" {
+		return nil, fmt.Errorf("failed to determine thin ids of snapshots")
+	}
+
+	vgName := escapeName(v.tpLv.VgName)
+	tpName := escapeName(v.tpLv.LvName)
+	poolDmName := fmt.Sprintf("%s-%s-tpool", vgName, tpName)
+	metadataDev := filepath.Join("/dev/mapper", fmt.Sprintf("%s-%s_tmeta", vgName, tpName))
+	return lvm.ThinDelta(poolDmName, metadataDev, oldLv.ThinId, newLv.ThinId)
+}
+
+// Discard unmaps all blocks of the volume, so that it reads as zeros afterward. The volume must not be mounted.
+func (v *Volume) Discard() error {
+	return util.RunCommand("blkdiscard", v.DevName())
+}
@@ -0,0 +1,180 @@
+package volume
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// BtrfsVolume stores the volume in a btrfs subvolume. Snapshots are read-only btrfs snapshots and the size is
+// enforced via a qgroup limit if quotas are enabled on the host filesystem.
+type BtrfsVolume struct {
+	pathVolume
+}
+
+func createBtrfs(opts CreateOptions) error {
+	if _, err := os.Stat(opts.ImagePath); err == nil {
+		if !opts.Force {
+			return fmt.Errorf("subvolume '%s' already exists, we won't overwrite it", opts.ImagePath)
+		}
+		v := &BtrfsVolume{pathVolume{path: opts.ImagePath}}
+		err = v.Delete()
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("creating btrfs subvolume", slog.Any("path", opts.ImagePath))
+	err := util.RunCommand("btrfs", "subvolume", "create", opts.ImagePath)
+	if err != nil {
+		return err
+	}
+
+	v := &BtrfsVolume{pathVolume{path: opts.ImagePath}}
+	return v.setSize(opts.FsSize)
+}
+
+func openBtrfs(path string) (*BtrfsVolume, error) {
+	err := util.RunCommand("btrfs", "subvolume", "show", path)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a btrfs subvolume: %w", path, err)
+	}
+	return &BtrfsVolume{pathVolume{path: path}}, nil
+}
+
+func (v *BtrfsVolume) setSize(size int64) error {
+	err := util.RunCommand("btrfs", "qgroup", "limit", strconv.FormatInt(size, 10), v.path)
+	if err != nil {
+		slog.Warn("failed to set qgroup limit, the volume size will not be enforced. Are quotas enabled?", slog.Any("error", err))
+	}
+	return v.writeSize(size)
+}
+
+func (v *BtrfsVolume) CreateSnapshot(snapshotName string, overwrite bool) error {
+	snapPath := v.snapshotPath(snapshotName)
+	if _, err := os.Stat(snapPath); err == nil {
+		if !overwrite {
+			return fmt.Errorf("snapshot %s already exists", snapshotName)
+		}
+		slog.Info("snapshot already exists, removing it", slog.Any("snapshotName", snapshotName))
+		err = v.DeleteSnapshot(snapshotName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(snapPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("creating snapshot", slog.Any("snapshotName", snapshotName))
+	return util.RunCommand("btrfs", "subvolume", "snapshot", "-r", v.path, snapPath)
+}
+
+func (v *BtrfsVolume) DeleteSnapshot(snapshotName string) error {
+	return util.RunCommand("btrfs", "subvolume", "delete", v.snapshotPath(snapshotName))
+}
+
+var btrfsCreationTimeRegex = regexp.MustCompile(`(?m)^\s*Creation time:\s*(.+)$`)
+
+func (v *BtrfsVolume) ListSnapshots() ([]SnapshotInfo, error) {
+	names, err := v.listSnapshotNames()
+	if err != nil {
+		return nil, err
+	}
+	var ret []SnapshotInfo
+	for _, n := range names {
+		stdout, err := util.RunCommandStdout("btrfs", "subvolume", "show", v.snapshotPath(n))
+		if err != nil {
+			return nil, err
+		}
+		m := btrfsCreationTimeRegex.FindStringSubmatch(string(stdout))
+		if m == nil {
+			return nil, fmt.Errorf("failed to determine creation time of snapshot %s", n)
+		}
+		t, err := time.Parse("2006-01-02 15:04:05 -0700", strings.TrimSpace(m[1]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse creation time of snapshot %s: %w", n, err)
+		}
+		ret = append(ret, SnapshotInfo{
+			Name: n,
+			Time: t,
+		})
+	}
+	return ret, nil
+}
+
+func (v *BtrfsVolume) Rollback(snapshotName string) error {
+	newPath, oldPath, err := v.prepareRollback(snapshotName)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{newPath, oldPath} {
+		if _, err := os.Stat(p); err == nil {
+			err = util.RunCommand("btrfs", "subvolume", "delete", p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	size, err := v.GetSize()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("rolling back volume", slog.Any("snapshotName", snapshotName))
+	err = util.RunCommand("btrfs", "subvolume", "snapshot", v.snapshotPath(snapshotName), newPath)
+	if err != nil {
+		return err
+	}
+	err = v.swapRollback(newPath, oldPath)
+	if err != nil {
+		return err
+	}
+	err = util.RunCommand("btrfs", "subvolume", "delete", oldPath)
+	if err != nil {
+		return err
+	}
+	// the qgroup limit belongs to the old subvolume
+	return v.setSize(size)
+}
+
+func (v *BtrfsVolume) Grow(fsSize int64, mountTarget string) error {
+	size, err := v.GetSize()
+	if err != nil {
+		return err
+	}
+	if fsSize <= size {
+		return nil
+	}
+	slog.Info("growing btrfs subvolume", slog.Any("size", fsSize))
+	return v.setSize(fsSize)
+}
+
+func (v *BtrfsVolume) Delete() error {
+	entries, err := os.ReadDir(v.path + ".snapshots")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, e := range entries {
+		err = v.DeleteSnapshot(e.Name())
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(v.path); err == nil {
+		err = util.RunCommand("btrfs", "subvolume", "delete", v.path)
+		if err != nil {
+			return err
+		}
+	}
+	return v.removeMetadata()
+}
@@ -20,6 +20,9 @@ type CreateOptions struct {
 	FsType    string
 	Force     bool
 
+	// EncryptionKey enables LUKS encryption of the filesystem when set
+	EncryptionKey []byte
+
 	VgName string
 }
 
@@ -67,30 +70,48 @@ func Create(opts CreateOptions) error {
 
 	defer losetup.Detach(loDev)
 
-	err = lvm.PVCreate(loDev)
+	l := lvm.NewScoped(loDev)
+
+	err = l.PVCreate(loDev)
 	if err != nil {
 		return err
 	}
 
-	err = lvm.VGCreate(vgName, loDev)
+	err = l.VGCreate(vgName, loDev)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = lvm.VGDeactivate(vgName)
+		_ = l.VGDeactivate(vgName)
 	}()
 
-	err = lvm.TPCreate100(vgName, tpName, []string{"tp"})
+	err = l.TPCreate100(vgName, tpName, []string{"tp"})
 	if err != nil {
 		return err
 	}
 
-	err = lvm.TLVCreate(vgName, tpName, volName, opts.FsSize, []string{"fs"})
+	err = l.TLVCreate(vgName, tpName, volName, opts.FsSize, []string{"fs"})
 	if err != nil {
 		return err
 	}
 
 	fsDev := buildDevName(vgName, volName)
+	if opts.EncryptionKey != nil {
+		err = cryptFormat(fsDev, opts.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		cryptName := buildCryptName(vgName, volName)
+		err = cryptOpen(fsDev, cryptName, opts.EncryptionKey, false)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = cryptClose(cryptName)
+		}()
+		fsDev = buildCryptDevName(cryptName)
+	}
+
 	err = util.RunCommand(fmt.Sprintf("mkfs.%s", opts.FsType), fsDev)
 	if err != nil {
 		return err
@@ -0,0 +1,70 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+const (
+	EncryptionNone = "none"
+	// EncryptionServerKey uses a per-volume key that is generated and stored by the server
+	EncryptionServerKey = "server-key"
+	// EncryptionLocalKey uses a key that is never sent to the server and must be supplied locally
+	EncryptionLocalKey = "local-key"
+)
+
+var AllowedEncryptions = []string{
+	EncryptionNone,
+	EncryptionServerKey,
+	EncryptionLocalKey,
+}
+
+func buildCryptName(vgName string, lvName string) string {
+	return fmt.Sprintf("%s_%s_crypt", vgName, lvName)
+}
+
+func buildCryptDevName(cryptName string) string {
+	return filepath.Join("/dev/mapper", cryptName)
+}
+
+func runCryptsetup(key []byte, args ...string) error {
+	c := util.CommandHelper{
+		Command: "cryptsetup",
+		Args:    append(args, "--key-file", "-"),
+		Stdin:   key,
+	}
+	return c.Run()
+}
+
+func cryptFormat(dev string, key []byte) error {
+	return runCryptsetup(key, "luksFormat", "--batch-mode", "--type", "luks2", dev)
+}
+
+func cryptOpen(dev string, cryptName string, key []byte, readOnly bool) error {
+	if _, err := os.Stat(buildCryptDevName(cryptName)); err == nil {
+		return nil
+	}
+	args := []string{"open", "--type", "luks"}
+	if readOnly {
+		args = append(args, "--readonly")
+	}
+	args = append(args, dev, cryptName)
+	return runCryptsetup(key, args...)
+}
+
+func cryptClose(cryptName string) error {
+	if _, err := os.Stat(buildCryptDevName(cryptName)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return util.RunCommand("cryptsetup", "close", cryptName)
+}
+
+func cryptResize(cryptName string, key []byte) error {
+	return runCryptsetup(key, "resize", cryptName)
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package volume
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func changeTime(st os.FileInfo) time.Time {
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return st.ModTime()
+	}
+	return time.Unix(sys.Ctim.Sec, sys.Ctim.Nsec)
+}
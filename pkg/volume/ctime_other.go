@@ -0,0 +1,12 @@
+//go:build !linux
+
+package volume
+
+import (
+	"os"
+	"time"
+)
+
+func changeTime(st os.FileInfo) time.Time {
+	return st.ModTime()
+}
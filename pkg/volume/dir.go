@@ -0,0 +1,146 @@
+package volume
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// DirVolume stores the volume in a plain directory. Snapshots are full copies which use reflinks when the host
+// filesystem supports them. The size is only recorded and not enforced.
+type DirVolume struct {
+	pathVolume
+}
+
+func createDir(opts CreateOptions) error {
+	if _, err := os.Stat(opts.ImagePath); err == nil {
+		if !opts.Force {
+			return fmt.Errorf("directory '%s' already exists, we won't overwrite it", opts.ImagePath)
+		}
+		v := &DirVolume{pathVolume{path: opts.ImagePath}}
+		err = v.Delete()
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("creating volume directory", slog.Any("path", opts.ImagePath))
+	err := os.Mkdir(opts.ImagePath, 0755)
+	if err != nil {
+		return err
+	}
+
+	v := &DirVolume{pathVolume{path: opts.ImagePath}}
+	return v.writeSize(opts.FsSize)
+}
+
+func openDir(path string) (*DirVolume, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return &DirVolume{pathVolume{path: path}}, nil
+}
+
+func (v *DirVolume) CreateSnapshot(snapshotName string, overwrite bool) error {
+	snapPath := v.snapshotPath(snapshotName)
+	if _, err := os.Stat(snapPath); err == nil {
+		if !overwrite {
+			return fmt.Errorf("snapshot %s already exists", snapshotName)
+		}
+		slog.Info("snapshot already exists, removing it", slog.Any("snapshotName", snapshotName))
+		err = v.DeleteSnapshot(snapshotName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(snapPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	_ = util.RunCommand("sync")
+
+	slog.Info("creating snapshot", slog.Any("snapshotName", snapshotName))
+	err = util.RunCommand("cp", "-a", "--reflink=auto", v.path, snapPath)
+	if err != nil {
+		_ = os.RemoveAll(snapPath)
+		return err
+	}
+	return nil
+}
+
+func (v *DirVolume) DeleteSnapshot(snapshotName string) error {
+	return os.RemoveAll(v.snapshotPath(snapshotName))
+}
+
+func (v *DirVolume) ListSnapshots() ([]SnapshotInfo, error) {
+	names, err := v.listSnapshotNames()
+	if err != nil {
+		return nil, err
+	}
+	var ret []SnapshotInfo
+	for _, n := range names {
+		st, err := os.Stat(v.snapshotPath(n))
+		if err != nil {
+			return nil, err
+		}
+		// cp -a preserves the modification time, so the change time is the closest we get to the creation time
+		ret = append(ret, SnapshotInfo{
+			Name: n,
+			Time: changeTime(st),
+		})
+	}
+	return ret, nil
+}
+
+func (v *DirVolume) Rollback(snapshotName string) error {
+	newPath, oldPath, err := v.prepareRollback(snapshotName)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{newPath, oldPath} {
+		err = os.RemoveAll(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("rolling back volume", slog.Any("snapshotName", snapshotName))
+	err = util.RunCommand("cp", "-a", "--reflink=auto", v.snapshotPath(snapshotName), newPath)
+	if err != nil {
+		_ = os.RemoveAll(newPath)
+		return err
+	}
+	err = v.swapRollback(newPath, oldPath)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(oldPath)
+}
+
+func (v *DirVolume) Grow(fsSize int64, mountTarget string) error {
+	size, err := v.GetSize()
+	if err != nil {
+		return err
+	}
+	if fsSize <= size {
+		return nil
+	}
+	return v.writeSize(fsSize)
+}
+
+func (v *DirVolume) Delete() error {
+	err := os.RemoveAll(v.path)
+	if err != nil {
+		return err
+	}
+	return v.removeMetadata()
+}
@@ -0,0 +1,148 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+	"github.com/moby/sys/mountinfo"
+)
+
+// Fsck checks the thin pool metadata and the filesystem of the volume. With repair, found problems are fixed if
+// possible. The volume and all its snapshots must be unmounted.
+func (v *Volume) Fsck(repair bool) error {
+	mounts, err := v.findMounts()
+	if err != nil {
+		return err
+	}
+	if len(mounts) != 0 {
+		return fmt.Errorf("%s is mounted at %s, refusing to check the volume", mounts[0].Source, mounts[0].Mountpoint)
+	}
+
+	err = v.checkThinPool(repair)
+	if err != nil {
+		return err
+	}
+	return v.checkFs(repair)
+}
+
+// findMounts returns all mounts of the volume and its snapshots
+func (v *Volume) findMounts() ([]*mountinfo.Info, error) {
+	lvs, err := v.lvm.ListLVs()
+	if err != nil {
+		return nil, err
+	}
+	var devs []string
+	for _, lv := range lvs {
+		if lv.VgName != v.fsLv.VgName {
+			continue
+		}
+		devs = append(devs, buildDevName(lv.VgName, lv.LvName), buildCryptDevName(buildCryptName(lv.VgName, lv.LvName)))
+	}
+
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*mountinfo.Info
+	for _, m := range mounts {
+		if slices.Contains(devs, m.Source) {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+func (v *Volume) checkThinPool(repair bool) error {
+	vgName := v.tpLv.VgName
+	metaLvName := v.tpLv.LvName + "_tmeta"
+
+	// the metadata can only be checked while the pool is inactive
+	err := v.Deactivate()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("checking thin pool metadata")
+	err = v.lvm.LVActivate(vgName, metaLvName, true)
+	if err != nil {
+		return err
+	}
+	checkErr := util.RunCommand("thin_check", buildDevName(vgName, metaLvName))
+	err = v.lvm.LVActivate(vgName, metaLvName, false)
+	if err != nil {
+		return err
+	}
+	if checkErr == nil {
+		return nil
+	}
+	if !repair {
+		return fmt.Errorf("thin pool metadata is damaged: %w", checkErr)
+	}
+
+	slog.Warn("thin pool metadata is damaged, repairing it", slog.Any("error", checkErr))
+	return v.lvm.TPRepair(vgName, v.tpLv.LvName)
+}
+
+func (v *Volume) checkFs(repair bool) error {
+	err := v.lvm.LVActivate(v.fsLv.VgName, v.fsLv.LvName, true)
+	if err != nil {
+		return err
+	}
+	err = v.openCrypt(v.fsLv.LvName, !repair)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := v.closeCrypt(v.fsLv.LvName)
+		if err != nil {
+			slog.Error("deferred crypt close failed", slog.Any("error", err))
+		}
+	}()
+
+	fsDev := v.FsDevName()
+	fsType, err := getFsType(fsDev)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("checking filesystem", slog.Any("fsType", fsType), slog.Any("repair", repair))
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		if repair {
+			// exit code 1 means that errors were found and corrected
+			return runFsckCommand([]int{0, 1}, "e2fsck", "-f", "-y", fsDev)
+		}
+		return runFsckCommand([]int{0}, "e2fsck", "-f", "-n", fsDev)
+	case "xfs":
+		if repair {
+			return runFsckCommand([]int{0}, "xfs_repair", fsDev)
+		}
+		return runFsckCommand([]int{0}, "xfs_repair", "-n", fsDev)
+	case "btrfs":
+		if repair {
+			return runFsckCommand([]int{0}, "btrfs", "check", "--repair", fsDev)
+		}
+		return runFsckCommand([]int{0}, "btrfs", "check", "--readonly", fsDev)
+	default:
+		return fmt.Errorf("checking %s filesystems is not supported", fsType)
+	}
+}
+
+func runFsckCommand(okExitCodes []int, command string, args ...string) error {
+	err := util.RunCommand(command, args...)
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if slices.Contains(okExitCodes, exitErr.ExitCode()) {
+			return nil
+		}
+		return fmt.Errorf("filesystem check failed with exit code %d", exitErr.ExitCode())
+	}
+	return err
+}
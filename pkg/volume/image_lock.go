@@ -0,0 +1,54 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrImageInUse is returned by LockImage if another process holds the lock
+var ErrImageInUse = errors.New("already in use by another process")
+
+func buildImageLockPath(image string) string {
+	return image + ".lock"
+}
+
+// LockImage takes an exclusive lock on the local volume, which marks it as in use for other processes, e.g. local gc.
+// The lock is held until the returned file is closed or the process exits.
+func LockImage(image string) (*os.File, error) {
+	f, err := os.OpenFile(buildImageLockPath(image), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%s is %w", image, ErrImageInUse)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// IsImageLocked returns true if another process currently holds the lock of LockImage
+func IsImageLocked(image string) (bool, error) {
+	f, err := os.Open(buildImageLockPath(image))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return true, nil
+		}
+		return false, err
+	}
+	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package volume
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/dboxed/dboxed-volume/pkg/losetup"
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+)
+
+// staleSnapshots are only needed while a backup is running. Block backup snapshots are kept, as the next block level
+// backup needs them. Leftovers of an interrupted rollback are handled by recoverRollback instead, as simply removing
+// them could leave the volume without its fs logical volume.
+var staleSnapshots = []string{
+	"_backup",
+}
+
+// GcLocalVolumes releases all local volumes which are neither served nor mounted. Stale snapshots are removed,
+// crypt devices are closed, the volume group is deactivated and the loop device is detached.
+func GcLocalVolumes(dryRun bool) error {
+	volumes, err := ListLocalVolumes()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range volumes {
+		log := slog.With(slog.Any("image", v.Image), slog.Any("loopDevice", v.LoopDevice), slog.Any("vgName", v.VgName))
+		if v.InUse() {
+			log.Info("volume is in use, skipping")
+			continue
+		}
+		if dryRun {
+			log.Info("would release volume", slog.Any("snapshots", v.Snapshots))
+			continue
+		}
+
+		err = gcLocalVolume(log, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gcLocalVolume(log *slog.Logger, v LocalVolume) error {
+	// the volume might have been started since it was listed, so it must stay locked while it is released
+	if !v.ImageDeleted {
+		imageLock, err := LockImage(v.Image)
+		if err != nil {
+			if errors.Is(err, ErrImageInUse) {
+				log.Info("volume is in use, skipping")
+				return nil
+			}
+			return err
+		}
+		defer imageLock.Close()
+	}
+
+	l := lvm.NewScoped(v.LoopDevice)
+
+	lvs, err := l.FindPVLVs(v.LoopDevice)
+	if err != nil {
+		return err
+	}
+	_, err = recoverRollback(l, lvs, "fs")
+	if err != nil {
+		return err
+	}
+
+	for _, s := range v.Snapshots {
+		if !slices.Contains(staleSnapshots, s) {
+			continue
+		}
+		log.Info("removing stale snapshot", slog.Any("snapshotName", s))
+		err := cryptClose(buildCryptName(v.VgName, s))
+		if err != nil {
+			return err
+		}
+		err = l.LVRemove(v.VgName, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, lvName := range v.lvNames {
+		err := cryptClose(buildCryptName(v.VgName, lvName))
+		if err != nil {
+			return err
+		}
+	}
+	if v.Active {
+		log.Info("deactivating volume group")
+		err := l.VGDeactivate(v.VgName)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("detaching loop device")
+	return losetup.Detach(v.LoopDevice)
+}
@@ -0,0 +1,127 @@
+package volume
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/pkg/losetup"
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+	"github.com/moby/sys/mountinfo"
+)
+
+// LocalVolume is an lvm-thin volume which is attached to this host
+type LocalVolume struct {
+	Image        string   `json:"image"`
+	ImageDeleted bool     `json:"imageDeleted,omitempty"`
+	LoopDevice   string   `json:"loopDevice"`
+	VgName       string   `json:"vgName"`
+	Active       bool     `json:"active"`
+	Served       bool     `json:"served"`
+	Mounts       []string `json:"mounts,omitempty"`
+	Snapshots    []string `json:"snapshots,omitempty"`
+
+	lvNames []string
+}
+
+// InUse returns true if the volume is served or mounted anywhere
+func (v *LocalVolume) InUse() bool {
+	return v.Served || len(v.Mounts) != 0
+}
+
+// ListLocalVolumes finds all lvm-thin volumes which are attached via loop devices, no matter if they are served or
+// left behind
+func ListLocalVolumes() ([]LocalVolume, error) {
+	loDevs, err := losetup.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(loDevs) == 0 {
+		return nil, nil
+	}
+	var loDevNames []string
+	for _, ld := range loDevs {
+		loDevNames = append(loDevNames, ld.Name)
+	}
+
+	l := lvm.NewScoped(loDevNames...)
+	pvs, err := l.ListPVs()
+	if err != nil {
+		return nil, err
+	}
+	lvs, err := l.ListLVs()
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []LocalVolume
+	for _, ld := range loDevs {
+		idx := slices.IndexFunc(pvs, func(pv lvm.PVEntry) bool {
+			return pv.PvName == ld.Name
+		})
+		if idx == -1 || pvs[idx].VgName == "" {
+			continue
+		}
+		vgName := pvs[idx].VgName
+
+		var vgLvs []lvm.LVEntry
+		for _, lv := range lvs {
+			if lv.VgName == vgName {
+				vgLvs = append(vgLvs, lv)
+			}
+		}
+		if !isDboxedVg(vgLvs) {
+			continue
+		}
+
+		image, deleted := strings.CutSuffix(ld.BackFile, " (deleted)")
+		v := LocalVolume{
+			Image:        image,
+			ImageDeleted: deleted,
+			LoopDevice:   ld.Name,
+			VgName:       vgName,
+		}
+		var devs []string
+		for _, lv := range vgLvs {
+			v.lvNames = append(v.lvNames, lv.LvName)
+			devs = append(devs, buildDevName(vgName, lv.LvName), buildCryptDevName(buildCryptName(vgName, lv.LvName)))
+			if isLvActive(lv) {
+				v.Active = true
+			}
+			if lv.PoolLv != "" && lv.LvTags != "fs" {
+				v.Snapshots = append(v.Snapshots, lv.LvName)
+			}
+		}
+		for _, m := range mounts {
+			if slices.Contains(devs, m.Source) {
+				v.Mounts = append(v.Mounts, m.Mountpoint)
+			}
+		}
+		if !deleted {
+			v.Served, err = IsImageLocked(image)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// isDboxedVg checks for the thin pool and filesystem volumes that Create sets up
+func isDboxedVg(lvs []lvm.LVEntry) bool {
+	hasTp := slices.ContainsFunc(lvs, func(lv lvm.LVEntry) bool {
+		return lv.LvTags == "tp"
+	})
+	hasFs := slices.ContainsFunc(lvs, func(lv lvm.LVEntry) bool {
+		return lv.LvTags == "fs"
+	})
+	return hasTp && hasFs
+}
+
+func isLvActive(lv lvm.LVEntry) bool {
+	return len(lv.LvAttr) > 4 && lv.LvAttr[4] == 'a'
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func (v *Volume) Mount(mountTarget string) error {
-	lvDev := v.DevName()
+	fsDev := v.FsDevName()
 
 	mounts, err := mountinfo.GetMounts(nil)
 	if err != nil {
@@ -16,16 +16,53 @@ func (v *Volume) Mount(mountTarget string) error {
 	}
 
 	for _, m := range mounts {
-		if m.Mountpoint == mountTarget && m.Source == lvDev {
+		if m.Mountpoint == mountTarget && m.Source == fsDev {
 			slog.Info("volume already mounted", slog.Any("mountPoint", m.Mountpoint), slog.Any("source", m.Source))
 			return nil
 		}
 	}
 
-	err = util.RunCommand("mount", lvDev, mountTarget)
+	err = v.openCrypt(v.fsLv.LvName, false)
+	if err != nil {
+		return err
+	}
+
+	err = util.RunCommand("mount", fsDev, mountTarget)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (v *Volume) Unmount(mountTarget string) error {
+	fsDev := v.FsDevName()
+
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		if m.Mountpoint == mountTarget && m.Source == fsDev {
+			return util.RunCommand("umount", mountTarget)
+		}
+	}
+	return nil
+}
+
+func (v *Volume) Remount(mountTarget string, readOnly bool) error {
+	opts := "remount,rw"
+	if readOnly {
+		opts = "remount,ro"
+	}
+	return util.RunCommand("mount", "-o", opts, mountTarget)
+}
+
+func (v *Volume) Freeze(mountTarget string) error {
+	return util.RunCommand("fsfreeze", "--freeze", mountTarget)
+}
+
+func (v *Volume) Thaw(mountTarget string) error {
+	return util.RunCommand("fsfreeze", "--unfreeze", mountTarget)
+}
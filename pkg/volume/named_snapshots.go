@@ -0,0 +1,109 @@
+package volume
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// named snapshots are prefixed, so that they never collide with the internal snapshots used for backups
+const namedSnapshotPrefix = "snap_"
+
+// AutoSnapshotPrefix is used for the snapshots created by the local snapshot schedule of volume serve. Only these
+// snapshots are subject to the local retention.
+const AutoSnapshotPrefix = "auto-"
+
+var snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func CheckSnapshotName(name string) error {
+	if !snapshotNameRegex.MatchString(name) || len(name) > 64 {
+		return fmt.Errorf("invalid snapshot name %s", name)
+	}
+	return nil
+}
+
+func BuildAutoSnapshotName(t time.Time) string {
+	return AutoSnapshotPrefix + t.UTC().Format("20060102-150405")
+}
+
+func CreateNamedSnapshot(v VolumeBackend, name string) error {
+	err := CheckSnapshotName(name)
+	if err != nil {
+		return err
+	}
+	return v.CreateSnapshot(namedSnapshotPrefix+name, false)
+}
+
+// ListNamedSnapshots returns all named snapshots sorted by creation time
+func ListNamedSnapshots(v VolumeBackend) ([]SnapshotInfo, error) {
+	snapshots, err := v.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	var ret []SnapshotInfo
+	for _, s := range snapshots {
+		name, ok := strings.CutPrefix(s.Name, namedSnapshotPrefix)
+		if !ok {
+			continue
+		}
+		ret = append(ret, SnapshotInfo{
+			Name: name,
+			Time: s.Time,
+		})
+	}
+	slices.SortFunc(ret, func(a, b SnapshotInfo) int {
+		return a.Time.Compare(b.Time)
+	})
+	return ret, nil
+}
+
+func getNamedSnapshot(v VolumeBackend, name string) (string, error) {
+	snapshots, err := ListNamedSnapshots(v)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range snapshots {
+		if s.Name == name {
+			return namedSnapshotPrefix + name, nil
+		}
+	}
+	return "", fmt.Errorf("snapshot %s not found", name)
+}
+
+func DeleteNamedSnapshot(v VolumeBackend, name string) error {
+	snapshotName, err := getNamedSnapshot(v, name)
+	if err != nil {
+		return err
+	}
+	err = v.UnmountSnapshot(snapshotName)
+	if err != nil {
+		return err
+	}
+	return v.DeleteSnapshot(snapshotName)
+}
+
+func MountNamedSnapshot(v VolumeBackend, name string, mountTarget string) error {
+	snapshotName, err := getNamedSnapshot(v, name)
+	if err != nil {
+		return err
+	}
+	return v.MountSnapshot(snapshotName, mountTarget)
+}
+
+func UnmountNamedSnapshot(v VolumeBackend, name string) error {
+	snapshotName, err := getNamedSnapshot(v, name)
+	if err != nil {
+		return err
+	}
+	return v.UnmountSnapshot(snapshotName)
+}
+
+func RollbackNamedSnapshot(v VolumeBackend, name string) error {
+	snapshotName, err := getNamedSnapshot(v, name)
+	if err != nil {
+		return err
+	}
+	return v.Rollback(snapshotName)
+}
@@ -0,0 +1,206 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+	"github.com/moby/sys/mountinfo"
+)
+
+// pathVolume implements everything that is shared between backends which store the volume as a directory tree on a
+// host filesystem. These volumes are bind mounted and have no real size limit, so the size is only recorded in a
+// file next to the volume.
+type pathVolume struct {
+	path string
+}
+
+func (v *pathVolume) snapshotPath(snapshotName string) string {
+	return filepath.Join(v.path+".snapshots", snapshotName)
+}
+
+func (v *pathVolume) sizeFile() string {
+	return v.path + ".size"
+}
+
+func (v *pathVolume) Mount(mountTarget string) error {
+	mounted, err := isMountPoint(mountTarget)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return nil
+	}
+	return util.RunCommand("mount", "--bind", v.path, mountTarget)
+}
+
+func (v *pathVolume) Unmount(mountTarget string) error {
+	mounted, err := isMountPoint(mountTarget)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return nil
+	}
+	return util.RunCommand("umount", mountTarget)
+}
+
+func (v *pathVolume) Remount(mountTarget string, readOnly bool) error {
+	opts := "remount,bind,rw"
+	if readOnly {
+		opts = "remount,bind,ro"
+	}
+	return util.RunCommand("mount", "-o", opts, mountTarget)
+}
+
+func (v *pathVolume) Freeze(mountTarget string) error {
+	return fmt.Errorf("freezing bind mounted volumes is not supported, as it would freeze the whole host filesystem")
+}
+
+func (v *pathVolume) Thaw(mountTarget string) error {
+	return fmt.Errorf("freezing bind mounted volumes is not supported, as it would freeze the whole host filesystem")
+}
+
+func (v *pathVolume) MountSnapshot(snapshotName string, mountTarget string) error {
+	return util.RunCommand("mount", "--bind", "-o", "ro", v.snapshotPath(snapshotName), mountTarget)
+}
+
+func (v *pathVolume) UnmountSnapshot(snapshotName string) error {
+	mounts, err := findBindMounts(v.snapshotPath(snapshotName))
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		err = util.RunCommand("umount", m.Mountpoint)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *pathVolume) listSnapshotNames() ([]string, error) {
+	entries, err := os.ReadDir(v.path + ".snapshots")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ret []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ret = append(ret, e.Name())
+		}
+	}
+	return ret, nil
+}
+
+// checkNotMounted ensures that the volume is not bind mounted anywhere, which is required before the volume
+// directory gets replaced
+func (v *pathVolume) checkNotMounted() error {
+	mounts, err := findBindMounts(v.path)
+	if err != nil {
+		return err
+	}
+	if len(mounts) != 0 {
+		return fmt.Errorf("the volume is still mounted at %s", mounts[0].Mountpoint)
+	}
+	return nil
+}
+
+// prepareRollback verifies that the snapshot exists and returns the temporary paths used to swap the volume
+func (v *pathVolume) prepareRollback(snapshotName string) (string, string, error) {
+	err := v.checkNotMounted()
+	if err != nil {
+		return "", "", err
+	}
+	_, err = os.Stat(v.snapshotPath(snapshotName))
+	if err != nil {
+		return "", "", fmt.Errorf("snapshot %s not found: %w", snapshotName, err)
+	}
+	return v.path + ".rollback-new", v.path + ".rollback-old", nil
+}
+
+// swapRollback moves newPath in place of the volume. The old volume ends up at oldPath and must be removed by the
+// caller
+func (v *pathVolume) swapRollback(newPath string, oldPath string) error {
+	err := os.Rename(v.path, oldPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(newPath, v.path)
+}
+
+func (v *pathVolume) GetSize() (int64, error) {
+	b, err := os.ReadFile(v.sizeFile())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func (v *pathVolume) writeSize(size int64) error {
+	return os.WriteFile(v.sizeFile(), []byte(strconv.FormatInt(size, 10)), 0600)
+}
+
+func (v *pathVolume) Release() error {
+	return nil
+}
+
+func (v *pathVolume) Close() error {
+	return nil
+}
+
+func (v *pathVolume) removeMetadata() error {
+	err := os.RemoveAll(v.path + ".snapshots")
+	if err != nil {
+		return err
+	}
+	err = os.Remove(v.sizeFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func isMountPoint(path string) (bool, error) {
+	mounts, err := mountinfo.GetMounts(mountinfo.SingleEntryFilter(path))
+	if err != nil {
+		return false, err
+	}
+	return len(mounts) != 0, nil
+}
+
+// findBindMounts finds all mounts (other than path itself) which show the given path of a host filesystem
+func findBindMounts(path string) ([]*mountinfo.Info, error) {
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent *mountinfo.Info
+	for _, m := range mounts {
+		if m.Mountpoint != "/" && path != m.Mountpoint && !strings.HasPrefix(path, m.Mountpoint+"/") {
+			continue
+		}
+		if parent == nil || len(m.Mountpoint) > len(parent.Mountpoint) {
+			parent = m
+		}
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("no mount found for %s", path)
+	}
+	root := filepath.Join(parent.Root, strings.TrimPrefix(path, parent.Mountpoint))
+
+	var ret []*mountinfo.Info
+	for _, m := range mounts {
+		if m.Major == parent.Major && m.Minor == parent.Minor && m.Root == root && m.Mountpoint != path {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,168 @@
+package volume
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/dboxed/dboxed-volume/pkg/fallocate"
+	"github.com/dboxed/dboxed-volume/pkg/losetup"
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+func (v *Volume) GetSize() (int64, error) {
+	lv, err := v.lvm.LVGet(v.fsLv.VgName, v.fsLv.LvName)
+	if err != nil {
+		return 0, err
+	}
+	return lvm.ParseSize(lv.LvSize)
+}
+
+// Grow grows all layers of the volume while it stays mounted at mountTarget, from the image file up to the filesystem.
+// Every step is skipped when it is already big enough, so an interrupted Grow can simply be retried.
+func (v *Volume) Grow(fsSize int64, mountTarget string) error {
+	err := v.growImage(fsSize * ImageSizeFactor)
+	if err != nil {
+		return err
+	}
+	err = v.extendThinPoolData()
+	if err != nil {
+		return err
+	}
+
+	lvSize, err := v.GetSize()
+	if err != nil {
+		return err
+	}
+	if lvSize < fsSize {
+		slog.Info("extending thin volume", slog.Any("oldSize", lvSize), slog.Any("newSize", fsSize))
+		err = v.lvm.LVExtend(v.fsLv.VgName, v.fsLv.LvName, fsSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.encryptionKey != nil {
+		err = cryptResize(buildCryptName(v.fsLv.VgName, v.fsLv.LvName), v.encryptionKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return v.growFs(mountTarget)
+}
+
+// extendThinPoolData gives all free space of the volume group to the thin pool
+func (v *Volume) extendThinPoolData() error {
+	vg, err := v.lvm.VGGet(v.fsLv.VgName)
+	if err != nil {
+		return err
+	}
+	vgFree, err := lvm.ParseSize(vg.VgFree)
+	if err != nil {
+		return err
+	}
+	if vgFree == 0 {
+		return nil
+	}
+	slog.Info("extending thin pool", slog.Any("vgFree", vgFree))
+	return v.lvm.LVExtend100(v.tpLv.VgName, v.tpLv.LvName)
+}
+
+// growImage grows the image file, the loop device and the physical volume. Whether the loop device and the physical
+// volume need to be resized is decided by the size of the physical volume, so that a retry after an interrupted grow
+// still resizes them even though the image file is already big enough.
+func (v *Volume) growImage(imageSize int64) error {
+	st, err := os.Stat(v.image)
+	if err != nil {
+		return err
+	}
+	if st.Size() < imageSize {
+		slog.Info("growing image", slog.Any("oldSize", st.Size()), slog.Any("newSize", imageSize))
+		f, err := os.OpenFile(v.image, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = fallocate.Fallocate(f, 0, imageSize)
+		if err != nil {
+			return err
+		}
+	} else {
+		imageSize = st.Size()
+	}
+
+	pvTooSmall, err := v.isPvTooSmall(imageSize)
+	if err != nil {
+		return err
+	}
+	if !pvTooSmall {
+		return nil
+	}
+
+	slog.Info("resizing loop device and physical volume", slog.Any("imageSize", imageSize))
+	err = losetup.SetCapacity(v.loDev)
+	if err != nil {
+		return err
+	}
+	err = v.lvm.PVResize(v.loDev)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// isPvTooSmall returns true if at least one more extent would fit into the physical volume. The physical volume only
+// counts whole extents after the metadata area, so it is always a bit smaller than the image.
+func (v *Volume) isPvTooSmall(imageSize int64) (bool, error) {
+	pv, err := v.lvm.PVGet(v.loDev)
+	if err != nil {
+		return false, err
+	}
+	pvSize, err := lvm.ParseSize(pv.PvSize)
+	if err != nil {
+		return false, err
+	}
+	peStart, err := lvm.ParseSize(pv.PeStart)
+	if err != nil {
+		return false, err
+	}
+	vg, err := v.lvm.VGGet(v.fsLv.VgName)
+	if err != nil {
+		return false, err
+	}
+	extentSize, err := lvm.ParseSize(vg.VgExtentSize)
+	if err != nil {
+		return false, err
+	}
+	return pvSize+peStart+extentSize <= imageSize, nil
+}
+
+func (v *Volume) growFs(mountTarget string) error {
+	fsType, err := getFsType(v.FsDevName())
+	if err != nil {
+		return err
+	}
+
+	slog.Info("growing filesystem", slog.Any("fsType", fsType))
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		return util.RunCommand("resize2fs", v.FsDevName())
+	case "xfs":
+		return util.RunCommand("xfs_growfs", mountTarget)
+	case "btrfs":
+		return util.RunCommand("btrfs", "filesystem", "resize", "max", mountTarget)
+	default:
+		return fmt.Errorf("growing %s filesystems is not supported", fsType)
+	}
+}
+
+func getFsType(dev string) (string, error) {
+	stdout, err := util.RunCommandStdout("blkid", "-o", "value", "-s", "TYPE", dev)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
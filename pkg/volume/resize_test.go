@@ -0,0 +1,35 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+)
+
+func TestIsPvTooSmall(t *testing.T) {
+	const mib = 1024 * 1024
+	f := &fakeLvm{
+		// a 1GiB image with 1MiB of metadata and 4MiB extents
+		pv: lvm.PVEntry{PvSize: "1069547520", PeStart: "1048576"},
+		vg: lvm.VGEntry{VgExtentSize: "4194304"},
+	}
+	v := &Volume{lvm: f, fsLv: &lvm.LVEntry{VgName: "vg"}}
+
+	tests := []struct {
+		imageSize int64
+		tooSmall  bool
+	}{
+		{1024 * mib, false},
+		{1025 * mib, true},
+		{2048 * mib, true},
+	}
+	for _, tt := range tests {
+		tooSmall, err := v.isPvTooSmall(tt.imageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tooSmall != tt.tooSmall {
+			t.Fatalf("image size %d: tooSmall = %v, want %v", tt.imageSize, tooSmall, tt.tooSmall)
+		}
+	}
+}
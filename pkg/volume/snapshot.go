@@ -11,7 +11,7 @@ import (
 )
 
 func (v *Volume) CreateSnapshot(snapshotName string, overwrite bool) error {
-	snapLv, err := lvm.LVGet(v.fsLv.VgName, snapshotName)
+	snapLv, err := v.lvm.LVGet(v.fsLv.VgName, snapshotName)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -22,7 +22,7 @@ func (v *Volume) CreateSnapshot(snapshotName string, overwrite bool) error {
 			return fmt.Errorf("snapshot %s already exists", snapshotName)
 		}
 		slog.Info("snapshot already exists, removing it", slog.Any("snapshotName", snapshotName))
-		err = lvm.LVRemove(v.fsLv.VgName, snapshotName)
+		err = v.lvm.LVRemove(v.fsLv.VgName, snapshotName)
 		if err != nil {
 			return err
 		}
@@ -31,7 +31,7 @@ func (v *Volume) CreateSnapshot(snapshotName string, overwrite bool) error {
 	_ = util.RunCommand("sync")
 
 	slog.Info("creating snapshot", slog.Any("snapshotName", snapshotName))
-	err = lvm.TLVSnapCreate(v.fsLv.VgName, v.fsLv.LvName, v.tpLv.LvName, snapshotName)
+	err = v.lvm.TLVSnapCreate(v.fsLv.VgName, v.fsLv.LvName, v.tpLv.LvName, snapshotName)
 	if err != nil {
 		return err
 	}
@@ -39,14 +39,14 @@ func (v *Volume) CreateSnapshot(snapshotName string, overwrite bool) error {
 	deferRemoveSnapshot := true
 	defer func() {
 		if deferRemoveSnapshot {
-			err := lvm.LVRemove(v.fsLv.VgName, snapshotName)
+			err := v.lvm.LVRemove(v.fsLv.VgName, snapshotName)
 			if err != nil {
 				slog.Error("remove snapshot failed in defer", slog.Any("error", err))
 			}
 		}
 	}()
 
-	err = lvm.LVActivate(v.fsLv.VgName, snapshotName, true)
+	err = v.lvm.LVActivate(v.fsLv.VgName, snapshotName, true)
 	if err != nil {
 		return err
 	}
@@ -56,12 +56,19 @@ func (v *Volume) CreateSnapshot(snapshotName string, overwrite bool) error {
 }
 
 func (v *Volume) DeleteSnapshot(snapshotName string) error {
-	return lvm.LVRemove(v.fsLv.VgName, snapshotName)
+	err := v.closeCrypt(snapshotName)
+	if err != nil {
+		return err
+	}
+	return v.lvm.LVRemove(v.fsLv.VgName, snapshotName)
 }
 
 func (v *Volume) MountSnapshot(snapshotName string, mountTarget string) error {
-	lvDev := buildDevName(v.fsLv.VgName, snapshotName)
-	err := util.RunCommand("mount", "-oro", lvDev, mountTarget)
+	err := v.openCrypt(snapshotName, true)
+	if err != nil {
+		return err
+	}
+	err = util.RunCommand("mount", "-oro", v.buildFsDevName(snapshotName), mountTarget)
 	if err != nil {
 		return err
 	}
@@ -73,13 +80,207 @@ func (v *Volume) UnmountSnapshot(snapshotName string) error {
 	if err != nil {
 		return err
 	}
-	if !isMounted {
-		return nil
+	if isMounted {
+		err = util.RunCommand("umount", v.buildFsDevName(snapshotName))
+		if err != nil {
+			return err
+		}
+	}
+	return v.closeCrypt(snapshotName)
+}
+
+// ListSnapshots returns all snapshots of the volume
+func (v *Volume) ListSnapshots() ([]SnapshotInfo, error) {
+	lvs, err := v.lvm.ListLVs()
+	if err != nil {
+		return nil, err
+	}
+	var ret []SnapshotInfo
+	for _, lv := range lvs {
+		if lv.VgName != v.fsLv.VgName || lv.PoolLv != v.tpLv.LvName || lv.LvName == v.fsLv.LvName {
+			continue
+		}
+		t, err := lvm.ParseTime(lv.LvTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse creation time of snapshot %s: %w", lv.LvName, err)
+		}
+		ret = append(ret, SnapshotInfo{
+			Name: lv.LvName,
+			Time: t,
+		})
+	}
+	return ret, nil
+}
+
+const (
+	rollbackNewLvName = "_rollback_new"
+	rollbackOldLvName = "_rollback_old"
+)
+
+// Rollback swaps a new thin snapshot of the given snapshot in place of the fs logical volume. If one of the steps
+// fails, the steps that were already done are undone. If the process dies in the middle, Open recovers the volume via
+// recoverRollback.
+func (v *Volume) Rollback(snapshotName string) (retErr error) {
+	vgName := v.fsLv.VgName
+	fsLvName := v.fsLv.LvName
+
+	mounted, err := v.IsSnapshotMounted(fsLvName)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fmt.Errorf("the volume must be unmounted before rolling back")
+	}
+	_, err = v.lvm.LVGet(vgName, snapshotName)
+	if err != nil {
+		return fmt.Errorf("snapshot %s not found: %w", snapshotName, err)
+	}
+
+	// leftovers of an interrupted rollback
+	lvs, err := v.lvm.FindPVLVs(v.loDev)
+	if err != nil {
+		return err
+	}
+	_, err = recoverRollback(v.lvm, lvs, fsLvName)
+	if err != nil {
+		return err
+	}
+
+	err = v.closeCrypt(fsLvName)
+	if err != nil {
+		return err
+	}
+
+	var undo []func() error
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			err := undo[i]()
+			if err != nil {
+				slog.Error("undoing rollback step failed", slog.Any("error", err))
+				return
+			}
+		}
+	}()
+
+	slog.Info("rolling back volume", slog.Any("snapshotName", snapshotName))
+	err = v.lvm.TLVSnapCreate(vgName, snapshotName, v.tpLv.LvName, rollbackNewLvName)
+	if err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return v.lvm.LVRemove(vgName, rollbackNewLvName)
+	})
+
+	// Open finds the volume by its tag, so the tag must never be on two logical volumes at the same time. The old
+	// logical volume keeps the tag until the new one was renamed, which is the point where recoverRollback switches
+	// from undoing to finishing the rollback.
+	err = v.lvm.LVRename(vgName, fsLvName, rollbackOldLvName)
+	if err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return v.lvm.LVRename(vgName, rollbackOldLvName, fsLvName)
+	})
+	err = v.lvm.LVRename(vgName, rollbackNewLvName, fsLvName)
+	if err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return v.lvm.LVRename(vgName, fsLvName, rollbackNewLvName)
+	})
+	err = v.lvm.LVDelTag(vgName, rollbackOldLvName, "fs")
+	if err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return v.lvm.LVAddTag(vgName, rollbackOldLvName, "fs")
+	})
+	err = v.lvm.LVAddTag(vgName, fsLvName, "fs")
+	if err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return v.lvm.LVDelTag(vgName, fsLvName, "fs")
+	})
+	err = v.lvm.LVActivate(vgName, fsLvName, true)
+	if err != nil {
+		return err
+	}
+	undo = nil
+
+	v.fsLv, err = v.lvm.LVGet(vgName, fsLvName)
+	if err != nil {
+		return err
 	}
 
-	lvDev := buildDevName(v.fsLv.VgName, snapshotName)
-	err = util.RunCommand("umount", lvDev)
-	return err
+	// if this fails, the next Open removes the old logical volume
+	err = v.lvm.LVRemove(vgName, rollbackOldLvName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// recoverRollback finishes or undoes a rollback that was interrupted. As long as the new logical volume was not
+// renamed to the fs name, the rollback is undone. Otherwise, it is finished. Returns true if anything was changed.
+func recoverRollback(l lvm.Backend, lvs []lvm.LVEntry, fsLvName string) (bool, error) {
+	var fsLv, newLv, oldLv *lvm.LVEntry
+	for i := range lvs {
+		switch lvs[i].LvName {
+		case fsLvName:
+			fsLv = &lvs[i]
+		case rollbackNewLvName:
+			newLv = &lvs[i]
+		case rollbackOldLvName:
+			oldLv = &lvs[i]
+		}
+	}
+	if newLv == nil && oldLv == nil {
+		return false, nil
+	}
+
+	if newLv != nil {
+		slog.Warn("undoing interrupted rollback")
+		if fsLv == nil {
+			if oldLv == nil {
+				return false, fmt.Errorf("neither %s nor %s found", fsLvName, rollbackOldLvName)
+			}
+			err := l.LVRename(oldLv.VgName, rollbackOldLvName, fsLvName)
+			if err != nil {
+				return false, err
+			}
+		}
+		err := l.LVRemove(newLv.VgName, rollbackNewLvName)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	slog.Warn("finishing interrupted rollback")
+	if fsLv == nil {
+		return false, fmt.Errorf("%s found without %s", rollbackOldLvName, fsLvName)
+	}
+	if oldLv.LvTags == "fs" {
+		err := l.LVDelTag(oldLv.VgName, rollbackOldLvName, "fs")
+		if err != nil {
+			return false, err
+		}
+	}
+	if fsLv.LvTags != "fs" {
+		err := l.LVAddTag(fsLv.VgName, fsLvName, "fs")
+		if err != nil {
+			return false, err
+		}
+	}
+	err := l.LVRemove(oldLv.VgName, rollbackOldLvName)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (v *Volume) IsSnapshotMounted(snapshotName string) (bool, error) {
@@ -87,10 +288,10 @@ func (v *Volume) IsSnapshotMounted(snapshotName string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	lvDev := buildDevName(v.fsLv.VgName, snapshotName)
+	fsDev := v.buildFsDevName(snapshotName)
 
 	for _, m := range mounts {
-		if m.Source == lvDev {
+		if m.Source == fsDev {
 			return true, nil
 		}
 	}
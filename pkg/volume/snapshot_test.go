@@ -0,0 +1,96 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+)
+
+// fakeLvm only implements what the tests need. ThinId is used to identify the logical volumes across renames.
+type fakeLvm struct {
+	lvm.Backend
+	lvs []lvm.LVEntry
+	pv  lvm.PVEntry
+	vg  lvm.VGEntry
+}
+
+func (f *fakeLvm) PVGet(pvName string) (*lvm.PVEntry, error) {
+	return &f.pv, nil
+}
+
+func (f *fakeLvm) VGGet(vgName string) (*lvm.VGEntry, error) {
+	return &f.vg, nil
+}
+
+func (f *fakeLvm) find(lvName string) *lvm.LVEntry {
+	for i := range f.lvs {
+		if f.lvs[i].LvName == lvName {
+			return &f.lvs[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeLvm) LVRename(vgName string, lvName string, newLvName string) error {
+	f.find(lvName).LvName = newLvName
+	return nil
+}
+
+func (f *fakeLvm) LVAddTag(vgName string, lvName string, tag string) error {
+	f.find(lvName).LvTags = tag
+	return nil
+}
+
+func (f *fakeLvm) LVDelTag(vgName string, lvName string, tag string) error {
+	f.find(lvName).LvTags = ""
+	return nil
+}
+
+func (f *fakeLvm) LVRemove(vgName string, lvName string) error {
+	for i := range f.lvs {
+		if f.lvs[i].LvName == lvName {
+			f.lvs = append(f.lvs[:i], f.lvs[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestRecoverRollback(t *testing.T) {
+	lv := func(name string, tags string, id string) lvm.LVEntry {
+		return lvm.LVEntry{LvName: name, VgName: "vg", LvTags: tags, ThinId: id}
+	}
+
+	tests := []struct {
+		name      string
+		lvs       []lvm.LVEntry
+		recovered bool
+		fsId      string
+	}{
+		{"nothing to do", []lvm.LVEntry{lv("fs", "fs", "old")}, false, "old"},
+		{"snapshot created", []lvm.LVEntry{lv("fs", "fs", "old"), lv(rollbackNewLvName, "", "new")}, true, "old"},
+		{"old renamed", []lvm.LVEntry{lv(rollbackOldLvName, "fs", "old"), lv(rollbackNewLvName, "", "new")}, true, "old"},
+		{"new renamed", []lvm.LVEntry{lv(rollbackOldLvName, "fs", "old"), lv("fs", "", "new")}, true, "new"},
+		{"old untagged", []lvm.LVEntry{lv(rollbackOldLvName, "", "old"), lv("fs", "", "new")}, true, "new"},
+		{"new tagged", []lvm.LVEntry{lv(rollbackOldLvName, "", "old"), lv("fs", "fs", "new")}, true, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLvm{lvs: append([]lvm.LVEntry{lv("tp", "tp", "")}, tt.lvs...)}
+			recovered, err := recoverRollback(f, f.lvs, "fs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recovered != tt.recovered {
+				t.Fatalf("recovered = %v, want %v", recovered, tt.recovered)
+			}
+			if len(f.lvs) != 2 {
+				t.Fatalf("unexpected logical volumes left: %v", f.lvs)
+			}
+			fsLv := f.find("fs")
+			if fsLv == nil || fsLv.LvTags != "fs" || fsLv.ThinId != tt.fsId {
+				t.Fatalf("expected fs to be %s with fs tag, got %v", tt.fsId, fsLv)
+			}
+		})
+	}
+}
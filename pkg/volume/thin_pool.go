@@ -0,0 +1,61 @@
+package volume
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+)
+
+type ThinPoolUsage struct {
+	DataPercent     float64
+	MetadataPercent float64
+	MetadataSize    int64
+}
+
+func (v *Volume) GetThinPoolUsage() (*ThinPoolUsage, error) {
+	tp, err := v.lvm.LVGet(v.tpLv.VgName, v.tpLv.LvName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret ThinPoolUsage
+	ret.DataPercent, err = lvm.ParsePercent(tp.DataPercent)
+	if err != nil {
+		return nil, err
+	}
+	ret.MetadataPercent, err = lvm.ParsePercent(tp.MetadataPercent)
+	if err != nil {
+		return nil, err
+	}
+	ret.MetadataSize, err = lvm.ParseSize(tp.LvMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (v *Volume) GetImageSize() (int64, error) {
+	st, err := os.Stat(v.image)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// ExtendThinPool grows the image to imageSize, extends the thin pool metadata by metadataGrowth bytes and then gives
+// all remaining free space to the thin pool data
+func (v *Volume) ExtendThinPool(imageSize int64, metadataGrowth int64) error {
+	err := v.growImage(imageSize)
+	if err != nil {
+		return err
+	}
+	if metadataGrowth != 0 {
+		slog.Info("extending thin pool metadata", slog.Any("metadataGrowth", metadataGrowth))
+		err = v.lvm.TPExtendMetadata(v.tpLv.VgName, v.tpLv.LvName, metadataGrowth)
+		if err != nil {
+			return err
+		}
+	}
+	return v.extendThinPoolData()
+}
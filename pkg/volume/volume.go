@@ -2,6 +2,7 @@ package volume
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -22,11 +23,15 @@ type Volume struct {
 
 	loDev         string
 	attachedLoDev bool
+	lvm           lvm.Backend
 	fsLv          *lvm.LVEntry
 	tpLv          *lvm.LVEntry
+
+	encryptionKey []byte
 }
 
-func Open(image string) (*Volume, error) {
+// Open opens the volume stored in image. encryptionKey must be set for encrypted volumes and nil otherwise.
+func Open(image string, encryptionKey []byte) (*Volume, error) {
 	loDev, attached, err := losetup.GetOrAttach(image, true)
 	if err != nil {
 		return nil, err
@@ -38,10 +43,21 @@ func Open(image string) (*Volume, error) {
 		}
 	}()
 
-	lvs, err := lvm.FindPVLVs(loDev)
+	l := lvm.NewScoped(loDev)
+	lvs, err := l.FindPVLVs(loDev)
 	if err != nil {
 		return nil, err
 	}
+	recovered, err := recoverRollback(l, lvs, "fs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted rollback: %w", err)
+	}
+	if recovered {
+		lvs, err = l.FindPVLVs(loDev)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var fsLv *lvm.LVEntry
 	var tpLv *lvm.LVEntry
@@ -64,30 +80,99 @@ func Open(image string) (*Volume, error) {
 		image:         image,
 		loDev:         loDev,
 		attachedLoDev: attached,
+		lvm:           l,
 		fsLv:          fsLv,
 		tpLv:          tpLv,
+		encryptionKey: encryptionKey,
 	}
 
 	return v, nil
 }
 
-func (v *Volume) Close(detach bool) error {
-	if v.attachedLoDev {
-		if detach {
-			err := losetup.Detach(v.loDev)
-			if err != nil {
-				return err
-			}
-		}
-		v.attachedLoDev = false
+// Close releases the volume if the loop device was attached by Open
+func (v *Volume) Close() error {
+	if !v.attachedLoDev {
+		return nil
+	}
+	return v.Release()
+}
+
+func (v *Volume) Deactivate() error {
+	err := v.closeCrypt(v.fsLv.LvName)
+	if err != nil {
+		return err
+	}
+	return v.lvm.VGDeactivate(v.fsLv.VgName)
+}
+
+// Detach detaches the loop device, even if it was already attached before the volume was opened
+func (v *Volume) Detach() error {
+	err := losetup.Detach(v.loDev)
+	if err != nil {
+		return err
 	}
+	v.attachedLoDev = false
 	return nil
 }
 
+// Release deactivates the volume group and detaches the loop device
+func (v *Volume) Release() error {
+	err := v.Deactivate()
+	if err != nil {
+		return err
+	}
+	return v.Detach()
+}
+
+// Delete releases the volume and removes the image. The volume must not be mounted anymore.
+func (v *Volume) Delete() error {
+	err := v.Release()
+	if err != nil {
+		return err
+	}
+	return os.Remove(v.image)
+}
+
 func (v *Volume) DevName() string {
 	return buildDevName(v.fsLv.VgName, v.fsLv.LvName)
 }
 
+// FsDevName returns the device that holds the filesystem, which is the dm-crypt device for encrypted volumes
+func (v *Volume) FsDevName() string {
+	return v.buildFsDevName(v.fsLv.LvName)
+}
+
+func (v *Volume) buildFsDevName(lvName string) string {
+	if v.encryptionKey != nil {
+		return buildCryptDevName(buildCryptName(v.fsLv.VgName, lvName))
+	}
+	return buildDevName(v.fsLv.VgName, lvName)
+}
+
+// openCrypt unlocks the dm-crypt layer of the given logical volume. For unencrypted volumes, it only verifies that the
+// logical volume is really unencrypted.
+func (v *Volume) openCrypt(lvName string, readOnly bool) error {
+	lvDev := buildDevName(v.fsLv.VgName, lvName)
+	if v.encryptionKey == nil {
+		fsType, err := getFsType(lvDev)
+		if err != nil {
+			return err
+		}
+		if fsType == "crypto_LUKS" {
+			return fmt.Errorf("volume is encrypted, an encryption key is required")
+		}
+		return nil
+	}
+	return cryptOpen(lvDev, buildCryptName(v.fsLv.VgName, lvName), v.encryptionKey, readOnly)
+}
+
+func (v *Volume) closeCrypt(lvName string) error {
+	if v.encryptionKey == nil {
+		return nil
+	}
+	return cryptClose(buildCryptName(v.fsLv.VgName, lvName))
+}
+
 func escapeName(n string) string {
 	return strings.ReplaceAll(n, "-", "--")
 }
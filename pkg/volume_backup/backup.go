@@ -8,35 +8,39 @@ import (
 	"path/filepath"
 
 	"github.com/dboxed/dboxed-volume/pkg/client"
-	"github.com/dboxed/dboxed-volume/pkg/util"
 	"github.com/dboxed/dboxed-volume/pkg/volume"
-	"github.com/dboxed/dboxed-volume/pkg/webdavproxy"
 	"github.com/pelletier/go-toml/v2"
 )
 
+const volumeUuidTagPrefix = "dboxed-volume-uuid="
+const volumeNameTagPrefix = "dboxed-volume-name="
+
 type VolumeBackup struct {
 	Client *client.Client
-	Volume *volume.Volume
+	Volume volume.VolumeBackend
 
 	RepositoryId          int64
+	VolumeUuid            string
+	VolumeName            string
+	Hostname              string
 	RusticPassword        string
 	SnapshotMount         string
 	WebdavProxyListenAddr string
+
+	SnapshotHooks *SnapshotHooks
 }
 
-func (vb *VolumeBackup) Backup(ctx context.Context) error {
+func (vb *VolumeBackup) Backup(ctx context.Context) (*RusticSnapshot, *SnapshotHooksResult, error) {
 	snapshotName := "_backup"
 
-	_ = util.RunCommand("sync")
-
 	err := vb.Volume.UnmountSnapshot(snapshotName)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	err = vb.Volume.CreateSnapshot(snapshotName, true)
+	hooksResult, err := vb.createSnapshot(ctx, vb.Volume, snapshotName, true)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer func() {
 		err := vb.Volume.DeleteSnapshot(snapshotName)
@@ -47,7 +51,7 @@ func (vb *VolumeBackup) Backup(ctx context.Context) error {
 
 	err = vb.Volume.MountSnapshot(snapshotName, vb.SnapshotMount)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer func() {
 		err := vb.Volume.UnmountSnapshot(snapshotName)
@@ -56,35 +60,43 @@ func (vb *VolumeBackup) Backup(ctx context.Context) error {
 		}
 	}()
 
-	fs := webdavproxy.NewFileSystem(ctx, vb.Client, vb.RepositoryId)
-
-	webdavProxy, err := webdavproxy.NewProxy(fs, vb.WebdavProxyListenAddr)
+	rustic, err := vb.startRustic(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	wdpAddr, err := webdavProxy.Start(ctx)
-	if err != nil {
-		return err
+	defer rustic.Stop()
+
+	rusticArgs := []string{"backup", "--init", "--json"}
+	if vb.Hostname != "" {
+		rusticArgs = append(rusticArgs, "--host", vb.Hostname)
 	}
-	defer webdavProxy.Stop()
+	for _, t := range vb.buildTags() {
+		rusticArgs = append(rusticArgs, "--tag", t)
+	}
+	rusticArgs = append(rusticArgs, vb.SnapshotMount)
 
-	configDir, err := vb.buildRusticConfigDir(wdpAddr.String())
+	var snapshot RusticSnapshot
+	err = rustic.RunJson(&snapshot, rusticArgs...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer os.RemoveAll(configDir)
+	return &snapshot, hooksResult, nil
+}
 
-	rusticArgs := []string{"backup", "--init", vb.SnapshotMount}
-	c := util.CommandHelper{
-		Command: "rustic",
-		Args:    rusticArgs,
-		Dir:     configDir,
+func (vb *VolumeBackup) buildTags() []string {
+	tags := []string{
+		volumeUuidTagPrefix + vb.VolumeUuid,
 	}
-	err = c.Run()
-	if err != nil {
-		return err
+	if vb.VolumeName != "" {
+		tags = append(tags, volumeNameTagPrefix+vb.VolumeName)
 	}
-	return nil
+	return tags
+}
+
+// buildSnapshotFilterArgs returns the global rustic arguments required to only operate on snapshots of this volume.
+// We only filter by the volume uuid, as the hostname changes when a volume is moved to another host.
+func (vb *VolumeBackup) buildSnapshotFilterArgs() []string {
+	return []string{"--filter-tags", volumeUuidTagPrefix + vb.VolumeUuid}
 }
 
 func (vb *VolumeBackup) buildRusticConfigDir(webdavAddr string) (string, error) {
@@ -0,0 +1,399 @@
+package volume_backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+const (
+	BackupModeFiles  = "files"
+	BackupModeBlocks = "blocks"
+)
+
+// the snapshot of the last block level backup is kept until the next backup, so that thin_delta can tell which blocks
+// changed in the meantime
+const blockSnapshotPrefix = "_block_backup_"
+
+const blockChunkSize = 4 * 1024 * 1024
+const blockTransferWorkers = 8
+
+type BlockBackupSummary struct {
+	ChunksTotal    int64
+	ChunksChanged  int64
+	ChunksUploaded int64
+	BytesProcessed int64
+	BytesUploaded  int64
+	Duration       time.Duration
+
+	SnapshotHooks *SnapshotHooksResult
+}
+
+func (vb *VolumeBackup) blockBackend() (volume.BlockBackend, error) {
+	bv, ok := vb.Volume.(volume.BlockBackend)
+	if !ok {
+		return nil, fmt.Errorf("the volume backend does not support block level backups")
+	}
+	return bv, nil
+}
+
+// BackupBlocks performs a block level backup. If the snapshot of the previous backup is still present, only the chunks
+// which changed since then are read, otherwise the whole volume is read. In both cases, only chunks which are not
+// already referenced by the previous backup are uploaded.
+func (vb *VolumeBackup) BackupBlocks(ctx context.Context) (*BlockManifest, *BlockBackupSummary, error) {
+	bv, err := vb.blockBackend()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer store.Close()
+
+	start := time.Now()
+	summary := &BlockBackupSummary{}
+
+	parent, err := vb.getLatestBlockManifest(ctx, store)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent != nil && parent.ChunkSize != blockChunkSize {
+		slog.InfoContext(ctx, "previous block backup uses a different chunk size, performing a full backup")
+		parent = nil
+	}
+
+	id, err := newBlockManifestId(start)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshotName := blockSnapshotPrefix + id
+
+	prevSnapshotName, err := vb.cleanupBlockSnapshots(bv, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary.SnapshotHooks, err = vb.createSnapshot(ctx, bv, snapshotName, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	keepSnapshot := false
+	defer func() {
+		if !keepSnapshot {
+			err := bv.DeleteSnapshot(snapshotName)
+			if err != nil {
+				slog.ErrorContext(ctx, "block backup snapshot deletion failed", slog.Any("error", err))
+			}
+		}
+	}()
+
+	size, err := bv.GetSnapshotSize(snapshotName)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &BlockManifest{
+		ID:         id,
+		Time:       start,
+		Hostname:   vb.Hostname,
+		VolumeUuid: vb.VolumeUuid,
+		VolumeName: vb.VolumeName,
+		Size:       size,
+		ChunkSize:  blockChunkSize,
+		Chunks:     make([]string, (size+blockChunkSize-1)/blockChunkSize),
+	}
+	summary.ChunksTotal = int64(len(m.Chunks))
+
+	var changed []int64
+	if parent != nil && prevSnapshotName != "" {
+		m.Parent = parent.ID
+		copy(m.Chunks, parent.Chunks)
+		changed, err = vb.getChangedChunks(bv, prevSnapshotName, snapshotName, int64(len(m.Chunks)))
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if parent != nil {
+			m.Parent = parent.ID
+		}
+		for i := range int64(len(m.Chunks)) {
+			changed = append(changed, i)
+		}
+	}
+	summary.ChunksChanged = int64(len(changed))
+
+	known := map[string]struct{}{}
+	if parent != nil {
+		for _, c := range parent.Chunks {
+			known[c] = struct{}{}
+		}
+	}
+
+	slog.InfoContext(ctx, "starting block backup",
+		slog.Any("id", id),
+		slog.Any("parent", m.Parent),
+		slog.Any("chunksTotal", summary.ChunksTotal),
+		slog.Any("chunksChanged", summary.ChunksChanged),
+	)
+
+	f, err := os.Open(bv.SnapshotDevName(snapshotName))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var knownMutex sync.Mutex
+	zeros := make([]byte, blockChunkSize)
+	err = runParallel(ctx, changed, func(idx int64) error {
+		off := idx * blockChunkSize
+		buf := make([]byte, min(blockChunkSize, size-off))
+		_, err := f.ReadAt(buf, off)
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&summary.BytesProcessed, int64(len(buf)))
+
+		if bytes.Equal(buf, zeros[:len(buf)]) {
+			m.Chunks[idx] = ""
+			return nil
+		}
+
+		chunkId := store.chunkId(buf)
+		m.Chunks[idx] = chunkId
+
+		knownMutex.Lock()
+		_, ok := known[chunkId]
+		known[chunkId] = struct{}{}
+		knownMutex.Unlock()
+		if ok {
+			return nil
+		}
+
+		n, err := store.putChunk(ctx, chunkId, buf)
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&summary.ChunksUploaded, 1)
+		atomic.AddInt64(&summary.BytesUploaded, n)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = store.putManifest(ctx, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keepSnapshot = true
+	if prevSnapshotName != "" {
+		err = bv.DeleteSnapshot(prevSnapshotName)
+		if err != nil {
+			slog.ErrorContext(ctx, "deleting previous block backup snapshot failed", slog.Any("error", err))
+		}
+	}
+
+	summary.Duration = time.Since(start)
+	return m, summary, nil
+}
+
+// cleanupBlockSnapshots deletes all block backup snapshots except the one belonging to the parent manifest, which is
+// returned if it exists
+func (vb *VolumeBackup) cleanupBlockSnapshots(bv volume.BlockBackend, parent *BlockManifest) (string, error) {
+	snapshots, err := bv.ListSnapshots()
+	if err != nil {
+		return "", err
+	}
+	var ret string
+	for _, s := range snapshots {
+		if !strings.HasPrefix(s.Name, blockSnapshotPrefix) {
+			continue
+		}
+		if parent != nil && s.Name == blockSnapshotPrefix+parent.ID {
+			ret = s.Name
+			continue
+		}
+		slog.Info("deleting stale block backup snapshot", slog.Any("snapshotName", s.Name))
+		err = bv.DeleteSnapshot(s.Name)
+		if err != nil {
+			return "", err
+		}
+	}
+	return ret, nil
+}
+
+func (vb *VolumeBackup) getChangedChunks(bv volume.BlockBackend, oldSnapshotName string, newSnapshotName string, chunkCount int64) ([]int64, error) {
+	ranges, err := bv.GetChangedRanges(oldSnapshotName, newSnapshotName)
+	if err != nil {
+		return nil, err
+	}
+	changed := map[int64]struct{}{}
+	for _, r := range ranges {
+		if r.Length == 0 {
+			continue
+		}
+		first := r.Begin / blockChunkSize
+		last := (r.Begin + r.Length - 1) / blockChunkSize
+		for i := first; i <= last && i < chunkCount; i++ {
+			changed[i] = struct{}{}
+		}
+	}
+	ret := make([]int64, 0, len(changed))
+	for i := range changed {
+		ret = append(ret, i)
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// runParallel calls fn for every chunk index with a limited number of workers and returns the first error
+func runParallel(ctx context.Context, indexes []int64, fn func(idx int64) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	ch := make(chan int64)
+	var wg sync.WaitGroup
+	for range blockTransferWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range ch {
+				err := fn(idx)
+				if err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+loop:
+	for _, idx := range indexes {
+		select {
+		case ch <- idx:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+func (vb *VolumeBackup) ListBlockManifests(ctx context.Context) ([]BlockManifestInfo, error) {
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return vb.listBlockManifests(ctx, store)
+}
+
+func (vb *VolumeBackup) listBlockManifests(ctx context.Context, store *blockStore) ([]BlockManifestInfo, error) {
+	ids, err := store.listManifestIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ret []BlockManifestInfo
+	for _, id := range ids {
+		info, err := parseBlockManifestId(id)
+		if err != nil {
+			slog.WarnContext(ctx, "ignoring invalid block manifest", slog.Any("error", err))
+			continue
+		}
+		ret = append(ret, *info)
+	}
+	slices.SortFunc(ret, func(a, b BlockManifestInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return ret, nil
+}
+
+// GetLatestBlockManifest returns the newest block level backup or nil if there are no backups yet
+func (vb *VolumeBackup) GetLatestBlockManifest(ctx context.Context) (*BlockManifest, error) {
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return vb.getLatestBlockManifest(ctx, store)
+}
+
+func (vb *VolumeBackup) getLatestBlockManifest(ctx context.Context, store *blockStore) (*BlockManifest, error) {
+	infos, err := vb.listBlockManifests(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, nil
+	}
+	return store.getManifest(ctx, infos[len(infos)-1].ID)
+}
+
+// FindBlockManifest finds a block level backup by its full ID or by an unambiguous ID prefix
+func (vb *VolumeBackup) FindBlockManifest(ctx context.Context, id string) (*BlockManifest, error) {
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	infos, err := vb.listBlockManifests(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *BlockManifestInfo
+	for _, info := range infos {
+		if info.ID == id {
+			found = &info
+			break
+		}
+		if strings.HasPrefix(info.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("block backup id %s is ambiguous", id)
+			}
+			found = &info
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("block backup %s not found", id)
+	}
+	return store.getManifest(ctx, found.ID)
+}
+
+// FindBlockManifestAt finds the newest block level backup that was taken at or before the given time
+func (vb *VolumeBackup) FindBlockManifestAt(ctx context.Context, t time.Time) (*BlockManifest, error) {
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	infos, err := vb.listBlockManifests(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *BlockManifestInfo
+	for _, info := range infos {
+		if info.Time.After(t) {
+			continue
+		}
+		found = &info
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no block backup found at or before %s", t.Format(time.RFC3339))
+	}
+	return store.getManifest(ctx, found.ID)
+}
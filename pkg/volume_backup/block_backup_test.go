@@ -0,0 +1,119 @@
+package volume_backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dboxed/dboxed-volume/pkg/lvm"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+type fakeBlockBackend struct {
+	volume.BlockBackend
+
+	ranges []lvm.BlockRange
+	err    error
+}
+
+func (f *fakeBlockBackend) GetChangedRanges(oldSnapshotName string, newSnapshotName string) ([]lvm.BlockRange, error) {
+	return f.ranges, f.err
+}
+
+func TestGetChangedChunks(t *testing.T) {
+	const cs = blockChunkSize
+
+	tests := []struct {
+		name     string
+		ranges   []lvm.BlockRange
+		expected []int64
+	}{
+		{"no changes", nil, []int64{}},
+		{"empty range", []lvm.BlockRange{{Begin: cs, Length: 0}}, []int64{}},
+		{"single byte", []lvm.BlockRange{{Begin: cs + 10, Length: 1}}, []int64{1}},
+		{"whole chunk", []lvm.BlockRange{{Begin: cs, Length: cs}}, []int64{1}},
+		{"crossing chunk boundary", []lvm.BlockRange{{Begin: cs - 1, Length: 2}}, []int64{0, 1}},
+		{"multiple chunks", []lvm.BlockRange{{Begin: 0, Length: 3 * cs}}, []int64{0, 1, 2}},
+		{"unsorted and overlapping", []lvm.BlockRange{{Begin: 5 * cs, Length: 1}, {Begin: 0, Length: 1}, {Begin: 0, Length: cs + 1}}, []int64{0, 1, 5}},
+		{"beyond the end", []lvm.BlockRange{{Begin: 6 * cs, Length: 4 * cs}, {Begin: 20 * cs, Length: 1}}, []int64{6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vb := &VolumeBackup{}
+			changed, err := vb.getChangedChunks(&fakeBlockBackend{ranges: tt.ranges}, "old", "new", 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(changed, tt.expected) {
+				t.Fatalf("got %v, want %v", changed, tt.expected)
+			}
+		})
+	}
+
+	_, err := (&VolumeBackup{}).getChangedChunks(&fakeBlockBackend{err: fmt.Errorf("thin_delta failed")}, "old", "new", 8)
+	if err == nil {
+		t.Fatal("expected error from GetChangedRanges to be returned")
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	var indexes []int64
+	for i := range int64(100) {
+		indexes = append(indexes, i)
+	}
+
+	var m sync.Mutex
+	var called []int64
+	err := runParallel(context.Background(), indexes, func(idx int64) error {
+		m.Lock()
+		defer m.Unlock()
+		called = append(called, idx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(called)
+	if !slices.Equal(called, indexes) {
+		t.Fatalf("not all indexes were processed exactly once: %v", called)
+	}
+}
+
+func TestRunParallelError(t *testing.T) {
+	var indexes []int64
+	for i := range int64(10000) {
+		indexes = append(indexes, i)
+	}
+
+	failErr := errors.New("chunk failed")
+	var calls atomic.Int64
+	err := runParallel(context.Background(), indexes, func(idx int64) error {
+		calls.Add(1)
+		if idx == 10 {
+			return failErr
+		}
+		return nil
+	})
+	if !errors.Is(err, failErr) {
+		t.Fatalf("got error %v, want %v", err, failErr)
+	}
+	if calls.Load() == int64(len(indexes)) {
+		t.Fatal("remaining indexes must not be processed after an error")
+	}
+}
+
+func TestRunParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runParallel(ctx, []int64{1, 2, 3}, func(idx int64) error {
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,73 @@
+package volume_backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const blockManifestTimeFormat = "20060102T150405Z"
+
+// BlockManifest describes a block level backup. Chunks maps every chunk of the volume to the id of the stored chunk,
+// with an empty id for chunks that only contain zeros.
+type BlockManifest struct {
+	ID         string    `json:"id"`
+	Parent     string    `json:"parent,omitempty"`
+	Time       time.Time `json:"time"`
+	Hostname   string    `json:"hostname"`
+	VolumeUuid string    `json:"volumeUuid"`
+	VolumeName string    `json:"volumeName,omitempty"`
+
+	Size      int64    `json:"size"`
+	ChunkSize int64    `json:"chunkSize"`
+	Chunks    []string `json:"chunks"`
+}
+
+// BlockManifestInfo is the information about a block level backup that can be derived without loading the manifest
+type BlockManifestInfo struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+func newBlockManifestId(t time.Time) (string, error) {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", t.UTC().Format(blockManifestTimeFormat), hex.EncodeToString(b)), nil
+}
+
+func parseBlockManifestId(id string) (*BlockManifestInfo, error) {
+	ts, _, ok := strings.Cut(id, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid block manifest id %s", id)
+	}
+	t, err := time.Parse(blockManifestTimeFormat, ts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block manifest id %s: %w", id, err)
+	}
+	return &BlockManifestInfo{
+		ID:   id,
+		Time: t,
+	}, nil
+}
+
+func (m *BlockManifest) marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func unmarshalBlockManifest(b []byte) (*BlockManifest, error) {
+	var m BlockManifest
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+	if m.ChunkSize <= 0 || int64(len(m.Chunks)) != (m.Size+m.ChunkSize-1)/m.ChunkSize {
+		return nil, fmt.Errorf("block manifest %s is inconsistent", m.ID)
+	}
+	return &m, nil
+}
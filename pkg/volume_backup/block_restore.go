@@ -0,0 +1,71 @@
+package volume_backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+)
+
+// RestoreBlocks writes a block level backup to the given block device. The device is discarded first, so that chunks
+// which only contain zeros don't need to be written. The device must not be in use.
+func (vb *VolumeBackup) RestoreBlocks(ctx context.Context, m *BlockManifest, dev string) error {
+	if m.VolumeUuid != vb.VolumeUuid {
+		return fmt.Errorf("block backup %s does not belong to volume %s", m.ID, vb.VolumeUuid)
+	}
+
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	f, err := os.OpenFile(dev, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	devSize, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if devSize < m.Size {
+		return fmt.Errorf("device %s is smaller than the backup (%d < %d)", dev, devSize, m.Size)
+	}
+
+	slog.InfoContext(ctx, "restoring block backup",
+		slog.Any("id", m.ID),
+		slog.Any("time", m.Time),
+		slog.Any("dev", dev),
+	)
+
+	err = util.RunCommand("blkdiscard", dev)
+	if err != nil {
+		return err
+	}
+
+	var indexes []int64
+	for i, c := range m.Chunks {
+		if c != "" {
+			indexes = append(indexes, int64(i))
+		}
+	}
+
+	err = runParallel(ctx, indexes, func(idx int64) error {
+		data, err := store.getChunk(ctx, m.Chunks[idx])
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteAt(data, idx*m.ChunkSize)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
@@ -0,0 +1,130 @@
+package volume_backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+)
+
+// ForgetBlocks deletes all block level backups which are not kept by the retention policy. The policy is applied the
+// same way rustic does it: every keep rule keeps the newest backup of each of the last n periods.
+func (vb *VolumeBackup) ForgetBlocks(ctx context.Context, retention models.VolumeRetention) error {
+	if retention.IsEmpty() {
+		return fmt.Errorf("refusing to forget block backups without a retention policy")
+	}
+
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	infos, err := vb.listBlockManifests(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	keep := applyRetention(infos, retention)
+	for _, info := range infos {
+		if _, ok := keep[info.ID]; ok {
+			continue
+		}
+		slog.InfoContext(ctx, "forgetting block backup", slog.Any("id", info.ID))
+		err = store.deleteManifest(ctx, info.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyRetention(infos []BlockManifestInfo, retention models.VolumeRetention) map[string]struct{} {
+	sorted := slices.Clone(infos)
+	slices.SortFunc(sorted, func(a, b BlockManifestInfo) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	keep := map[string]struct{}{}
+	keepPeriods := func(n *int64, period func(t time.Time) string) {
+		if n == nil {
+			return
+		}
+		seen := map[string]struct{}{}
+		for _, info := range sorted {
+			if int64(len(seen)) >= *n {
+				break
+			}
+			p := period(info.Time)
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			keep[info.ID] = struct{}{}
+		}
+	}
+
+	keepPeriods(retention.KeepLast, func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	})
+	keepPeriods(retention.KeepHourly, func(t time.Time) string {
+		return t.Format("2006-01-02 15")
+	})
+	keepPeriods(retention.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(retention.KeepWeekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", y, w)
+	})
+	keepPeriods(retention.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+	return keep
+}
+
+// PruneBlocks deletes all chunks which are not referenced by any block level backup anymore. Chunks are stored per
+// volume, so this must not run concurrently to a block level backup of the same volume.
+func (vb *VolumeBackup) PruneBlocks(ctx context.Context) error {
+	store, err := vb.newBlockStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	infos, err := vb.listBlockManifests(ctx, store)
+	if err != nil {
+		return err
+	}
+	referenced := map[string]struct{}{}
+	for _, info := range infos {
+		m, err := store.getManifest(ctx, info.ID)
+		if err != nil {
+			return err
+		}
+		for _, c := range m.Chunks {
+			referenced[c] = struct{}{}
+		}
+	}
+
+	chunkIds, err := store.listChunkIds(ctx)
+	if err != nil {
+		return err
+	}
+	var deleted int
+	for _, id := range chunkIds {
+		if _, ok := referenced[id]; ok {
+			continue
+		}
+		err = store.deleteChunk(ctx, id)
+		if err != nil {
+			return err
+		}
+		deleted++
+	}
+	slog.InfoContext(ctx, "pruned block backup chunks", slog.Any("deleted", deleted), slog.Any("remaining", len(chunkIds)-deleted))
+	return nil
+}
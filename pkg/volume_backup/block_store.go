@@ -0,0 +1,330 @@
+package volume_backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/klauspost/compress/zstd"
+)
+
+// blockStore stores the chunks and manifests of block level backups in the repository's bucket. All objects are
+// compressed and encrypted with a key derived from the rustic password of the repository. Chunks are addressed by
+// a keyed hash of their plain content, so that the object names don't leak anything about the content.
+type blockStore struct {
+	client       *client.Client
+	repositoryId int64
+	prefix       string
+
+	aead   cipher.AEAD
+	macKey []byte
+
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+
+	m        sync.Mutex
+	dirCache map[string]*blockStoreDir
+}
+
+type blockStoreDir struct {
+	urls    map[string]string
+	expires time.Time
+}
+
+func (vb *VolumeBackup) newBlockStore() (*blockStore, error) {
+	encKey := sha256.Sum256([]byte("dboxed-volume-blocks-enc:" + vb.RusticPassword))
+	macKey := sha256.Sum256([]byte("dboxed-volume-blocks-mac:" + vb.RusticPassword))
+
+	block, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockStore{
+		client:       vb.Client,
+		repositoryId: vb.RepositoryId,
+		prefix:       path.Join("blocks", vb.VolumeUuid),
+		aead:         aead,
+		macKey:       macKey[:],
+		encoder:      encoder,
+		decoder:      decoder,
+		dirCache:     map[string]*blockStoreDir{},
+	}, nil
+}
+
+func (s *blockStore) Close() {
+	_ = s.encoder.Close()
+	s.decoder.Close()
+}
+
+func (s *blockStore) chunkId(data []byte) string {
+	h := hmac.New(sha256.New, s.macKey)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *blockStore) chunkKey(id string) string {
+	return path.Join(s.prefix, "chunks", id[:2], id)
+}
+
+func (s *blockStore) manifestKey(id string) string {
+	return path.Join(s.prefix, "manifests", id)
+}
+
+func (s *blockStore) seal(data []byte) ([]byte, error) {
+	compressed := s.encoder.EncodeAll(data, nil)
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, compressed, nil), nil
+}
+
+func (s *blockStore) open(data []byte) ([]byte, error) {
+	if len(data) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("object too short")
+	}
+	nonce := data[:s.aead.NonceSize()]
+	compressed, err := s.aead.Open(nil, nonce, data[s.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.decoder.DecodeAll(compressed, nil)
+}
+
+func (s *blockStore) putChunk(ctx context.Context, id string, data []byte) (int64, error) {
+	sealed, err := s.seal(data)
+	if err != nil {
+		return 0, err
+	}
+	err = s.put(ctx, s.chunkKey(id), sealed)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(sealed)), nil
+}
+
+func (s *blockStore) getChunk(ctx context.Context, id string) ([]byte, error) {
+	key := s.chunkKey(id)
+	url, err := s.getChunkUrl(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt chunk %s: %w", id, err)
+	}
+	if s.chunkId(data) != id {
+		return nil, fmt.Errorf("chunk %s is corrupted", id)
+	}
+	return data, nil
+}
+
+// getChunkUrl returns the presigned url of a chunk. Restores fetch many chunks, so the presigned urls of whole chunk
+// directories are cached until shortly before they expire.
+func (s *blockStore) getChunkUrl(ctx context.Context, key string) (string, error) {
+	dir := path.Dir(key) + "/"
+
+	s.m.Lock()
+	d, ok := s.dirCache[dir]
+	s.m.Unlock()
+
+	if !ok || time.Now().Add(5*time.Minute).After(d.expires) {
+		objects, err := s.list(ctx, dir)
+		if err != nil {
+			return "", err
+		}
+		d = &blockStoreDir{
+			urls:    map[string]string{},
+			expires: time.Now().Add(time.Hour),
+		}
+		for _, o := range objects {
+			d.urls[o.Key] = o.PresignedGetUrl
+			if o.PresignedGetUrlExpires.Before(d.expires) {
+				d.expires = o.PresignedGetUrlExpires
+			}
+		}
+		s.m.Lock()
+		s.dirCache[dir] = d
+		s.m.Unlock()
+	}
+
+	url, ok := d.urls[key]
+	if !ok {
+		return "", fmt.Errorf("object %s not found", key)
+	}
+	return url, nil
+}
+
+func (s *blockStore) putManifest(ctx context.Context, m *BlockManifest) error {
+	b, err := m.marshal()
+	if err != nil {
+		return err
+	}
+	sealed, err := s.seal(b)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, s.manifestKey(m.ID), sealed)
+}
+
+func (s *blockStore) getManifest(ctx context.Context, id string) (*BlockManifest, error) {
+	key := s.manifestKey(id)
+	objects, err := s.list(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		if o.Key != key {
+			continue
+		}
+		sealed, err := s.get(ctx, o.PresignedGetUrl)
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.open(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt manifest %s: %w", id, err)
+		}
+		return unmarshalBlockManifest(b)
+	}
+	return nil, fmt.Errorf("manifest %s not found", id)
+}
+
+func (s *blockStore) listManifestIds(ctx context.Context) ([]string, error) {
+	dir := path.Join(s.prefix, "manifests") + "/"
+	objects, err := s.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, "/") {
+			continue
+		}
+		ret = append(ret, strings.TrimPrefix(o.Key, dir))
+	}
+	return ret, nil
+}
+
+func (s *blockStore) deleteManifest(ctx context.Context, id string) error {
+	return s.delete(ctx, s.manifestKey(id))
+}
+
+// listChunkIds returns the ids of all stored chunks
+func (s *blockStore) listChunkIds(ctx context.Context) ([]string, error) {
+	chunksDir := path.Join(s.prefix, "chunks") + "/"
+	dirs, err := s.list(ctx, chunksDir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, d := range dirs {
+		if !strings.HasSuffix(d.Key, "/") {
+			continue
+		}
+		objects, err := s.list(ctx, d.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			if strings.HasSuffix(o.Key, "/") {
+				continue
+			}
+			ret = append(ret, path.Base(o.Key))
+		}
+	}
+	return ret, nil
+}
+
+func (s *blockStore) deleteChunk(ctx context.Context, id string) error {
+	return s.delete(ctx, s.chunkKey(id))
+}
+
+func (s *blockStore) list(ctx context.Context, prefix string) ([]models.S3ObjectInfo, error) {
+	rep, err := s.client.S3ProxyListObjects(ctx, s.repositoryId, models.S3ProxyListObjectsRequest{
+		Prefix: prefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rep.Objects, nil
+}
+
+func (s *blockStore) delete(ctx context.Context, key string) error {
+	_, err := s.client.S3ProxyDeleteObject(ctx, s.repositoryId, models.S3ProxyDeleteObjectRequest{
+		Key: key,
+	})
+	return err
+}
+
+func (s *blockStore) put(ctx context.Context, key string, data []byte) error {
+	presigned, err := s.client.S3ProxyPresignPut(ctx, s.repositoryId, models.S3ProxyPresignPutRequest{
+		Key: key,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", presigned.PresignedUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("upload of %s failed with status %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (s *blockStore) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,87 @@
+package volume_backup
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// TagLegacySnapshots adds the volume tags to snapshots that were taken before backups were tagged with the volume
+// identity. These snapshots are matched the way rustic grouped them back then, by hostname and backup path. Several
+// volumes served on the same host with the same snapshot mount can't be told apart this way, so the snapshots are
+// only tagged when tag is true, which the user must explicitly opt in to. It returns the number of untagged snapshots
+// that are left.
+func (vb *VolumeBackup) TagLegacySnapshots(ctx context.Context, tag bool) (int, error) {
+	rustic, err := vb.startRustic(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer rustic.Stop()
+
+	exists, err := rustic.RepositoryExists(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var groups []RusticSnapshotGroup
+	err = rustic.RunJson(&groups, "snapshots", "--json")
+	if err != nil {
+		return 0, err
+	}
+
+	var matched []string
+	unmatched := 0
+	for _, g := range groups {
+		for _, s := range g.Snapshots {
+			if !isLegacySnapshot(s) {
+				continue
+			}
+			if vb.isLegacySnapshotOfVolume(s) {
+				matched = append(matched, s.ID)
+			} else {
+				unmatched++
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return unmatched, nil
+	}
+	if !tag {
+		slog.WarnContext(ctx, "found untagged snapshots from an older version with the hostname and path of this volume, "+
+			"they are only claimed for this volume when explicitly requested",
+			slog.Any("snapshotIds", matched))
+		return unmatched + len(matched), nil
+	}
+
+	slog.InfoContext(ctx, "tagging legacy snapshots with the volume identity", slog.Any("snapshotIds", matched))
+	args := []string{"tag", "--add", strings.Join(vb.buildTags(), ",")}
+	args = append(args, matched...)
+	err = rustic.Run(args...)
+	if err != nil {
+		return 0, err
+	}
+	return unmatched, nil
+}
+
+func isLegacySnapshot(s RusticSnapshot) bool {
+	for _, t := range s.Tags {
+		if strings.HasPrefix(t, volumeUuidTagPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (vb *VolumeBackup) isLegacySnapshotOfVolume(s RusticSnapshot) bool {
+	if vb.Hostname == "" || vb.SnapshotMount == "" {
+		return false
+	}
+	if s.Hostname != vb.Hostname || len(s.Paths) != 1 {
+		return false
+	}
+	return filepath.Clean(s.Paths[0]) == filepath.Clean(vb.SnapshotMount)
+}
@@ -0,0 +1,39 @@
+package volume_backup
+
+import "testing"
+
+func TestIsLegacySnapshotOfVolume(t *testing.T) {
+	vb := &VolumeBackup{
+		VolumeUuid:    "uuid-1",
+		Hostname:      "host-1",
+		SnapshotMount: "/mnt/snapshot",
+	}
+
+	tests := []struct {
+		name     string
+		snapshot RusticSnapshot
+		legacy   bool
+		matches  bool
+	}{
+		{"tagged", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/snapshot"}, Tags: []string{volumeUuidTagPrefix + "uuid-1"}}, false, true},
+		{"tagged other volume", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/snapshot"}, Tags: []string{volumeUuidTagPrefix + "uuid-2"}}, false, true},
+		{"untagged same host and path", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/snapshot/"}}, true, true},
+		{"untagged other host", RusticSnapshot{Hostname: "host-2", Paths: []string{"/mnt/snapshot"}}, true, false},
+		{"untagged other path", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/other"}}, true, false},
+		{"untagged multiple paths", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/snapshot", "/mnt/other"}}, true, false},
+		{"unrelated tags", RusticSnapshot{Hostname: "host-1", Paths: []string{"/mnt/snapshot"}, Tags: []string{"foo"}}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLegacySnapshot(tt.snapshot); got != tt.legacy {
+				t.Fatalf("isLegacySnapshot = %v, want %v", got, tt.legacy)
+			}
+			if !tt.legacy {
+				return
+			}
+			if got := vb.isLegacySnapshotOfVolume(tt.snapshot); got != tt.matches {
+				t.Fatalf("isLegacySnapshotOfVolume = %v, want %v", got, tt.matches)
+			}
+		})
+	}
+}
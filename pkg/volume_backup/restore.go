@@ -0,0 +1,133 @@
+package volume_backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+func (vb *VolumeBackup) ListSnapshots(ctx context.Context) ([]RusticSnapshot, error) {
+	rustic, err := vb.startRustic(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rustic.Stop()
+
+	exists, err := rustic.RepositoryExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	var groups []RusticSnapshotGroup
+	args := vb.buildSnapshotFilterArgs()
+	args = append(args, "snapshots", "--json")
+	err = rustic.RunJson(&groups, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []RusticSnapshot
+	for _, g := range groups {
+		ret = append(ret, g.Snapshots...)
+	}
+	return ret, nil
+}
+
+// GetLatestSnapshot returns the newest snapshot in the repository or nil if there are no snapshots yet
+func (vb *VolumeBackup) GetLatestSnapshot(ctx context.Context) (*RusticSnapshot, error) {
+	snapshots, err := vb.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *RusticSnapshot
+	for _, s := range snapshots {
+		if latest == nil || s.Time.After(latest.Time) {
+			latest = &s
+		}
+	}
+	return latest, nil
+}
+
+// FindSnapshot finds a snapshot by its full ID or by an unambiguous ID prefix
+func (vb *VolumeBackup) FindSnapshot(ctx context.Context, id string) (*RusticSnapshot, error) {
+	snapshots, err := vb.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *RusticSnapshot
+	for _, s := range snapshots {
+		if s.ID == id {
+			return &s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("snapshot id %s is ambiguous", id)
+			}
+			found = &s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("snapshot %s not found", id)
+	}
+	return found, nil
+}
+
+// FindSnapshotAt finds the newest snapshot that was taken at or before the given time
+func (vb *VolumeBackup) FindSnapshotAt(ctx context.Context, t time.Time) (*RusticSnapshot, error) {
+	snapshots, err := vb.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *RusticSnapshot
+	for _, s := range snapshots {
+		if s.Time.After(t) {
+			continue
+		}
+		if found == nil || s.Time.After(found.Time) {
+			found = &s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no snapshot found at or before %s", t.Format(time.RFC3339))
+	}
+	return found, nil
+}
+
+func (vb *VolumeBackup) Restore(ctx context.Context, snapshot *RusticSnapshot, target string) error {
+	if !slices.Contains(snapshot.Tags, volumeUuidTagPrefix+vb.VolumeUuid) {
+		return fmt.Errorf("snapshot %s does not belong to volume %s", snapshot.ID, vb.VolumeUuid)
+	}
+	if len(snapshot.Paths) != 1 {
+		return fmt.Errorf("snapshot %s has unexpected paths %v", snapshot.ID, snapshot.Paths)
+	}
+
+	rustic, err := vb.startRustic(ctx)
+	if err != nil {
+		return err
+	}
+	defer rustic.Stop()
+
+	slog.InfoContext(ctx, "restoring snapshot",
+		slog.Any("snapshotId", snapshot.ID),
+		slog.Any("snapshotTime", snapshot.Time),
+		slog.Any("target", target),
+	)
+
+	// backups are taken from the snapshot mount, so we only restore the content below that path
+	args := vb.buildSnapshotFilterArgs()
+	args = append(args, "restore", fmt.Sprintf("%s:%s", snapshot.ID, snapshot.Paths[0]), target)
+	err = rustic.Run(args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package volume_backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+)
+
+func (vb *VolumeBackup) Forget(ctx context.Context, retention models.VolumeRetention) error {
+	if retention.IsEmpty() {
+		return fmt.Errorf("refusing to forget snapshots without a retention policy")
+	}
+
+	rustic, err := vb.startRustic(ctx)
+	if err != nil {
+		return err
+	}
+	defer rustic.Stop()
+
+	exists, err := rustic.RepositoryExists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	// hostnames change when a volume is moved to another host, so we group by the volume tags only
+	args := vb.buildSnapshotFilterArgs()
+	args = append(args, "forget", "--group-by", "tags")
+	addKeep := func(flag string, v *int64) {
+		if v != nil {
+			args = append(args, flag, fmt.Sprintf("%d", *v))
+		}
+	}
+	addKeep("--keep-last", retention.KeepLast)
+	addKeep("--keep-hourly", retention.KeepHourly)
+	addKeep("--keep-daily", retention.KeepDaily)
+	addKeep("--keep-weekly", retention.KeepWeekly)
+	addKeep("--keep-monthly", retention.KeepMonthly)
+
+	return rustic.Run(args...)
+}
+
+// Prune removes all data that is not referenced by any snapshot anymore. This affects the whole repository and
+// not only the snapshots of this volume, so the caller must ensure that no other host prunes at the same time.
+func (vb *VolumeBackup) Prune(ctx context.Context) error {
+	rustic, err := vb.startRustic(ctx)
+	if err != nil {
+		return err
+	}
+	defer rustic.Stop()
+
+	exists, err := rustic.RepositoryExists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	return rustic.Run("prune")
+}
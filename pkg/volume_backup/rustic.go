@@ -0,0 +1,74 @@
+package volume_backup
+
+import (
+	"context"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+	"github.com/dboxed/dboxed-volume/pkg/webdavproxy"
+)
+
+type rusticRunner struct {
+	fs          *webdavproxy.FileSystem
+	webdavProxy *webdavproxy.Proxy
+	configDir   string
+}
+
+func (vb *VolumeBackup) startRustic(ctx context.Context) (*rusticRunner, error) {
+	r := &rusticRunner{}
+
+	r.fs = webdavproxy.NewFileSystem(ctx, vb.Client, vb.RepositoryId)
+
+	var err error
+	r.webdavProxy, err = webdavproxy.NewProxy(r.fs, vb.WebdavProxyListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	wdpAddr, err := r.webdavProxy.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.configDir, err = vb.buildRusticConfigDir(wdpAddr.String())
+	if err != nil {
+		_ = r.webdavProxy.Stop()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rusticRunner) Stop() {
+	_ = os.RemoveAll(r.configDir)
+	_ = r.webdavProxy.Stop()
+}
+
+// RepositoryExists checks for the rustic config file in the repository, which is only written on the first backup
+func (r *rusticRunner) RepositoryExists(ctx context.Context) (bool, error) {
+	_, err := r.fs.Stat(ctx, "config")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *rusticRunner) Run(args ...string) error {
+	c := util.CommandHelper{
+		Command: "rustic",
+		Args:    args,
+		Dir:     r.configDir,
+	}
+	return c.Run()
+}
+
+func (r *rusticRunner) RunJson(ret any, args ...string) error {
+	c := util.CommandHelper{
+		Command: "rustic",
+		Args:    args,
+		Dir:     r.configDir,
+	}
+	return c.RunStdoutJson(ret)
+}
@@ -0,0 +1,66 @@
+package volume_backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type RusticSnapshot struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Label    string    `json:"label,omitempty"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags,omitempty"`
+
+	Summary *RusticSnapshotSummary `json:"summary,omitempty"`
+}
+
+type RusticSnapshotSummary struct {
+	FilesNew            int64 `json:"files_new"`
+	FilesChanged        int64 `json:"files_changed"`
+	FilesUnmodified     int64 `json:"files_unmodified"`
+	TotalFilesProcessed int64 `json:"total_files_processed"`
+	TotalBytesProcessed int64 `json:"total_bytes_processed"`
+	DirsNew             int64 `json:"dirs_new"`
+	DirsChanged         int64 `json:"dirs_changed"`
+	DirsUnmodified      int64 `json:"dirs_unmodified"`
+	DataAdded           int64 `json:"data_added"`
+	DataAddedPacked     int64 `json:"data_added_packed"`
+
+	BackupDuration float64 `json:"backup_duration"`
+	TotalDuration  float64 `json:"total_duration"`
+}
+
+type RusticSnapshotGroupKey struct {
+	Hostname *string  `json:"hostname,omitempty"`
+	Label    *string  `json:"label,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+}
+
+// RusticSnapshotGroup is a single entry of `rustic snapshots --json`, which is serialized as a [group, snapshots] tuple
+type RusticSnapshotGroup struct {
+	Group     RusticSnapshotGroupKey
+	Snapshots []RusticSnapshot
+}
+
+func (g *RusticSnapshotGroup) UnmarshalJSON(b []byte) error {
+	var tuple []json.RawMessage
+	err := json.Unmarshal(b, &tuple)
+	if err != nil {
+		return err
+	}
+	if len(tuple) != 2 {
+		return fmt.Errorf("unexpected snapshot group tuple length %d", len(tuple))
+	}
+	err = json.Unmarshal(tuple[0], &g.Group)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(tuple[1], &g.Snapshots)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package volume_backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/util"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+const DefaultSnapshotHookTimeout = 60 * time.Second
+
+const (
+	snapshotPhasePre  = "pre-snapshot"
+	snapshotPhasePost = "post-snapshot"
+)
+
+// SnapshotHooks describes what happens around the creation of a backup snapshot. Hooks are either shell commands or
+// http(s) URLs, which receive a POST request.
+type SnapshotHooks struct {
+	Freeze      bool
+	MountTarget string
+
+	PreSnapshot  string
+	PostSnapshot string
+	Timeout      time.Duration
+}
+
+// SnapshotHooksResult is reported together with the backup. Hook failures don't fail the backup, as a crash-consistent
+// backup is still better than no backup at all.
+type SnapshotHooksResult struct {
+	Frozen            bool
+	PreSnapshotError  error
+	PostSnapshotError error
+}
+
+type snapshotHookRequest struct {
+	Phase      string `json:"phase"`
+	VolumeUuid string `json:"volumeUuid"`
+	VolumeName string `json:"volumeName"`
+	Snapshot   string `json:"snapshot"`
+}
+
+func (vb *VolumeBackup) createSnapshot(ctx context.Context, v volume.VolumeBackend, snapshotName string, overwrite bool) (*SnapshotHooksResult, error) {
+	result := &SnapshotHooksResult{}
+	hooks := vb.SnapshotHooks
+	if hooks == nil {
+		hooks = &SnapshotHooks{}
+	}
+
+	if hooks.PreSnapshot != "" {
+		result.PreSnapshotError = vb.runSnapshotHook(ctx, hooks, snapshotPhasePre, hooks.PreSnapshot, snapshotName)
+		if result.PreSnapshotError != nil {
+			slog.ErrorContext(ctx, "pre-snapshot hook failed", slog.Any("error", result.PreSnapshotError))
+		}
+	}
+
+	_ = util.RunCommand("sync")
+
+	err := vb.createSnapshotFrozen(ctx, v, hooks, snapshotName, overwrite, result)
+
+	// the post-snapshot hook must run even if the snapshot failed, as the application would otherwise stay quiesced
+	if hooks.PostSnapshot != "" {
+		result.PostSnapshotError = vb.runSnapshotHook(ctx, hooks, snapshotPhasePost, hooks.PostSnapshot, snapshotName)
+		if result.PostSnapshotError != nil {
+			slog.ErrorContext(ctx, "post-snapshot hook failed", slog.Any("error", result.PostSnapshotError))
+		}
+	}
+	if err != nil {
+		return nil, errors.Join(err, result.PostSnapshotError)
+	}
+	return result, nil
+}
+
+func (vb *VolumeBackup) createSnapshotFrozen(ctx context.Context, v volume.VolumeBackend, hooks *SnapshotHooks, snapshotName string, overwrite bool, result *SnapshotHooksResult) error {
+	if hooks.Freeze && hooks.MountTarget != "" {
+		err := v.Freeze(hooks.MountTarget)
+		if err != nil {
+			slog.WarnContext(ctx, "freezing filesystem failed, continuing with a crash-consistent snapshot", slog.Any("error", err))
+		} else {
+			result.Frozen = true
+			defer func() {
+				err := v.Thaw(hooks.MountTarget)
+				if err != nil {
+					slog.ErrorContext(ctx, "thawing filesystem failed", slog.Any("error", err))
+				}
+			}()
+		}
+	}
+
+	return v.CreateSnapshot(snapshotName, overwrite)
+}
+
+func (vb *VolumeBackup) runSnapshotHook(ctx context.Context, hooks *SnapshotHooks, phase string, hook string, snapshotName string) error {
+	timeout := hooks.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSnapshotHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slog.InfoContext(ctx, "running snapshot hook", slog.Any("phase", phase), slog.Any("hook", hook))
+
+	var err error
+	if isHttpHook(hook) {
+		err = vb.runHttpSnapshotHook(ctx, phase, hook, snapshotName)
+	} else {
+		err = vb.runCommandSnapshotHook(ctx, phase, hook, snapshotName)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", phase, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %w", phase, err)
+	}
+	return nil
+}
+
+func (vb *VolumeBackup) runCommandSnapshotHook(ctx context.Context, phase string, hook string, snapshotName string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Env = append(os.Environ(),
+		"DBOXED_VOLUME_UUID="+vb.VolumeUuid,
+		"DBOXED_VOLUME_NAME="+vb.VolumeName,
+		"DBOXED_SNAPSHOT_PHASE="+phase,
+		"DBOXED_SNAPSHOT_NAME="+snapshotName,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (vb *VolumeBackup) runHttpSnapshotHook(ctx context.Context, phase string, hook string, snapshotName string) error {
+	b, err := json.Marshal(snapshotHookRequest{
+		Phase:      phase,
+		VolumeUuid: vb.VolumeUuid,
+		VolumeName: vb.VolumeName,
+		Snapshot:   snapshotName,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", hook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func isHttpHook(hook string) bool {
+	return strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://")
+}
+
+// IsCommandHook returns true if the hook is executed as a local shell command
+func IsCommandHook(hook string) bool {
+	return hook != "" && !isHttpHook(hook)
+}
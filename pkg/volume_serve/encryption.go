@@ -0,0 +1,60 @@
+package volume_serve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+// LoadEncryptionKey returns the key used to unlock the local volume, or nil if the volume is not encrypted.
+// Server-side keys are fetched from the server, local keys are read from keyFile. Without a lockId, fetching a
+// server-side key requires the owner role. With the lockId of a lock held by the caller, the operator role is enough.
+func LoadEncryptionKey(ctx context.Context, c *client.Client, v *models.Volume, keyFile *string, lockId *string) ([]byte, error) {
+	switch v.Encryption {
+	case "", volume.EncryptionNone:
+		if keyFile != nil {
+			return nil, fmt.Errorf("volume is not encrypted, but an encryption key file was specified")
+		}
+		return nil, nil
+	case volume.EncryptionServerKey:
+		if keyFile != nil {
+			return nil, fmt.Errorf("volume uses a server-side encryption key, an encryption key file can't be used")
+		}
+		var k *models.VolumeEncryptionKey
+		var err error
+		if lockId != nil {
+			k, err = c.GetVolumeEncryptionKeyWithLock(ctx, v.RepositoryID, v.ID, *lockId)
+		} else {
+			k, err = c.GetVolumeEncryptionKey(ctx, v.RepositoryID, v.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []byte(k.Key), nil
+	case volume.EncryptionLocalKey:
+		if keyFile == nil {
+			return nil, fmt.Errorf("volume uses a local encryption key, an encryption key file must be specified")
+		}
+		return ReadEncryptionKeyFile(*keyFile)
+	default:
+		return nil, fmt.Errorf("unsupported encryption %s", v.Encryption)
+	}
+}
+
+// ReadEncryptionKeyFile reads a local encryption key. This also works without API access, e.g. for offline checks.
+func ReadEncryptionKeyFile(keyFile string) ([]byte, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key = bytes.TrimRight(key, "\n")
+	if len(key) == 0 {
+		return nil, fmt.Errorf("encryption key file %s is empty", keyFile)
+	}
+	return key, nil
+}
@@ -0,0 +1,165 @@
+package volume_serve
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type FenceMode string
+
+const (
+	FenceModeNone     FenceMode = "none"
+	FenceModeReadOnly FenceMode = "read-only"
+	FenceModeFreeze   FenceMode = "freeze"
+)
+
+// defaultLockTtl is used until the server returned the lock TTL of the volume, so that the intervals derived from it
+// never become 0
+const defaultLockTtl = 5 * time.Minute
+
+// lockRefreshInterval and all other lock related intervals are derived from the lock TTL of the volume. With the
+// default TTL of 5m, this results in a refresh every 15s, a fence check every 5s and fencing 1m before the server
+// considers the lock expired.
+func (vs *VolumeServe) lockRefreshInterval() time.Duration {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+	return vs.getLockTtl() / 20
+}
+
+func (vs *VolumeServe) fenceCheckInterval() time.Duration {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+	return vs.getLockTtl() / 60
+}
+
+// getLockTtl must be called with fenceMutex held
+func (vs *VolumeServe) getLockTtl() time.Duration {
+	if vs.lockTtl <= 0 {
+		return defaultLockTtl
+	}
+	return vs.lockTtl
+}
+
+func (vs *VolumeServe) onLockRefreshed(lockId string, lockTtl time.Duration) {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+
+	vs.lastLockRefresh = time.Now()
+	vs.lockId = lockId
+	if vs.lockTtl != 0 && lockTtl != vs.lockTtl {
+		vs.log.Info("lock ttl changed", slog.Any("lockTtl", lockTtl))
+	}
+	vs.lockTtl = lockTtl
+
+	if !vs.fenced {
+		return
+	}
+	if vs.lockLost {
+		vs.log.Error("lock was lost before, volume stays fenced", slog.Any("lockId", lockId))
+		return
+	}
+	if lockId != vs.fencedLockId {
+		// somebody else might have owned the volume in-between, so we can't safely continue writing to it
+		vs.log.Error("lock was re-acquired with a new lock id, volume stays fenced",
+			slog.Any("fencedLockId", vs.fencedLockId),
+			slog.Any("lockId", lockId),
+		)
+		return
+	}
+
+	vs.log.Info("lock refreshed with the original lock id, unfencing volume", slog.Any("lockId", lockId))
+	err := vs.unfence()
+	if err != nil {
+		vs.log.Error("unfencing volume failed", slog.Any("error", err))
+		return
+	}
+	vs.fenced = false
+}
+
+// onLockLost is called when the server rejected the refresh of our lock, e.g. because it was force-unlocked and
+// maybe already taken by another host. The volume is fenced immediately and never unfenced again.
+func (vs *VolumeServe) onLockLost(lockId string) {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+
+	if !vs.lockLost {
+		vs.log.Error("lock is not ours anymore", slog.Any("lockId", lockId))
+	}
+	vs.lockLost = true
+	vs.fenceLocked()
+}
+
+func (vs *VolumeServe) isFenced() bool {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+	return vs.fenced
+}
+
+func (vs *VolumeServe) periodicCheckFence(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(vs.fenceCheckInterval()):
+			vs.checkFence()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (vs *VolumeServe) checkFence() {
+	vs.fenceMutex.Lock()
+	defer vs.fenceMutex.Unlock()
+
+	if vs.fenced {
+		return
+	}
+	// fence when only a fifth of the TTL is left until the server considers the lock expired
+	lockTtl := vs.getLockTtl()
+	fenceAfter := lockTtl - lockTtl/5
+	sinceRefresh := time.Since(vs.lastLockRefresh)
+	if sinceRefresh < fenceAfter && !vs.lockLost {
+		return
+	}
+
+	vs.log.Error("lock could not be refreshed in time, fencing volume",
+		slog.Any("lastLockRefresh", vs.lastLockRefresh),
+		slog.Any("fenceMode", vs.FenceMode),
+	)
+	vs.fenceLocked()
+}
+
+// fenceLocked must be called with fenceMutex held
+func (vs *VolumeServe) fenceLocked() {
+	if vs.fenced {
+		return
+	}
+	err := vs.fence()
+	if err != nil {
+		// we'll retry on the next check
+		vs.log.Error("fencing volume failed", slog.Any("error", err))
+		return
+	}
+	vs.fenced = true
+	vs.fencedLockId = vs.lockId
+}
+
+func (vs *VolumeServe) fence() error {
+	switch vs.FenceMode {
+	case FenceModeReadOnly:
+		return vs.localVolume.Remount(vs.Mount, true)
+	case FenceModeFreeze:
+		return vs.localVolume.Freeze(vs.Mount)
+	}
+	return nil
+}
+
+func (vs *VolumeServe) unfence() error {
+	switch vs.FenceMode {
+	case FenceModeReadOnly:
+		return vs.localVolume.Remount(vs.Mount, false)
+	case FenceModeFreeze:
+		return vs.localVolume.Thaw(vs.Mount)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package volume_serve
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/client"
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+type fakeBackend struct {
+	volume.VolumeBackend
+
+	m        sync.Mutex
+	readOnly bool
+}
+
+func (b *fakeBackend) Remount(mountTarget string, readOnly bool) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.readOnly = readOnly
+	return nil
+}
+
+func (b *fakeBackend) isReadOnly() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.readOnly
+}
+
+func TestFenceOnFailedLockRefresh(t *testing.T) {
+	var m sync.Mutex
+	lockCalls := 0
+	var prevLockIds []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.VolumeLockRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		m.Lock()
+		defer m.Unlock()
+		lockCalls++
+		if req.PrevLockId != nil {
+			prevLockIds = append(prevLockIds, *req.PrevLockId)
+		}
+		if lockCalls > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		lockId := "lock-1"
+		_ = json.NewEncoder(w).Encode(models.Volume{
+			ID:      1,
+			LockId:  &lockId,
+			LockTtl: 1,
+		})
+	}))
+	defer srv.Close()
+
+	token := "test"
+	c, err := client.New(srv.URL, &token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	vs := &VolumeServe{
+		Client:       c,
+		RepositoryId: 1,
+		VolumeId:     1,
+		FenceMode:    FenceModeReadOnly,
+		log:          slog.Default(),
+		localVolume:  backend,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = vs.lockVolume(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vs.goRoutine(func() {
+		vs.periodicRefreshLock(ctx)
+	})
+	vs.goRoutine(func() {
+		vs.periodicCheckFence(ctx)
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !vs.isFenced() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	vs.wg.Wait()
+
+	if !vs.isFenced() {
+		t.Fatal("volume was not fenced after the lock refresh failed")
+	}
+	if !backend.isReadOnly() {
+		t.Fatal("volume was not remounted read-only")
+	}
+
+	v := vs.getVolume()
+	if v == nil || v.LockId == nil || *v.LockId != "lock-1" {
+		t.Fatalf("last good volume was not kept: %v", v)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if lockCalls < 3 {
+		t.Fatalf("expected the refresh to be retried, got %d lock calls", lockCalls)
+	}
+	for _, id := range prevLockIds {
+		if id != "lock-1" {
+			t.Fatalf("refresh was retried with lock id %q instead of the last good one", id)
+		}
+	}
+}
+
+func TestFenceOnLostLock(t *testing.T) {
+	var m sync.Mutex
+	lockCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		lockCalls++
+		if lockCalls > 1 {
+			// the lock was force-unlocked and taken by somebody else
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		lockId := "lock-1"
+		_ = json.NewEncoder(w).Encode(models.Volume{
+			ID:      1,
+			LockId:  &lockId,
+			LockTtl: 300,
+		})
+	}))
+	defer srv.Close()
+
+	token := "test"
+	c, err := client.New(srv.URL, &token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{}
+	vs := &VolumeServe{
+		Client:       c,
+		RepositoryId: 1,
+		VolumeId:     1,
+		FenceMode:    FenceModeReadOnly,
+		log:          slog.Default(),
+		localVolume:  backend,
+	}
+
+	ctx := context.Background()
+	err = vs.lockVolume(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vs.lockVolume(ctx, vs.getVolume().LockId)
+	if !client.IsHttpStatus(err, http.StatusConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	// no need to wait for the TTL, the lock is known to be lost
+	if !vs.isFenced() || !backend.isReadOnly() {
+		t.Fatal("volume was not fenced immediately after the lock was lost")
+	}
+
+	vs.onLockRefreshed("lock-1", 300*time.Second)
+	if !vs.isFenced() || !backend.isReadOnly() {
+		t.Fatal("volume was unfenced after the lock was lost")
+	}
+}
+
+func TestLockIntervalsWithoutTtl(t *testing.T) {
+	vs := &VolumeServe{}
+	if vs.lockRefreshInterval() != defaultLockTtl/20 || vs.fenceCheckInterval() != defaultLockTtl/60 {
+		t.Fatal("intervals must be derived from the default lock ttl when the lock ttl is unknown")
+	}
+
+	vs.onLockRefreshed("lock-1", 0)
+	if vs.lockRefreshInterval() <= 0 || vs.fenceCheckInterval() <= 0 {
+		t.Fatal("intervals must not be 0 when the server returned no lock ttl")
+	}
+}
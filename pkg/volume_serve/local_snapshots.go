@@ -0,0 +1,78 @@
+package volume_serve
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/volume"
+)
+
+func (vs *VolumeServe) periodicLocalSnapshot(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(vs.LocalSnapshotInterval):
+			if vs.isFenced() {
+				vs.log.Warn("volume is fenced, skipping local snapshot")
+				continue
+			}
+			err := vs.doLocalSnapshot()
+			if err != nil {
+				vs.log.Error("local snapshot failed", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (vs *VolumeServe) doLocalSnapshot() error {
+	vs.localMutex.Lock()
+	defer vs.localMutex.Unlock()
+
+	err := vs.checkThinPoolForSnapshot()
+	if err != nil {
+		return err
+	}
+
+	name := volume.BuildAutoSnapshotName(time.Now())
+	vs.log.Info("creating local snapshot", slog.Any("name", name))
+	err = volume.CreateNamedSnapshot(vs.localVolume, name)
+	if err != nil {
+		return err
+	}
+
+	return vs.applyLocalSnapshotRetention()
+}
+
+// applyLocalSnapshotRetention deletes all scheduled snapshots except the newest LocalSnapshotKeep ones. Manually
+// created snapshots are never deleted.
+func (vs *VolumeServe) applyLocalSnapshotRetention() error {
+	if vs.LocalSnapshotKeep <= 0 {
+		return nil
+	}
+
+	snapshots, err := volume.ListNamedSnapshots(vs.localVolume)
+	if err != nil {
+		return err
+	}
+	var auto []volume.SnapshotInfo
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.Name, volume.AutoSnapshotPrefix) {
+			auto = append(auto, s)
+		}
+	}
+	if len(auto) <= vs.LocalSnapshotKeep {
+		return nil
+	}
+
+	for _, s := range auto[:len(auto)-vs.LocalSnapshotKeep] {
+		vs.log.Info("deleting old local snapshot", slog.Any("name", s.Name))
+		err = volume.DeleteNamedSnapshot(vs.localVolume, s.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package volume_serve
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+func (vs *VolumeServe) periodicCheckResize(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(vs.lockRefreshInterval()):
+			err := vs.checkResize()
+			if err != nil {
+				vs.log.Error("resizing volume failed", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkResize grows the local volume when the fsSize of the volume got increased on the server. The volume model is
+// updated on every lock refresh.
+func (vs *VolumeServe) checkResize() error {
+	if vs.isFenced() {
+		return nil
+	}
+
+	fsSize := vs.getVolume().FsSize
+	size, err := vs.localVolume.GetSize()
+	if err != nil {
+		return err
+	}
+	if size >= fsSize {
+		return nil
+	}
+	return vs.grow(fsSize)
+}
+
+func (vs *VolumeServe) grow(fsSize int64) error {
+	vs.localMutex.Lock()
+	defer vs.localMutex.Unlock()
+
+	vs.log.Info("growing volume",
+		slog.Any("fsSize", humanize.Bytes(uint64(fsSize))),
+	)
+	return vs.localVolume.Grow(fsSize, vs.Mount)
+}
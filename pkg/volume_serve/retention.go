@@ -0,0 +1,178 @@
+package volume_serve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/volume_backup"
+)
+
+// the server considers a prune lock stale after 10 minutes
+const pruneLockRefreshInterval = time.Minute
+
+func (vs *VolumeServe) periodicForget(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(vs.ForgetInterval):
+			if vs.isFenced() {
+				vs.log.Warn("volume is fenced, skipping forget")
+				continue
+			}
+			err := vs.forget(ctx)
+			if err != nil {
+				vs.log.Error("forget failed", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (vs *VolumeServe) forget(ctx context.Context) error {
+	retention := vs.getVolume().Retention
+	if retention.IsEmpty() {
+		return nil
+	}
+
+	vs.log.Info("forgetting old snapshots")
+	var err error
+	if vs.BackupMode == volume_backup.BackupModeBlocks {
+		err = vs.backup.ForgetBlocks(ctx, retention)
+	} else {
+		err = vs.backup.Forget(ctx, retention)
+	}
+	if err != nil {
+		return err
+	}
+
+	return vs.syncBackupCatalog(ctx)
+}
+
+// syncBackupCatalog removes backups from the server side catalog which got forgotten
+func (vs *VolumeServe) syncBackupCatalog(ctx context.Context) error {
+	snapshotIds := map[string]struct{}{}
+	if vs.BackupMode == volume_backup.BackupModeBlocks {
+		manifests, err := vs.backup.ListBlockManifests(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range manifests {
+			snapshotIds[m.ID] = struct{}{}
+		}
+	} else {
+		snapshots, err := vs.backup.ListSnapshots(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			snapshotIds[s.ID] = struct{}{}
+		}
+	}
+
+	backups, err := vs.Client.ListVolumeBackups(ctx, vs.RepositoryId, vs.VolumeId)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		if _, ok := snapshotIds[b.SnapshotId]; ok {
+			continue
+		}
+		vs.log.Info("removing forgotten backup from catalog", slog.Any("snapshotId", b.SnapshotId))
+		err = vs.Client.DeleteVolumeBackup(ctx, vs.RepositoryId, vs.VolumeId, b.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vs *VolumeServe) periodicPrune(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(vs.PruneInterval):
+			var err error
+			if vs.BackupMode == volume_backup.BackupModeBlocks {
+				err = vs.pruneBlocks(ctx)
+			} else {
+				err = vs.pruneWithLock(ctx)
+			}
+			if err != nil {
+				vs.log.Error("prune failed", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (vs *VolumeServe) pruneWithLock(ctx context.Context) error {
+	if vs.isFenced() {
+		vs.log.Warn("volume is fenced, skipping prune")
+		return nil
+	}
+
+	lock, err := vs.Client.RepositoryPruneLock(ctx, vs.RepositoryId, models.RepositoryPruneLockRequest{})
+	if err != nil {
+		return err
+	}
+	vs.log.Info("repository locked for prune", slog.Any("pruneLockId", lock.LockId))
+
+	// the prune is cancelled as soon as the prune lock can't be refreshed, as another host might acquire it after it
+	// expired and start pruning concurrently. rustic accesses the repository only through the webdav proxy, which
+	// stops serving requests once its context is cancelled.
+	pruneCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		for {
+			select {
+			case <-time.After(pruneLockRefreshInterval):
+				_, err := vs.Client.RepositoryPruneLock(pruneCtx, vs.RepositoryId, models.RepositoryPruneLockRequest{
+					PrevLockId: &lock.LockId,
+				})
+				if err != nil {
+					vs.log.Error("error while refreshing prune lock, cancelling prune", slog.Any("error", err))
+					cancel(fmt.Errorf("refreshing prune lock failed: %w", err))
+					return
+				}
+			case <-pruneCtx.Done():
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		err := vs.Client.RepositoryPruneUnlock(context.WithoutCancel(ctx), vs.RepositoryId, models.RepositoryPruneUnlockRequest{
+			LockId: lock.LockId,
+		})
+		if err != nil {
+			vs.log.Error("releasing prune lock failed", slog.Any("error", err))
+		}
+	}()
+
+	vs.log.Info("pruning repository")
+	err = vs.backup.Prune(pruneCtx)
+	if err != nil {
+		if cause := context.Cause(pruneCtx); cause != nil && ctx.Err() == nil {
+			return cause
+		}
+		return err
+	}
+	return nil
+}
+
+// pruneBlocks removes unreferenced chunks of block level backups. These are stored per volume, so instead of the
+// repository wide prune lock, we only need to make sure that no backup of this volume runs at the same time.
+func (vs *VolumeServe) pruneBlocks(ctx context.Context) error {
+	if vs.isFenced() {
+		vs.log.Warn("volume is fenced, skipping prune")
+		return nil
+	}
+
+	vs.localMutex.Lock()
+	defer vs.localMutex.Unlock()
+
+	return vs.backup.PruneBlocks(ctx)
+}
@@ -0,0 +1,63 @@
+package volume_serve
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/volume_backup"
+)
+
+// buildSnapshotHooks merges the hooks configured on the volume with the ones passed to volume serve. The latter take
+// precedence.
+func (vs *VolumeServe) buildSnapshotHooks() *volume_backup.SnapshotHooks {
+	cfg := vs.getVolume().SnapshotHooks
+
+	hooks := &volume_backup.SnapshotHooks{
+		Freeze:      cfg.Freeze || vs.SnapshotFreeze,
+		MountTarget: vs.Mount,
+		Timeout:     vs.SnapshotHookTimeout,
+	}
+	if hooks.Timeout == 0 && cfg.Timeout != nil {
+		hooks.Timeout = time.Duration(*cfg.Timeout) * time.Second
+	}
+
+	hooks.PreSnapshot = vs.selectSnapshotHook("pre-snapshot", vs.PreSnapshotHook, cfg.PreSnapshot)
+	hooks.PostSnapshot = vs.selectSnapshotHook("post-snapshot", vs.PostSnapshotHook, cfg.PostSnapshot)
+	return hooks
+}
+
+func (vs *VolumeServe) selectSnapshotHook(phase string, local *string, fromVolume *string) string {
+	if local != nil {
+		return *local
+	}
+	if fromVolume == nil {
+		return ""
+	}
+	// everyone with write access to the volume could otherwise execute commands on this host
+	if volume_backup.IsCommandHook(*fromVolume) && !vs.AllowVolumeHookCommands {
+		vs.log.Warn("ignoring command hook configured on the volume, pass --allow-volume-hook-commands to run it",
+			slog.Any("phase", phase),
+		)
+		return ""
+	}
+	return *fromVolume
+}
+
+func snapshotHooksResultToModel(r *volume_backup.SnapshotHooksResult) models.VolumeBackupSnapshotHooks {
+	if r == nil {
+		return models.VolumeBackupSnapshotHooks{}
+	}
+	errStr := func(err error) *string {
+		if err == nil {
+			return nil
+		}
+		s := err.Error()
+		return &s
+	}
+	return models.VolumeBackupSnapshotHooks{
+		Frozen:            r.Frozen,
+		PreSnapshotError:  errStr(r.PreSnapshotError),
+		PostSnapshotError: errStr(r.PostSnapshotError),
+	}
+}
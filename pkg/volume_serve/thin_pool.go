@@ -0,0 +1,105 @@
+package volume_serve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	thinPoolCheckInterval = time.Minute
+
+	thinPoolWarnPercent     = 80
+	thinPoolExtendPercent   = 85
+	thinPoolCriticalPercent = 95
+
+	// the image grows by a quarter of its current size on every extension of the thin pool, but never beyond
+	// maxImageSizeFactor * fsSize
+	thinPoolExtendDivisor = 4
+	maxImageSizeFactor    = 4
+)
+
+func (vs *VolumeServe) periodicCheckThinPool(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(thinPoolCheckInterval):
+			err := vs.checkThinPool()
+			if err != nil {
+				vs.log.Error("checking thin pool failed", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (vs *VolumeServe) checkThinPool() error {
+	usage, err := vs.thinPool.GetThinPoolUsage()
+	if err != nil {
+		return err
+	}
+
+	if usage.DataPercent < thinPoolWarnPercent && usage.MetadataPercent < thinPoolWarnPercent {
+		return nil
+	}
+	vs.log.Warn("thin pool is filling up",
+		slog.Any("dataPercent", usage.DataPercent),
+		slog.Any("metadataPercent", usage.MetadataPercent),
+	)
+
+	if usage.DataPercent < thinPoolExtendPercent && usage.MetadataPercent < thinPoolExtendPercent {
+		return nil
+	}
+	if vs.NoPoolAutoExtend {
+		return nil
+	}
+
+	vs.localMutex.Lock()
+	defer vs.localMutex.Unlock()
+
+	imageSize, err := vs.thinPool.GetImageSize()
+	if err != nil {
+		return err
+	}
+	maxImageSize := vs.getVolume().FsSize * maxImageSizeFactor
+	newImageSize := min(imageSize+imageSize/thinPoolExtendDivisor, maxImageSize)
+	if newImageSize <= imageSize {
+		vs.log.Error("thin pool can't be extended anymore, image has reached its maximum size",
+			slog.Any("imageSize", humanize.Bytes(uint64(imageSize))),
+		)
+		return nil
+	}
+
+	var metadataGrowth int64
+	if usage.MetadataPercent >= thinPoolExtendPercent {
+		// double the metadata size
+		metadataGrowth = usage.MetadataSize
+	}
+
+	vs.log.Info("extending thin pool",
+		slog.Any("oldImageSize", humanize.Bytes(uint64(imageSize))),
+		slog.Any("newImageSize", humanize.Bytes(uint64(newImageSize))),
+		slog.Any("metadataGrowth", humanize.Bytes(uint64(metadataGrowth))),
+	)
+	return vs.thinPool.ExtendThinPool(newImageSize, metadataGrowth)
+}
+
+// checkThinPoolForSnapshot refuses snapshots when the thin pool is critically full, as running out of space in a thin
+// pool can corrupt the filesystem
+func (vs *VolumeServe) checkThinPoolForSnapshot() error {
+	if vs.thinPool == nil {
+		return nil
+	}
+	usage, err := vs.thinPool.GetThinPoolUsage()
+	if err != nil {
+		return err
+	}
+	if usage.DataPercent >= thinPoolCriticalPercent || usage.MetadataPercent >= thinPoolCriticalPercent {
+		return fmt.Errorf("thin pool is critically full (data %.1f%%, metadata %.1f%%), refusing to create a snapshot",
+			usage.DataPercent, usage.MetadataPercent)
+	}
+	return nil
+}
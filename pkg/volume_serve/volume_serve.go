@@ -2,12 +2,18 @@ package volume_serve
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/dboxed/dboxed-common/util"
 	"github.com/dboxed/dboxed-volume/pkg/client"
 	"github.com/dboxed/dboxed-volume/pkg/server/models"
+	"github.com/dboxed/dboxed-volume/pkg/version"
 	"github.com/dboxed/dboxed-volume/pkg/volume"
 	"github.com/dboxed/dboxed-volume/pkg/volume_backup"
 	"github.com/dustin/go-humanize"
@@ -21,20 +27,67 @@ type VolumeServe struct {
 
 	PrevLockId     *string
 	UpdateLockIdCb func(newLockId string) error
+	LockLabel      *string
 
-	Image          string
-	Mount          string
-	SnapshotMount  string
-	BackupInterval time.Duration
+	Backend           string
+	BackupMode        string
+	EncryptionKeyFile *string
+	Image             string
+	Mount             string
+	SnapshotMount     string
+	BackupInterval    time.Duration
+	ForgetInterval    time.Duration
+	PruneInterval     time.Duration
+	NoRestore         bool
+	FinalBackup       bool
+	NoPoolAutoExtend  bool
+	FenceMode         FenceMode
+
+	// TagLegacySnapshots claims untagged snapshots from older versions with the same hostname and snapshot mount
+	TagLegacySnapshots bool
+
+	LocalSnapshotInterval time.Duration
+	LocalSnapshotKeep     int
+
+	SnapshotFreeze          bool
+	PreSnapshotHook         *string
+	PostSnapshotHook        *string
+	SnapshotHookTimeout     time.Duration
+	AllowVolumeHookCommands bool
 
 	WebdavProxyListen string
 
 	log *slog.Logger
+	wg  sync.WaitGroup
 
+	hostname   string
 	repository *models.Repository
-	volume     *models.Volume
 
-	localVolume *volume.Volume
+	// volumeMutex guards volume, which is replaced by the lock refresher while other goroutines read it
+	volumeMutex sync.Mutex
+	volume      *models.Volume
+
+	// imageLock tells other local processes, e.g. local gc, that the local volume is in use
+	imageLock   *os.File
+	localVolume volume.VolumeBackend
+	// thinPool is only set for backends which store data in a thin pool
+	thinPool volume.ThinPoolBackend
+	backup   *volume_backup.VolumeBackup
+	mounted  bool
+	// untaggedSnapshots is the number of snapshots from before backups were tagged with the volume identity, which
+	// were not tagged with the identity of this volume
+	untaggedSnapshots int
+
+	// localMutex serializes operations on the local volume, e.g. backups and resizing
+	localMutex sync.Mutex
+
+	fenceMutex      sync.Mutex
+	lastLockRefresh time.Time
+	lockId          string
+	lockTtl         time.Duration
+	fenced          bool
+	fencedLockId    string
+	lockLost        bool
 }
 
 func (vs *VolumeServe) Start(ctx context.Context) error {
@@ -46,6 +99,16 @@ func (vs *VolumeServe) Start(ctx context.Context) error {
 	)
 
 	var err error
+	vs.hostname, err = os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	vs.imageLock, err = volume.LockImage(vs.Image)
+	if err != nil {
+		return err
+	}
+
 	vs.repository, err = vs.Client.GetRepositoryById(ctx, vs.RepositoryId)
 	if err != nil {
 		return err
@@ -56,88 +119,385 @@ func (vs *VolumeServe) Start(ctx context.Context) error {
 		return err
 	}
 
-	go vs.periodicRefreshLock(ctx)
+	vs.goRoutine(func() {
+		vs.periodicRefreshLock(ctx)
+	})
+
+	v := vs.getVolume()
+
+	encryptionKey, err := LoadEncryptionKey(ctx, vs.Client, v, vs.EncryptionKeyFile, v.LockId)
+	if err != nil {
+		return err
+	}
 
+	restorePendingMarker := vs.Image + ".restore-pending"
 	if _, err := os.Stat(vs.Image); err != nil {
-		imageSize := vs.volume.FsSize * 2
-		vs.log.Info("creating local volume image",
+		if !vs.NoRestore {
+			// if anything goes wrong between creating the image and finishing the restore, this marker lets us
+			// retry the restore on the next start instead of silently serving a partially restored volume
+			err = os.WriteFile(restorePendingMarker, nil, 0600)
+			if err != nil {
+				return err
+			}
+		}
+
+		imageSize := v.FsSize * volume.ImageSizeFactor
+		vs.log.Info("creating local volume",
+			slog.Any("backend", vs.Backend),
 			slog.Any("path", vs.Image),
 			slog.Any("imageSize", humanize.Bytes(uint64(imageSize))),
-			slog.Any("fsSize", humanize.Bytes(uint64(vs.volume.FsSize))),
-			slog.Any("fsType", vs.volume.FsType),
+			slog.Any("fsSize", humanize.Bytes(uint64(v.FsSize))),
+			slog.Any("fsType", v.FsType),
+			slog.Any("encryption", v.Encryption),
 		)
-		err := volume.Create(volume.CreateOptions{
+		err := volume.CreateBackend(vs.Backend, volume.CreateOptions{
 			ImagePath: vs.Image,
 			ImageSize: imageSize,
-			FsSize:    vs.volume.FsSize,
-			FsType:    vs.volume.FsType,
+			FsSize:    v.FsSize,
+			FsType:    v.FsType,
+
+			EncryptionKey: encryptionKey,
 		})
 		if err != nil {
 			return err
 		}
 	}
 
-	vs.log.Info("opening local volume image",
+	vs.log.Info("opening local volume",
+		slog.Any("backend", vs.Backend),
 		slog.Any("path", vs.Image),
 	)
-	vs.localVolume, err = volume.Open(vs.Image)
+	vs.localVolume, err = volume.OpenBackend(vs.Backend, vs.Image, encryptionKey)
 	if err != nil {
 		return err
 	}
+	vs.thinPool, _ = vs.localVolume.(volume.ThinPoolBackend)
+	if vs.BackupMode == volume_backup.BackupModeBlocks {
+		if _, ok := vs.localVolume.(volume.BlockBackend); !ok {
+			return fmt.Errorf("block level backups are not supported by the %s backend", vs.Backend)
+		}
+	}
+
+	vs.backup = &volume_backup.VolumeBackup{
+		Client:                vs.Client,
+		Volume:                vs.localVolume,
+		RepositoryId:          vs.repository.ID,
+		VolumeUuid:            v.Uuid,
+		VolumeName:            v.Name,
+		Hostname:              vs.hostname,
+		RusticPassword:        vs.repository.Rustic.Password,
+		SnapshotMount:         vs.SnapshotMount,
+		WebdavProxyListenAddr: vs.WebdavProxyListen,
+	}
+
+	if vs.BackupMode != volume_backup.BackupModeBlocks {
+		vs.untaggedSnapshots, err = vs.backup.TagLegacySnapshots(ctx, vs.TagLegacySnapshots)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(restorePendingMarker); err == nil && vs.NoRestore {
+		vs.log.Warn("skipping pending restore as requested, starting with the volume as it is")
+		err = os.Remove(restorePendingMarker)
+		if err != nil {
+			return err
+		}
+	} else if err == nil {
+		err = vs.restoreLatestBackup(ctx)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(restorePendingMarker)
+		if err != nil {
+			return err
+		}
+	}
 
 	vs.log.Info("mounting volume", slog.Any("mountPath", vs.Mount))
 	err = vs.localVolume.Mount(vs.Mount)
 	if err != nil {
 		return err
 	}
+	vs.mounted = true
 
-	go vs.periodicBackup(ctx)
+	// this also finishes a grow that was interrupted by a restart
+	err = vs.grow(v.FsSize)
+	if err != nil {
+		return err
+	}
+
+	vs.goRoutine(func() {
+		vs.periodicCheckFence(ctx)
+	})
+	vs.goRoutine(func() {
+		vs.periodicCheckResize(ctx)
+	})
+	if vs.thinPool != nil {
+		vs.goRoutine(func() {
+			vs.periodicCheckThinPool(ctx)
+		})
+	}
+	vs.goRoutine(func() {
+		vs.periodicBackup(ctx)
+	})
+	if vs.ForgetInterval != 0 {
+		vs.goRoutine(func() {
+			vs.periodicForget(ctx)
+		})
+	}
+	if vs.PruneInterval != 0 {
+		vs.goRoutine(func() {
+			vs.periodicPrune(ctx)
+		})
+	}
+	if vs.LocalSnapshotInterval != 0 {
+		vs.goRoutine(func() {
+			vs.periodicLocalSnapshot(ctx)
+		})
+	}
 
 	return nil
 }
 
+func (vs *VolumeServe) goRoutine(fn func()) {
+	vs.wg.Add(1)
+	go func() {
+		defer vs.wg.Done()
+		fn()
+	}()
+}
+
+// Stop waits for all background tasks to finish, which requires the context passed to Start to be cancelled.
+// It then optionally performs a final backup, tears down the local volume and releases the volume lock. Failures are
+// logged and the remaining teardown steps are still performed, except that the lock is kept when the volume could
+// not be unmounted and released, as it is then still in use by this host.
+func (vs *VolumeServe) Stop(ctx context.Context) error {
+	vs.wg.Wait()
+
+	fenced := vs.isFenced()
+
+	var errs []error
+	releaseLock := true
+	if vs.localVolume != nil {
+		if fenced {
+			vs.log.Warn("volume is fenced, skipping final backup")
+		} else if vs.FinalBackup && vs.mounted {
+			// only back up volumes which were fully started, as a failed restore would otherwise result in a backup
+			// of a partially restored volume
+			err := vs.finalBackup(ctx)
+			if err != nil {
+				vs.log.Error("final backup failed", slog.Any("error", err))
+				errs = append(errs, err)
+			}
+		}
+
+		if fenced && vs.FenceMode == FenceModeFreeze {
+			// a frozen filesystem can't be unmounted
+			err := vs.localVolume.Thaw(vs.Mount)
+			if err != nil {
+				vs.log.Error("thawing volume failed", slog.Any("error", err))
+				errs = append(errs, err)
+			}
+		}
+
+		vs.log.Info("unmounting volume", slog.Any("mountPath", vs.Mount))
+		err := vs.localVolume.Unmount(vs.Mount)
+		if err != nil {
+			vs.log.Error("unmounting volume failed", slog.Any("error", err))
+			errs = append(errs, err)
+			releaseLock = false
+		}
+		vs.log.Info("releasing local volume")
+		err = vs.localVolume.Release()
+		if err != nil {
+			vs.log.Error("releasing local volume failed", slog.Any("error", err))
+			errs = append(errs, err)
+			releaseLock = false
+		}
+		if releaseLock {
+			vs.localVolume = nil
+			vs.thinPool = nil
+			vs.mounted = false
+		}
+	}
+	if vs.imageLock != nil && releaseLock {
+		_ = vs.imageLock.Close()
+		vs.imageLock = nil
+	}
+
+	if fenced {
+		vs.log.Warn("volume is fenced, not releasing the lock as it might not be ours anymore")
+	} else if !releaseLock {
+		vs.log.Warn("local volume is still in use, not releasing the lock")
+	} else if v := vs.getVolume(); v != nil && v.LockId != nil {
+		vs.log.Info("releasing volume lock", slog.Any("lockId", *v.LockId))
+		unlocked, err := vs.Client.VolumeUnlock(ctx, vs.RepositoryId, vs.VolumeId, models.VolumeUnlockRequest{
+			LockId: *v.LockId,
+		})
+		if err != nil {
+			vs.log.Error("releasing volume lock failed", slog.Any("error", err))
+			errs = append(errs, err)
+		} else {
+			vs.setVolume(unlocked)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// finalBackup keeps refreshing the lock while the backup runs, as the background refresher is already stopped and
+// the backup might take longer than the lock TTL
+func (vs *VolumeServe) finalBackup(ctx context.Context) error {
+	refreshCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		vs.periodicRefreshLock(refreshCtx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	vs.log.Info("performing final backup")
+	backup, err := vs.doBackup(ctx)
+	if err != nil {
+		return err
+	}
+	return vs.reportBackup(ctx, backup)
+}
+
+// getVolume returns a copy of the volume as returned by the last successful lock refresh
+func (vs *VolumeServe) getVolume() *models.Volume {
+	vs.volumeMutex.Lock()
+	defer vs.volumeMutex.Unlock()
+	if vs.volume == nil {
+		return nil
+	}
+	v := *vs.volume
+	return &v
+}
+
+func (vs *VolumeServe) setVolume(v *models.Volume) {
+	vs.volumeMutex.Lock()
+	defer vs.volumeMutex.Unlock()
+	vs.volume = v
+}
+
 func (vs *VolumeServe) lockVolume(ctx context.Context, prevLockId *string) error {
-	var err error
 	if prevLockId == nil {
 		vs.log.Info("locking volume")
 	} else {
 		vs.log.Info("refreshing lock", slog.Any("prevLockId", *prevLockId))
 	}
 	lockRequest := models.VolumeLockRequest{
-		PrevLockId: prevLockId,
+		PrevLockId:    prevLockId,
+		Hostname:      &vs.hostname,
+		Pid:           util.Ptr(int64(os.Getpid())),
+		ClientVersion: &version.Version,
+		Label:         vs.LockLabel,
 	}
-	vs.volume, err = vs.Client.VolumeLock(ctx, vs.RepositoryId, vs.VolumeId, lockRequest)
+	// only replace the volume on success, so that a failed refresh keeps the last good lock id for the retry
+	v, err := vs.Client.VolumeLock(ctx, vs.RepositoryId, vs.VolumeId, lockRequest)
 	if err != nil {
+		if prevLockId != nil && client.IsHttpStatus(err, http.StatusConflict) {
+			vs.onLockLost(*prevLockId)
+		}
 		return err
 	}
-	if prevLockId == nil || *prevLockId != *vs.volume.LockId {
+	if v.LockId == nil {
+		return fmt.Errorf("server returned no lock id")
+	}
+	vs.setVolume(v)
+	if prevLockId == nil || *prevLockId != *v.LockId {
 		if vs.UpdateLockIdCb != nil {
-			err = vs.UpdateLockIdCb(*vs.volume.LockId)
+			err = vs.UpdateLockIdCb(*v.LockId)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	vs.log.Info("volume locked", slog.Any("lockId", *vs.volume.LockId))
+	vs.log.Info("volume locked", slog.Any("lockId", *v.LockId))
+	vs.onLockRefreshed(*v.LockId, time.Duration(v.LockTtl)*time.Second)
 	return nil
 }
 
-func (vs *VolumeServe) periodicBackup(ctx context.Context) {
-	vb := volume_backup.VolumeBackup{
-		Client:                vs.Client,
-		Volume:                vs.localVolume,
-		RepositoryId:          vs.repository.ID,
-		RusticPassword:        vs.repository.Rustic.Password,
-		SnapshotMount:         vs.SnapshotMount,
-		WebdavProxyListenAddr: vs.WebdavProxyListen,
+func (vs *VolumeServe) restoreLatestBackup(ctx context.Context) error {
+	if vs.BackupMode == volume_backup.BackupModeBlocks {
+		return vs.restoreLatestBlockBackup(ctx)
 	}
 
+	vs.log.Info("looking for latest backup to restore")
+	snapshot, err := vs.backup.GetLatestSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		if vs.untaggedSnapshots != 0 {
+			// these might be backups of this volume that were taken on another host, so starting with an empty volume
+			// could silently hide its data
+			return fmt.Errorf("no backup of this volume found, but the repository contains %d untagged snapshots from an older version "+
+				"that were not tagged with the identity of this volume. Use --tag-legacy-snapshots to claim the ones with the hostname "+
+				"and path of this volume, tag them with the volume identity via 'rustic tag' or use --no-restore to start with an "+
+				"empty volume", vs.untaggedSnapshots)
+		}
+		vs.log.Info("no backup found, starting with an empty volume")
+		return nil
+	}
+
+	// restore into the snapshot mount so that the volume is never visible at the real mount in a partially restored state
+	err = vs.localVolume.Mount(vs.SnapshotMount)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := vs.localVolume.Unmount(vs.SnapshotMount)
+		if err != nil {
+			vs.log.Error("deferred unmounting failed", slog.Any("error", err))
+		}
+	}()
+
+	err = vs.backup.Restore(ctx, snapshot, vs.SnapshotMount)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vs *VolumeServe) restoreLatestBlockBackup(ctx context.Context) error {
+	vs.log.Info("looking for latest block backup to restore")
+	m, err := vs.backup.GetLatestBlockManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		vs.log.Info("no block backup found, starting with an empty volume")
+		return nil
+	}
+
+	// the volume was just created and is not mounted yet, so we can write directly to its block device
+	bv := vs.localVolume.(volume.BlockBackend)
+	return vs.backup.RestoreBlocks(ctx, m, bv.DevName())
+}
+
+func (vs *VolumeServe) periodicBackup(ctx context.Context) {
 	for {
 		select {
 		case <-time.After(vs.BackupInterval):
-			err := vb.Backup(ctx)
+			if vs.isFenced() {
+				vs.log.Warn("volume is fenced, skipping backup")
+				continue
+			}
+			backup, err := vs.doBackup(ctx)
 			if err != nil {
 				vs.log.Error("backup failed", slog.Any("error", err))
+				continue
+			}
+			err = vs.reportBackup(ctx, backup)
+			if err != nil {
+				vs.log.Error("reporting backup failed", slog.Any("error", err))
 			}
 		case <-ctx.Done():
 			return
@@ -145,15 +505,94 @@ func (vs *VolumeServe) periodicBackup(ctx context.Context) {
 	}
 }
 
+func (vs *VolumeServe) doBackup(ctx context.Context) (*models.CreateVolumeBackup, error) {
+	vs.localMutex.Lock()
+	defer vs.localMutex.Unlock()
+
+	err := vs.checkThinPoolForSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	vs.backup.SnapshotHooks = vs.buildSnapshotHooks()
+
+	if vs.BackupMode == volume_backup.BackupModeBlocks {
+		m, summary, err := vs.backup.BackupBlocks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &models.CreateVolumeBackup{
+			SnapshotId:   m.ID,
+			SnapshotTime: m.Time,
+			Hostname:     m.Hostname,
+			DurationMs:   summary.Duration.Milliseconds(),
+			Summary: models.VolumeBackupSummary{
+				TotalBytes:      summary.BytesProcessed,
+				DataAdded:       summary.BytesUploaded,
+				DataAddedPacked: summary.BytesUploaded,
+			},
+			SnapshotHooks: snapshotHooksResultToModel(summary.SnapshotHooks),
+		}, nil
+	}
+
+	snapshot, hooksResult, err := vs.backup.Backup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := &models.CreateVolumeBackup{
+		SnapshotId:    snapshot.ID,
+		SnapshotTime:  snapshot.Time,
+		Hostname:      snapshot.Hostname,
+		SnapshotHooks: snapshotHooksResultToModel(hooksResult),
+	}
+	if snapshot.Summary != nil {
+		req.DurationMs = int64(snapshot.Summary.TotalDuration * 1000)
+		req.Summary = models.VolumeBackupSummary{
+			TotalFiles:      snapshot.Summary.TotalFilesProcessed,
+			TotalBytes:      snapshot.Summary.TotalBytesProcessed,
+			FilesNew:        snapshot.Summary.FilesNew,
+			FilesChanged:    snapshot.Summary.FilesChanged,
+			FilesUnmodified: snapshot.Summary.FilesUnmodified,
+			DirsNew:         snapshot.Summary.DirsNew,
+			DirsChanged:     snapshot.Summary.DirsChanged,
+			DirsUnmodified:  snapshot.Summary.DirsUnmodified,
+			DataAdded:       snapshot.Summary.DataAdded,
+			DataAddedPacked: snapshot.Summary.DataAddedPacked,
+		}
+	}
+	return req, nil
+}
+
+func (vs *VolumeServe) reportBackup(ctx context.Context, req *models.CreateVolumeBackup) error {
+	v := vs.getVolume()
+	if v == nil || v.LockId == nil {
+		return fmt.Errorf("volume is not locked")
+	}
+	req.LockId = *v.LockId
+
+	vs.log.Info("backup done",
+		slog.Any("snapshotId", req.SnapshotId),
+		slog.Any("totalFiles", req.Summary.TotalFiles),
+		slog.Any("dataAdded", humanize.Bytes(uint64(req.Summary.DataAdded))),
+		slog.Any("frozen", req.SnapshotHooks.Frozen),
+	)
+
+	_, err := vs.Client.CreateVolumeBackup(ctx, vs.RepositoryId, vs.VolumeId, *req)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (vs *VolumeServe) periodicRefreshLock(ctx context.Context) {
 	for {
-		err := vs.lockVolume(ctx, vs.volume.LockId)
+		err := vs.lockVolume(ctx, vs.getVolume().LockId)
 		if err != nil {
 			vs.log.Error("error in VolumeLock", slog.Any("error", err))
 		}
 
 		select {
-		case <-time.After(15 * time.Second):
+		case <-time.After(vs.lockRefreshInterval()):
 		case <-ctx.Done():
 			return
 		}